@@ -0,0 +1,102 @@
+// migrate はFirestoreのユーザーデータを最新の形式（models.UserSchemaVersion）に変換します
+//
+//	go run ./cmd/migrate -dry-run          # 変更内容をログに出すだけで書き込まない
+//	go run ./cmd/migrate                   # すべてのユーザーを最新のバージョンまで変換する
+//	go run ./cmd/migrate -to 2 -user <uid> # 指定したユーザーをバージョン2まで変換する
+//
+// Firestoreの接続先はサーバーと同じ設定（環境変数・設定ファイル）を使います
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"geekcamp-vol10-backend/internal/config"
+	"geekcamp-vol10-backend/internal/logging"
+	"geekcamp-vol10-backend/internal/migrations"
+	"geekcamp-vol10-backend/pkg/database"
+)
+
+func main() {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configFile := flags.String("config", "", "設定ファイル(.env形式)のパス（環境変数 CONFIG_FILE）")
+	dryRun := flags.Bool("dry-run", false, "変更内容をログに出すだけで書き込まない")
+	target := flags.Int("to", 0, "このバージョンまで適用する（0の場合は最新まで）")
+	userID := flags.String("user", "", "指定したユーザーのみ変換する")
+	list := flags.Bool("list", false, "マイグレーションの一覧を表示して終了する")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+
+	if *list {
+		for _, m := range migrations.All {
+			fmt.Printf("%d\t%s\n", m.Version, m.Name)
+		}
+		return
+	}
+
+	var configArgs []string
+	if *configFile != "" {
+		configArgs = []string{"-config", *configFile}
+	}
+	cfg, err := config.Load(configArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opts := migrations.Options{DryRun: *dryRun, Target: *target, UserID: *userID}
+	if err := run(cfg, opts); err != nil {
+		slog.Error("マイグレーションを終了します", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run はマイグレーションを実行し、失敗したユーザーがいた場合はエラーを返します
+func run(cfg *config.Config, opts migrations.Options) error {
+	logging.Setup(os.Stdout, cfg.LogLevel)
+
+	// Ctrl+Cで中断しても、処理中のマイグレーションはトランザクションごと取り消されるため、再実行すると続きから変換する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fb, err := database.NewFirebase(context.Background(), cfg)
+	if err != nil {
+		return fmt.Errorf("Firestore の初期化に失敗しました: %w", err)
+	}
+	defer fb.Close()
+
+	runner, err := migrations.NewRunner(fb.Firestore, migrations.All)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		slog.Info("dry-runのため書き込みません。2つ目以降のマイグレーションは、前のマイグレーションを適用する前のデータに対する変更を表示します")
+	}
+	result, err := runner.Run(ctx, opts)
+	slog.Info("マイグレーションが終了しました",
+		"dry_run", opts.DryRun,
+		"users", result.Users,
+		"migrated", result.Migrated,
+		"up_to_date", result.UpToDate,
+		"failed", result.Failed,
+		"changes", result.Changes,
+	)
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d人のユーザーの変換に失敗しました。ログを確認して、データを直してから再実行してください", result.Failed)
+	}
+	return nil
+}
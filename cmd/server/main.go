@@ -2,60 +2,89 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"geekcamp-vol10-backend/internal/config"
-	"geekcamp-vol10-backend/internal/handlers"
+	"geekcamp-vol10-backend/internal/logging"
+	"geekcamp-vol10-backend/internal/server"
+	"geekcamp-vol10-backend/internal/tracing"
 	"geekcamp-vol10-backend/pkg/database"
-	//"geekcamp-vol10-backend/internal/middleware"
 )
 
 func main() {
-	// 設定を読み込み
-	cfg := config.LoadConfig()
+	// 設定を読み込み（フラグ > 環境変数 > 設定ファイル > デフォルト値）
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// ログの設定前なので、問題の一覧をそのまま標準エラーに出す
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// Firestoreを初期化
-	ctx := context.Background()
-	if err := database.InitializeFirestore(ctx, cfg); err != nil {
-		log.Fatalf("Firestore の初期化に失敗しました: %v", err)
+	if err := run(cfg); err != nil {
+		slog.Error("サーバーを終了します", "error", err)
+		os.Exit(1)
 	}
+}
 
-	// アプリケーション終了時にFirestoreクライアントをクローズ
+// run はサーバーを起動し、SIGINT/SIGTERMを受けるまで処理を続けます
+// 終了時のFirestoreのクローズやトレースの送信をdeferで確実に行うため、os.Exitはmainでのみ呼び出します
+func run(cfg *config.Config) error {
+	// 構造化ログ(JSON)を標準出力に出す。トークンやメールアドレスはマスクされる
+	logging.Setup(os.Stdout, cfg.LogLevel)
+
+	ctx := context.Background()
+
+	// トレースを初期化（TRACE_EXPORTER=none の場合は何も出力しない）
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TraceExporter)
+	if err != nil {
+		return fmt.Errorf("トレースの初期化に失敗しました: %w", err)
+	}
+	// 終了時に未送信のスパンを送信する
 	defer func() {
-		if err := database.CloseFirestore(); err != nil {
-			log.Printf("Firestore のクローズに失敗しました: %v", err)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Error("トレースの終了処理に失敗しました", "error", err)
 		}
 	}()
 
-	// Ginルーターを初期化
-	r := gin.Default()
-
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "OK",
-		})
-	})
-
-
-	// authが必要なエンドポイントにmiddleware/auth.goを適用
-	authRequired := r.Group("/")
-	authRequired.POST("/users", handlers.Users)
-	authRequired.GET("/users/:id", handlers.GETUser)
-	authRequired.GET("/contributions/:id", handlers.GetContribution)
-	/*
-		authRequired.Use(middleware.AuthMiddleware())
-		{
-			authRequired.GET("/contributions/:id", handlers.GetContribution)
+	// FirebaseアプリとFirestoreを初期化
+	fb, err := database.NewFirebase(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("Firestore の初期化に失敗しました: %w", err)
+	}
+
+	// アプリケーション終了時にFirestoreクライアントをクローズ
+	// 処理中の同期が書き込みを終えてから閉じるため、サーバーの停止より後に実行される
+	defer func() {
+		if err := fb.Close(); err != nil {
+			slog.Error("Firestore のクローズに失敗しました", "error", err)
+			return
 		}
-	*/
+		slog.Info("Firestore をクローズしました")
+	}()
 
-	// サーバーをポート8080で起動
-	if err := r.Run("localhost:8081"); err != nil {
-		// エラーが発生した場合、ログに詳細を出力してプログラムを終了する
-		log.Fatalf("サーバーの起動に失敗しました: %v", err)
+	// リポジトリ・サービス・ハンドラーを組み立ててルートを登録
+	srv, err := server.New(ctx, cfg, fb)
+	if err != nil {
+		return err
 	}
 
+	// SIGINT (Ctrl+C) / SIGTERM (コンテナの停止) を受けたらシャットダウンする
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// シャットダウンが始まったら、2回目のシグナルは通常どおりプロセスを終了させる
+	context.AfterFunc(sigCtx, stop)
+
+	return srv.Run(sigCtx)
 }
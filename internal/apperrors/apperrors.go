@@ -0,0 +1,102 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// アプリ全体で使うドメインエラー
+// リポジトリ・サービス層では fmt.Errorf("...: %w", apperrors.ErrXxx) のようにラップして返し、
+// HTTPレスポンスへの変換はMiddlewareで一括して行います
+var (
+	ErrValidation             = errors.New("リクエストの内容が正しくありません")
+	ErrUserNotFound           = errors.New("ユーザーが見つかりません")
+	ErrCurrentMonsterNotFound = errors.New("育成中のモンスターが見つかりません")
+	ErrDeviceNotFound         = errors.New("端末が登録されていません")
+	ErrWebhookNotFound        = errors.New("webhookが登録されていません")
+	ErrWebhookLimitExceeded   = errors.New("登録できるwebhookの数の上限に達しています")
+	ErrConflict               = errors.New("リソースが既に存在します")
+	ErrUnauthenticated        = errors.New("有効なIDトークンが必要です")
+	ErrAdminRequired          = errors.New("管理者の権限が必要です")
+	ErrForbidden              = errors.New("他のユーザーのデータにはアクセスできません")
+	ErrGitHubTokenMissing     = errors.New("IDトークンにGitHubアクセストークンが含まれていません")
+	ErrRateLimited            = errors.New("リクエストが多すぎます")
+	ErrGitHubCredentials      = errors.New("GitHubのユーザー名とトークンは必須です")
+	ErrGitHubUnauthorized     = errors.New("GitHubのトークンが無効です")
+	ErrGitHubRateLimited      = errors.New("GitHub APIのレート制限に達しました")
+	ErrGitHubUserNotFound     = errors.New("GitHubのユーザーが見つかりません")
+	ErrGitHubUserMismatch     = errors.New("GitHubユーザー名がトークンの持ち主と一致しません")
+	ErrGitHubUnavailable      = errors.New("GitHub APIに接続できません")
+	ErrMonsterNotFound        = errors.New("モンスターが見つかりません")
+	ErrMonsterCatalogBroken   = errors.New("モンスターのマスターデータが不正です")
+	ErrDatabaseUnavailable    = errors.New("データベースに接続できません")
+)
+
+// kind はエラーとHTTPステータス・安定したエラーコードの対応です
+type kind struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// 上から順にerrors.Isで判定するため、より具体的なエラーを先に並べる
+var kinds = []kind{
+	{ErrValidation, http.StatusBadRequest, "validation_failed", "Validation failed"},
+	{ErrUserNotFound, http.StatusNotFound, "user_not_found", "User not found"},
+	{ErrCurrentMonsterNotFound, http.StatusNotFound, "current_monster_not_found", "Current monster not found"},
+	{ErrDeviceNotFound, http.StatusNotFound, "device_not_found", "Device not found"},
+	{ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found", "Webhook not found"},
+	{ErrWebhookLimitExceeded, http.StatusConflict, "webhook_limit_exceeded", "Webhook limit exceeded"},
+	{ErrConflict, http.StatusConflict, "conflict", "Conflict"},
+	{ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Unauthenticated"},
+	{ErrAdminRequired, http.StatusForbidden, "admin_required", "Admin required"},
+	{ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+	{ErrGitHubTokenMissing, http.StatusForbidden, "github_token_missing", "GitHub token missing"},
+	{ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "Too many requests"},
+	{ErrGitHubCredentials, http.StatusBadRequest, "github_credentials_missing", "GitHub credentials missing"},
+	{ErrGitHubUnauthorized, http.StatusUnauthorized, "github_unauthorized", "GitHub token rejected"},
+	{ErrGitHubRateLimited, http.StatusTooManyRequests, "github_rate_limited", "GitHub rate limit exceeded"},
+	{ErrGitHubUserNotFound, http.StatusNotFound, "github_user_not_found", "GitHub user not found"},
+	{ErrGitHubUserMismatch, http.StatusForbidden, "github_user_mismatch", "GitHub user mismatch"},
+	{ErrGitHubUnavailable, http.StatusBadGateway, "github_unavailable", "GitHub unavailable"},
+	{ErrMonsterNotFound, http.StatusNotFound, "monster_not_found", "Monster not found"},
+	{ErrMonsterCatalogBroken, http.StatusInternalServerError, "monster_catalog_broken", "Monster catalog broken"},
+	{ErrDatabaseUnavailable, http.StatusServiceUnavailable, "database_unavailable", "Database unavailable"},
+}
+
+var internalKind = kind{nil, http.StatusInternalServerError, "internal", "Internal server error"}
+
+func classify(err error) kind {
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k
+		}
+	}
+	return internalKind
+}
+
+// Error はドメインエラーにレスポンスへ含める追加情報を持たせたエラーです
+type Error struct {
+	Err        error
+	Extensions map[string]interface{}
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// WithExtensions はproblemレスポンスに追加のフィールドを含めるようにエラーをラップします
+func WithExtensions(err error, extensions map[string]interface{}) error {
+	return &Error{Err: err, Extensions: extensions}
+}
+
+// Status はエラーに対応するHTTPステータスを返します
+func Status(err error) int {
+	return classify(err).status
+}
+
+// Code はエラーに対応する安定したエラーコードを返します
+func Code(err error) string {
+	return classify(err).code
+}
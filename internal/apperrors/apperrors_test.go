@@ -0,0 +1,90 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+		wantTitle  string
+	}{
+		{ErrValidation, http.StatusBadRequest, "validation_failed", "Validation failed"},
+		{ErrUserNotFound, http.StatusNotFound, "user_not_found", "User not found"},
+		{ErrWebhookLimitExceeded, http.StatusConflict, "webhook_limit_exceeded", "Webhook limit exceeded"},
+		{ErrConflict, http.StatusConflict, "conflict", "Conflict"},
+		{ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Unauthenticated"},
+		{ErrAdminRequired, http.StatusForbidden, "admin_required", "Admin required"},
+		{ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+		{ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "Too many requests"},
+		{ErrGitHubUnauthorized, http.StatusUnauthorized, "github_unauthorized", "GitHub token rejected"},
+		{ErrGitHubUnavailable, http.StatusBadGateway, "github_unavailable", "GitHub unavailable"},
+		{ErrMonsterCatalogBroken, http.StatusInternalServerError, "monster_catalog_broken", "Monster catalog broken"},
+		{ErrDatabaseUnavailable, http.StatusServiceUnavailable, "database_unavailable", "Database unavailable"},
+		// ラップされていても元のエラーで判定する
+		{fmt.Errorf("ユーザー u1 の取得: %w", ErrUserNotFound), http.StatusNotFound, "user_not_found", "User not found"},
+		{fmt.Errorf("外側: %w", fmt.Errorf("内側: %w", ErrForbidden)), http.StatusForbidden, "forbidden", "Forbidden"},
+		{WithExtensions(ErrConflict, map[string]interface{}{"user": "u1"}), http.StatusConflict, "conflict", "Conflict"},
+	}
+	for _, tt := range tests {
+		p := NewProblem(tt.err, "/users/u1")
+		if p.Status != tt.wantStatus || p.Code != tt.wantCode || p.Title != tt.wantTitle {
+			t.Errorf("NewProblem(%v) = %d %s %q, want %d %s %q", tt.err, p.Status, p.Code, p.Title, tt.wantStatus, tt.wantCode, tt.wantTitle)
+		}
+		if p.Type != "/problems/"+tt.wantCode || p.Instance != "/users/u1" {
+			t.Errorf("NewProblem(%v) type = %s, instance = %s", tt.err, p.Type, p.Instance)
+		}
+		if Status(tt.err) != tt.wantStatus || Code(tt.err) != tt.wantCode {
+			t.Errorf("Status/Code(%v) = %d %s", tt.err, Status(tt.err), Code(tt.err))
+		}
+	}
+}
+
+func TestNewProblemDetail(t *testing.T) {
+	// detailはラップした側の文言ではなく、ドメインエラーの文言にする（内部の詳細を出さない）
+	p := NewProblem(fmt.Errorf("users/u1 の読み込み: %w", ErrUserNotFound), "")
+	if p.Detail != ErrUserNotFound.Error() {
+		t.Errorf("Detail = %q, want %q", p.Detail, ErrUserNotFound.Error())
+	}
+
+	// 未知のエラーは500で、詳細を返さない
+	p = NewProblem(errors.New("rpc error: code = Internal desc = secret"), "")
+	if p.Status != http.StatusInternalServerError || p.Code != "internal" || p.Detail != "" {
+		t.Errorf("NewProblem(未知のエラー) = %+v", p)
+	}
+}
+
+func TestNewProblemSpecificBeforeGeneric(t *testing.T) {
+	// 上限超過はErrConflictより先に判定する
+	err := fmt.Errorf("%w: %w", ErrWebhookLimitExceeded, ErrConflict)
+	if got := Code(err); got != "webhook_limit_exceeded" {
+		t.Errorf("Code() = %s, want webhook_limit_exceeded", got)
+	}
+}
+
+func TestWithExtensions(t *testing.T) {
+	ext := map[string]interface{}{"retryAfter": 30}
+	err := WithExtensions(fmt.Errorf("GitHub: %w", ErrGitHubRateLimited), ext)
+
+	if !errors.Is(err, ErrGitHubRateLimited) {
+		t.Error("errors.Isで元のエラーを判定できません")
+	}
+	if err.Error() != "GitHub: "+ErrGitHubRateLimited.Error() {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	p := NewProblem(err, "")
+	if p.Extensions["retryAfter"] != 30 {
+		t.Errorf("Extensions = %v", p.Extensions)
+	}
+
+	// さらにラップされても拡張フィールドを取り出す
+	p = NewProblem(fmt.Errorf("外側: %w", err), "")
+	if p.Extensions["retryAfter"] != 30 || p.Code != "github_rate_limited" {
+		t.Errorf("ラップした場合 = %+v", p)
+	}
+}
@@ -0,0 +1,93 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType はRFC 7807のレスポンスのContent-Typeです
+const ProblemContentType = "application/problem+json"
+
+// Problem はRFC 7807 (Problem Details for HTTP APIs) 形式のレスポンスです
+// Codeはアプリ側で分岐に使う安定したエラーコードです
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Code       string                 `json:"code"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON は拡張フィールドをトップレベルに展開してエンコードします
+func (p Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+	out["type"] = p.Type
+	out["title"] = p.Title
+	out["status"] = p.Status
+	out["code"] = p.Code
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	return json.Marshal(out)
+}
+
+// NewProblem はエラーからproblemレスポンスを組み立てます
+func NewProblem(err error, instance string) Problem {
+	k := classify(err)
+	p := Problem{
+		Type:     "/problems/" + k.code,
+		Title:    k.title,
+		Status:   k.status,
+		Instance: instance,
+		Code:     k.code,
+	}
+	// 内部エラーの詳細は外部に出さない
+	if k.err != nil {
+		p.Detail = k.err.Error()
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		p.Extensions = appErr.Extensions
+	}
+	return p
+}
+
+// Abort はエラーをgin.Contextに登録して処理を中断します
+// レスポンスはMiddlewareが書き込みます
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Middleware はハンドラーで登録されたエラーをproblem+jsonのレスポンスに変換するミドルウェアを返します
+// 既にレスポンスが書き込まれている場合は何もしません
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := NewProblem(err, c.Request.URL.Path)
+		if problem.Status >= 500 {
+			slog.ErrorContext(c.Request.Context(), "リクエストの処理中にエラーが発生しました", "code", problem.Code, "error", err)
+		}
+
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(problem.Status, problem)
+	}
+}
@@ -0,0 +1,86 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProblemMarshalJSON(t *testing.T) {
+	p := NewProblem(WithExtensions(ErrConflict, map[string]interface{}{
+		"user": map[string]interface{}{"firebaseId": "u1"},
+		// 標準のフィールドは拡張フィールドで上書きできない
+		"status": 200,
+		"code":   "ok",
+	}), "/users")
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["type"] != "/problems/conflict" || got["title"] != "Conflict" || got["status"] != float64(http.StatusConflict) || got["code"] != "conflict" || got["instance"] != "/users" {
+		t.Errorf("MarshalJSON() = %s", b)
+	}
+	if user, _ := got["user"].(map[string]interface{}); user["firebaseId"] != "u1" {
+		t.Errorf("拡張フィールドがトップレベルにありません: %s", b)
+	}
+	if _, ok := got["Extensions"]; ok {
+		t.Errorf("Extensionsをそのまま出力しました: %s", b)
+	}
+
+	// 詳細がない場合はdetailを出さない
+	b, _ = json.Marshal(NewProblem(nil, ""))
+	var internal map[string]interface{}
+	if err := json.Unmarshal(b, &internal); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := internal["detail"]; ok {
+		t.Errorf("MarshalJSON() = %s", b)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/abort", func(c *gin.Context) {
+		Abort(c, ErrUserNotFound)
+	})
+	r.GET("/written", func(c *gin.Context) {
+		// 既にレスポンスを書き込んだ場合はそのまま
+		c.String(http.StatusTeapot, "teapot")
+		_ = c.Error(ErrUserNotFound)
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abort", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("/abort = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var got Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Code != "user_not_found" || got.Instance != "/abort" {
+		t.Errorf("/abort body = %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if w.Code != http.StatusTeapot || w.Body.String() != "teapot" {
+		t.Errorf("/written = %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("/ok = %d %s", w.Code, w.Body)
+	}
+}
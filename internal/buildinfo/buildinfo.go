@@ -0,0 +1,53 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// ビルド時に -ldflags で埋め込む値
+//
+//	go build -ldflags "-X geekcamp-vol10-backend/internal/buildinfo.Version=v1.2.3 \
+//	  -X geekcamp-vol10-backend/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X geekcamp-vol10-backend/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// プロセスの起動時刻
+var startTime = time.Now()
+
+// Info はビルド情報と起動時刻です
+type Info struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildTime string    `json:"buildTime,omitempty"`
+	GoVersion string    `json:"goVersion"`
+	StartTime time.Time `json:"startTime"`
+}
+
+// Get はビルド情報を返します
+// Commitが埋め込まれていない場合は、go buildが記録したVCSの情報（vcs.revision）を使います
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		StartTime: startTime,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
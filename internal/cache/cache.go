@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Store はキーごとにバイト列をTTL付きで保存するキャッシュです
+// 1インスタンスならMemoryStore、複数インスタンスで共有する場合はFirestoreに保存する実装を使います
+type Store interface {
+	// Get はkeyの値を返します。存在しない・期限切れの場合はfalseを返します
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set はkeyに値をttlの間保存します
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MemoryStore はプロセス内に保持するLRUキャッシュです
+// maxEntriesを超えると、最も長く使われていないエントリから削除します
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryStore はmaxEntries件まで保持するMemoryStoreを作成します
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get はkeyの値を返します
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set はkeyに値をttlの間保存します
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := s.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		s.ll.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for s.ll.Len() > s.maxEntries {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+// Len は保持しているエントリ数（期限切れで未削除のものを含む）を返します
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func mustGet(t *testing.T, s *MemoryStore, key string) (string, bool) {
+	t.Helper()
+	value, ok, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", key, err)
+	}
+	return string(value), ok
+}
+
+func mustSet(t *testing.T, s *MemoryStore, key, value string, ttl time.Duration) {
+	t.Helper()
+	if err := s.Set(context.Background(), key, []byte(value), ttl); err != nil {
+		t.Fatalf("Set(%s) error = %v", key, err)
+	}
+}
+
+func TestMemoryStoreGetSet(t *testing.T) {
+	s := NewMemoryStore(10)
+	if _, ok := mustGet(t, s, "a"); ok {
+		t.Error("空のキャッシュから値を取得しました")
+	}
+	mustSet(t, s, "a", "1", time.Minute)
+	if got, ok := mustGet(t, s, "a"); !ok || got != "1" {
+		t.Errorf("Get(a) = %q, %v", got, ok)
+	}
+
+	// 上書きしても件数は増えない
+	mustSet(t, s, "a", "2", time.Minute)
+	if got, _ := mustGet(t, s, "a"); got != "2" || s.Len() != 1 {
+		t.Errorf("上書き後: Get(a) = %q, Len() = %d", got, s.Len())
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	s := NewMemoryStore(10)
+	mustSet(t, s, "expired", "1", 0)
+	mustSet(t, s, "short", "2", 20*time.Millisecond)
+	mustSet(t, s, "long", "3", time.Minute)
+
+	// TTLが0以下のものはすぐに期限切れになり、読み込んだ時点で削除する
+	if _, ok := mustGet(t, s, "expired"); ok {
+		t.Error("期限切れの値を取得しました")
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", s.Len())
+	}
+
+	if _, ok := mustGet(t, s, "short"); !ok {
+		t.Error("期限前の値を取得できません")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := mustGet(t, s, "short"); ok {
+		t.Error("期限切れの値を取得しました")
+	}
+	if _, ok := mustGet(t, s, "long"); !ok {
+		t.Error("期限前の値を取得できません")
+	}
+
+	// 上書きするとTTLも延びる
+	mustSet(t, s, "short", "4", time.Minute)
+	if got, ok := mustGet(t, s, "short"); !ok || got != "4" {
+		t.Errorf("上書き後: Get(short) = %q, %v", got, ok)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStore(3)
+	mustSet(t, s, "a", "1", time.Minute)
+	mustSet(t, s, "b", "2", time.Minute)
+	mustSet(t, s, "c", "3", time.Minute)
+
+	// aを読み込むと、最も長く使われていないのはbになる
+	mustGet(t, s, "a")
+	mustSet(t, s, "d", "4", time.Minute)
+	if _, ok := mustGet(t, s, "b"); ok {
+		t.Error("最も長く使われていないbが残っています")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := mustGet(t, s, key); !ok {
+			t.Errorf("%s が削除されました", key)
+		}
+	}
+
+	// 上書きも使ったものとして扱う（a, c, dの順に使用→cを上書き→aが最も古い）
+	mustSet(t, s, "c", "5", time.Minute)
+	mustGet(t, s, "d")
+	mustSet(t, s, "e", "6", time.Minute)
+	if _, ok := mustGet(t, s, "a"); ok {
+		t.Error("最も長く使われていないaが残っています")
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+}
+
+func TestNewMemoryStoreMinimumSize(t *testing.T) {
+	s := NewMemoryStore(0)
+	mustSet(t, s, "a", "1", time.Minute)
+	mustSet(t, s, "b", "2", time.Minute)
+	if _, ok := mustGet(t, s, "a"); ok || s.Len() != 1 {
+		t.Errorf("NewMemoryStore(0) は1件だけ保持します: Len() = %d", s.Len())
+	}
+}
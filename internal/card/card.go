@@ -0,0 +1,572 @@
+// Package card はユーザーのアバター・育成中のモンスター・封印したモンスターのコレクション・封印記録を
+// 1枚のPNG画像（SNSで共有するためのカード）に描画します
+// データの取得は行わず、渡された画像と数値だけから描画します
+package card
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// レイアウト
+const (
+	// OGP画像（1200x630）
+	LayoutOG = "og"
+	// 正方形（1080x1080）
+	LayoutSquare = "square"
+)
+
+// サイズ
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
+// テーマ名
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// ErrUnknownLayout はレイアウトまたはサイズの指定が正しくない場合のエラーです
+var ErrUnknownLayout = errors.New("カードのレイアウトまたはサイズが正しくありません")
+
+// sizeScales はlargeを1としたサイズごとの倍率です
+var sizeScales = map[string]float64{
+	SizeSmall:  0.5,
+	SizeMedium: 2.0 / 3.0,
+	SizeLarge:  1,
+}
+
+// Theme はカードの配色です
+type Theme struct {
+	Background color.RGBA
+	Panel      color.RGBA
+	Text       color.RGBA
+	SubText    color.RGBA
+	Accent     color.RGBA
+	// HPバーの背景と残りHP
+	BarBackground color.RGBA
+	Bar           color.RGBA
+}
+
+// Themes はテーマ名ごとの配色です
+var Themes = map[string]Theme{
+	ThemeLight: {
+		Background:    color.RGBA{0xf6, 0xf8, 0xfa, 0xff},
+		Panel:         color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Text:          color.RGBA{0x1f, 0x23, 0x28, 0xff},
+		SubText:       color.RGBA{0x57, 0x60, 0x6a, 0xff},
+		Accent:        color.RGBA{0x21, 0x6e, 0x39, 0xff},
+		BarBackground: color.RGBA{0xeb, 0xed, 0xf0, 0xff},
+		Bar:           color.RGBA{0xcf, 0x22, 0x2e, 0xff},
+	},
+	ThemeDark: {
+		Background:    color.RGBA{0x0d, 0x11, 0x17, 0xff},
+		Panel:         color.RGBA{0x16, 0x1b, 0x22, 0xff},
+		Text:          color.RGBA{0xe6, 0xed, 0xf3, 0xff},
+		SubText:       color.RGBA{0x8b, 0x94, 0x9e, 0xff},
+		Accent:        color.RGBA{0x39, 0xd3, 0x53, 0xff},
+		BarBackground: color.RGBA{0x30, 0x36, 0x3d, 0xff},
+		Bar:           color.RGBA{0xff, 0x7b, 0x72, 0xff},
+	},
+}
+
+// Monster はカードに表示するモンスターです
+type Monster struct {
+	MonsterId string
+	Name      string
+	// nilの場合は代わりの印を描画します
+	Icon image.Image
+}
+
+// CurrentMonster は育成中のモンスターと進捗です
+type CurrentMonster struct {
+	Monster
+	ProgressContributions int
+	RequiredContributions int
+}
+
+// CollectionItem は封印したモンスター1種類分です
+type CollectionItem struct {
+	Monster
+	// 封印した回数
+	Count int
+}
+
+// Data はカードに描画する内容です
+type Data struct {
+	DisplayName    string
+	GithubUserName string
+	// nilの場合は名前の頭文字を描画します
+	Avatar image.Image
+	// nilの場合は育成中のモンスターがいないことを表示します
+	Current              *CurrentMonster
+	Collection           []CollectionItem
+	SealedMonsterCount   int64
+	ContinuousSealRecord int64
+	MaxSealRecord        int64
+}
+
+// Options は描画の設定です
+type Options struct {
+	Layout string
+	Size   string
+	Theme  Theme
+}
+
+// Dimensions はレイアウトとサイズに応じた画像の大きさを返します
+func Dimensions(layoutName, size string) (width, height int, err error) {
+	l, ok := layouts[layoutName]
+	scale, okSize := sizeScales[size]
+	if !ok || !okSize {
+		return 0, 0, fmt.Errorf("%s/%s: %w", layoutName, size, ErrUnknownLayout)
+	}
+	return int(float64(l.width) * scale), int(float64(l.height) * scale), nil
+}
+
+// Renderer はフォントを読み込んだ描画器です
+// 複数のgoroutineから同時に使えます
+type Renderer struct {
+	regular *opentype.Font
+	bold    *opentype.Font
+}
+
+// NewRenderer はRendererを作成します
+// fontFileが空の場合はバイナリに埋め込んだGoフォントを使います。Goフォントには日本語の字形がないため、
+// 日本語のモンスター名を表示する場合は日本語を含むTrueType/OpenTypeフォントのパスを指定してください（太字にも同じフォントを使います）
+func NewRenderer(fontFile string) (*Renderer, error) {
+	if fontFile != "" {
+		data, err := os.ReadFile(fontFile)
+		if err != nil {
+			return nil, fmt.Errorf("フォントを読み込めません: %w", err)
+		}
+		f, err := opentype.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("フォントを解析できません %s: %w", fontFile, err)
+		}
+		return &Renderer{regular: f, bold: f}, nil
+	}
+
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{regular: regular, bold: bold}, nil
+}
+
+// Render はカードをPNGとしてwに書き出します
+func (r *Renderer) Render(w io.Writer, data Data, opts Options) error {
+	l, ok := layouts[opts.Layout]
+	scale, okSize := sizeScales[opts.Size]
+	if !ok || !okSize {
+		return fmt.Errorf("%s/%s: %w", opts.Layout, opts.Size, ErrUnknownLayout)
+	}
+
+	c := &canvas{
+		r:     r,
+		scale: scale,
+		theme: opts.Theme,
+		faces: make(map[faceKey]font.Face),
+	}
+	defer c.close()
+	c.dst = image.NewRGBA(image.Rect(0, 0, c.px(l.width), c.px(l.height)))
+	draw.Draw(c.dst, c.dst.Bounds(), image.NewUniform(opts.Theme.Background), image.Point{}, draw.Src)
+
+	c.drawHeader(l, data)
+	c.drawCurrentMonster(l, data.Current)
+	c.drawStats(l, data)
+	c.drawCollection(l, data.Collection)
+
+	return png.Encode(w, c.dst)
+}
+
+// 座標はすべてlargeサイズでの値（px）で、描画時にscaleを掛けます
+type layout struct {
+	width, height int
+
+	avatar               box
+	nameX, nameY         int
+	nameSize, handleSize float64
+	handleY              int
+	// 右上のアプリ名
+	brandY int
+
+	panel       box
+	monsterIcon box
+	// モンスター名とHPバーの左端
+	monsterX, monsterNameY int
+	monsterNameSize        float64
+	bar                    box
+	hpY                    int
+	hpSize                 float64
+
+	statsX                         []int
+	statsLabelY, statsValueY       int
+	statsLabelSize, statsValueSize float64
+
+	collection     box
+	collectionCell int
+}
+
+type box struct{ x, y, w, h int }
+
+var layouts = map[string]layout{
+	LayoutOG: {
+		width:           1200,
+		height:          630,
+		avatar:          box{60, 50, 120, 120},
+		nameX:           210,
+		nameY:           105,
+		nameSize:        48,
+		handleY:         150,
+		handleSize:      28,
+		brandY:          80,
+		panel:           box{60, 200, 1080, 190},
+		monsterIcon:     box{85, 225, 140, 140},
+		monsterX:        255,
+		monsterNameY:    262,
+		monsterNameSize: 36,
+		bar:             box{255, 285, 855, 32},
+		hpY:             355,
+		hpSize:          26,
+		statsX:          []int{60, 250, 440},
+		statsLabelY:     440,
+		statsValueY:     490,
+		statsLabelSize:  20,
+		statsValueSize:  44,
+		collection:      box{640, 415, 500, 180},
+		collectionCell:  72,
+	},
+	LayoutSquare: {
+		width:           1080,
+		height:          1080,
+		avatar:          box{60, 60, 160, 160},
+		nameX:           250,
+		nameY:           135,
+		nameSize:        56,
+		handleY:         190,
+		handleSize:      32,
+		brandY:          95,
+		panel:           box{60, 270, 960, 300},
+		monsterIcon:     box{90, 320, 200, 200},
+		monsterX:        320,
+		monsterNameY:    375,
+		monsterNameSize: 44,
+		bar:             box{320, 410, 670, 40},
+		hpY:             500,
+		hpSize:          32,
+		statsX:          []int{60, 380, 700},
+		statsLabelY:     640,
+		statsValueY:     705,
+		statsLabelSize:  24,
+		statsValueSize:  56,
+		collection:      box{60, 760, 960, 270},
+		collectionCell:  96,
+	},
+}
+
+type faceKey struct {
+	bold bool
+	size float64
+}
+
+type canvas struct {
+	r     *Renderer
+	dst   *image.RGBA
+	scale float64
+	theme Theme
+	faces map[faceKey]font.Face
+}
+
+func (c *canvas) close() {
+	for _, face := range c.faces {
+		face.Close()
+	}
+}
+
+func (c *canvas) px(v int) int {
+	return int(float64(v) * c.scale)
+}
+
+func (c *canvas) rect(b box) image.Rectangle {
+	return image.Rect(c.px(b.x), c.px(b.y), c.px(b.x+b.w), c.px(b.y+b.h))
+}
+
+func (c *canvas) face(bold bool, size float64) font.Face {
+	key := faceKey{bold, size}
+	if face, ok := c.faces[key]; ok {
+		return face
+	}
+	f := c.r.regular
+	if bold {
+		f = c.r.bold
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size * c.scale, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		// サイズが正しければ失敗しないため、基本のフォントで代用する
+		face, _ = opentype.NewFace(c.r.regular, &opentype.FaceOptions{Size: 12, DPI: 72})
+	}
+	c.faces[key] = face
+	return face
+}
+
+// text はx, y（ベースライン）からsを描画します。maxWidthを超える場合は末尾を省略します
+func (c *canvas) text(s string, x, y int, bold bool, size float64, col color.Color, maxWidth int) {
+	face := c.face(bold, size)
+	s = truncate(face, s, fixed.I(c.px(maxWidth)))
+	d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(col), Face: face, Dot: fixed.P(c.px(x), c.px(y))}
+	d.DrawString(s)
+}
+
+// textRight は右端をxに揃えてsを描画します
+func (c *canvas) textRight(s string, x, y int, bold bool, size float64, col color.Color) {
+	face := c.face(bold, size)
+	width := font.MeasureString(face, s)
+	d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(col), Face: face, Dot: fixed.Point26_6{X: fixed.I(c.px(x)) - width, Y: fixed.I(c.px(y))}}
+	d.DrawString(s)
+}
+
+// textCenter はcxを中心にsを描画します
+func (c *canvas) textCenter(s string, cx, y int, bold bool, size float64, col color.Color) {
+	face := c.face(bold, size)
+	width := font.MeasureString(face, s)
+	d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(col), Face: face, Dot: fixed.Point26_6{X: fixed.I(c.px(cx)) - width/2, Y: fixed.I(c.px(y))}}
+	d.DrawString(s)
+}
+
+// canRender はフォントがsのすべての文字の字形を持っているかを返します
+func (c *canvas) canRender(s string, bold bool) bool {
+	face := c.face(bold, 12)
+	for _, r := range s {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func truncate(face font.Face, s string, maxWidth fixed.Int26_6) string {
+	if maxWidth <= 0 || font.MeasureString(face, s) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := string(runes) + "..."; font.MeasureString(face, t) <= maxWidth {
+			return t
+		}
+	}
+	return ""
+}
+
+func (c *canvas) drawHeader(l layout, data Data) {
+	name := data.DisplayName
+	if name == "" || !c.canRender(name, true) {
+		name = data.GithubUserName
+	}
+	c.drawImage(c.rect(l.avatar), data.Avatar, true, initial(name))
+
+	nameWidth := l.width - l.nameX - 300
+	c.text(name, l.nameX, l.nameY, true, l.nameSize, c.theme.Text, nameWidth)
+	c.text("@"+data.GithubUserName, l.nameX, l.handleY, false, l.handleSize, c.theme.SubText, nameWidth)
+	c.textRight("Grass Chain", l.width-60, l.brandY, true, l.handleSize, c.theme.Accent)
+}
+
+func (c *canvas) drawCurrentMonster(l layout, current *CurrentMonster) {
+	fillRoundedRect(c.dst, c.rect(l.panel), c.px(16), c.theme.Panel)
+	right := l.panel.x + l.panel.w - 30
+
+	if current == nil {
+		c.drawImage(c.rect(l.monsterIcon), nil, false, "?")
+		c.text("No monster yet", l.monsterX, l.monsterNameY, true, l.monsterNameSize, c.theme.SubText, right-l.monsterX)
+		return
+	}
+
+	c.drawImage(c.rect(l.monsterIcon), current.Icon, false, monsterNumber(current.MonsterId))
+	c.text(c.monsterName(current.Monster), l.monsterX, l.monsterNameY, true, l.monsterNameSize, c.theme.Text, right-l.monsterX)
+
+	// HPは封印までに必要な残りのコントリビューション数
+	remaining := max(current.RequiredContributions-current.ProgressContributions, 0)
+	fillRoundedRect(c.dst, c.rect(l.bar), c.px(l.bar.h/2), c.theme.BarBackground)
+	if current.RequiredContributions > 0 && remaining > 0 {
+		filled := l.bar
+		filled.w = max(l.bar.w*remaining/current.RequiredContributions, l.bar.h)
+		fillRoundedRect(c.dst, c.rect(filled), c.px(l.bar.h/2), c.theme.Bar)
+	}
+
+	percent := 0
+	if current.RequiredContributions > 0 {
+		percent = min(max(current.ProgressContributions, 0)*100/current.RequiredContributions, 100)
+	}
+	c.text(fmt.Sprintf("HP %d / %d", remaining, current.RequiredContributions), l.monsterX, l.hpY, true, l.hpSize, c.theme.Text, 0)
+	c.textRight(fmt.Sprintf("%d%% damaged", percent), l.bar.x+l.bar.w, l.hpY, false, l.hpSize, c.theme.SubText)
+}
+
+func (c *canvas) drawStats(l layout, data Data) {
+	stats := []struct {
+		label string
+		value int64
+	}{
+		{"SEALED", data.SealedMonsterCount},
+		{"STREAK", data.ContinuousSealRecord},
+		{"BEST", data.MaxSealRecord},
+	}
+	for i, stat := range stats {
+		if i >= len(l.statsX) {
+			break
+		}
+		c.text(stat.label, l.statsX[i], l.statsLabelY, false, l.statsLabelSize, c.theme.SubText, 0)
+		c.text(strconv.FormatInt(stat.value, 10), l.statsX[i], l.statsValueY, true, l.statsValueSize, c.theme.Text, 0)
+	}
+}
+
+// コレクションは枠に収まるだけ並べ、収まらない場合は最後のマスに残りの種類数を表示する
+func (c *canvas) drawCollection(l layout, items []CollectionItem) {
+	cell := l.collectionCell
+	columns := max(l.collection.w/cell, 1)
+	rows := max(l.collection.h/cell, 1)
+	capacity := columns * rows
+	if len(items) == 0 {
+		c.text("No sealed monsters yet", l.collection.x, l.collection.y+cell/2, false, l.statsLabelSize, c.theme.SubText, l.collection.w)
+		return
+	}
+
+	shown := items
+	if len(items) > capacity {
+		shown = items[:capacity-1]
+	}
+	icon := cell * 3 / 4
+	for i, item := range shown {
+		x := l.collection.x + (i%columns)*cell
+		y := l.collection.y + (i/columns)*cell
+		c.drawImage(c.rect(box{x, y, icon, icon}), item.Icon, false, monsterNumber(item.MonsterId))
+		if item.Count > 1 {
+			c.drawBadge(fmt.Sprintf("x%d", item.Count), x+icon, y+icon, float64(cell)/4)
+		}
+	}
+	if len(items) > capacity {
+		i := capacity - 1
+		x := l.collection.x + (i%columns)*cell
+		y := l.collection.y + (i/columns)*cell
+		fillRoundedRect(c.dst, c.rect(box{x, y, icon, icon}), c.px(icon/4), c.theme.Panel)
+		c.textCenter(fmt.Sprintf("+%d", len(items)-capacity+1), x+icon/2, y+icon*5/8, true, float64(icon)/3, c.theme.SubText)
+	}
+}
+
+// フォントに字形がない名前（日本語のモンスター名をGoフォントで描画する場合など）は番号で表示する
+func (c *canvas) monsterName(m Monster) string {
+	if m.Name != "" && c.canRender(m.Name, true) {
+		return m.Name
+	}
+	return "No." + m.MonsterId
+}
+
+// drawImage はimgをrに収まるよう拡大縮小して描画します。imgがnilの場合はfallbackの文字を描画します
+func (c *canvas) drawImage(r image.Rectangle, img image.Image, circle bool, fallback string) {
+	radius := r.Dx() / 6
+	if circle {
+		radius = r.Dx() / 2
+	}
+	if img == nil {
+		fillRoundedRect(c.dst, r, radius, c.theme.BarBackground)
+		face := c.face(true, float64(r.Dy())/2/c.scale)
+		width := font.MeasureString(face, fallback)
+		d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(c.theme.SubText), Face: face,
+			Dot: fixed.Point26_6{X: fixed.I(r.Min.X+r.Dx()/2) - width/2, Y: fixed.I(r.Min.Y + r.Dy()*2/3)}}
+		d.DrawString(fallback)
+		return
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Over, nil)
+	draw.DrawMask(c.dst, r, scaled, image.Point{}, &roundedMask{rect: scaled.Bounds(), radius: radius}, image.Point{}, draw.Over)
+}
+
+// drawBadge はアイコンの右下（right, bottom）に重ねて回数を描画します
+func (c *canvas) drawBadge(s string, right, bottom int, size float64) {
+	face := c.face(true, size)
+	width := font.MeasureString(face, s).Ceil()
+	height := c.px(int(size * 1.3))
+	padding := c.px(int(size / 3))
+	r := image.Rect(c.px(right)-width-padding*2+padding, c.px(bottom)-height+padding, c.px(right)+padding, c.px(bottom)+padding)
+	fillRoundedRect(c.dst, r, r.Dy()/2, c.theme.Accent)
+	d := &font.Drawer{Dst: c.dst, Src: image.NewUniform(c.theme.Background), Face: face,
+		Dot: fixed.P(r.Min.X+padding, r.Max.Y-(r.Dy()-face.Metrics().CapHeight.Ceil())/2)}
+	d.DrawString(s)
+}
+
+// アイコンがないモンスターは番号（"002"の場合は"2"）を表示する
+func monsterNumber(id string) string {
+	if n := strings.TrimLeft(id, "0"); n != "" {
+		return n
+	}
+	return initial(id)
+}
+
+func initial(s string) string {
+	for _, r := range s {
+		return string(r)
+	}
+	return "?"
+}
+
+func fillRoundedRect(dst draw.Image, r image.Rectangle, radius int, col color.Color) {
+	mask := &roundedMask{rect: image.Rect(0, 0, r.Dx(), r.Dy()), radius: radius}
+	draw.DrawMask(dst, r, image.NewUniform(col), image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// roundedMask は角の丸い四角形（radiusが幅の半分の場合は円）のマスクです
+type roundedMask struct {
+	rect   image.Rectangle
+	radius int
+}
+
+func (m *roundedMask) ColorModel() color.Model { return color.AlphaModel }
+func (m *roundedMask) Bounds() image.Rectangle { return m.rect }
+
+func (m *roundedMask) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(m.rect)) {
+		return color.Alpha{}
+	}
+	r := m.radius
+	// 角の中心からの距離で判定する（角以外は常に不透明）
+	cx, cy := x, y
+	switch {
+	case x < m.rect.Min.X+r:
+		cx = m.rect.Min.X + r
+	case x >= m.rect.Max.X-r:
+		cx = m.rect.Max.X - r - 1
+	}
+	switch {
+	case y < m.rect.Min.Y+r:
+		cy = m.rect.Min.Y + r
+	case y >= m.rect.Max.Y-r:
+		cy = m.rect.Max.Y - r - 1
+	}
+	dx, dy := x-cx, y-cy
+	if dx*dx+dy*dy > r*r {
+		return color.Alpha{}
+	}
+	return color.Alpha{A: 0xff}
+}
@@ -0,0 +1,218 @@
+package card
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func solid(c color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+var (
+	red  = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	blue = color.RGBA{0x00, 0x00, 0xff, 0xff}
+)
+
+func newRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	r, err := NewRenderer("")
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+	return r
+}
+
+// render は描画したPNGを読み込み直して返す
+func render(t *testing.T, data Data, opts Options) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := newRenderer(t).Render(&buf, data, opts); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("PNGを読み込めません: %v", err)
+	}
+	return img
+}
+
+// assertColor はlargeサイズでの座標(x, y)の色を確かめる
+func assertColor(t *testing.T, img image.Image, scale float64, x, y int, want color.RGBA, what string) {
+	t.Helper()
+	px, py := int(float64(x)*scale), int(float64(y)*scale)
+	if got := color.RGBAModel.Convert(img.At(px, py)).(color.RGBA); got != want {
+		t.Errorf("%s (%d, %d) の色 = %v, want %v", what, px, py, got, want)
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	tests := []struct {
+		layout, size  string
+		width, height int
+	}{
+		{LayoutOG, SizeLarge, 1200, 630},
+		{LayoutOG, SizeMedium, 800, 420},
+		{LayoutOG, SizeSmall, 600, 315},
+		{LayoutSquare, SizeLarge, 1080, 1080},
+		{LayoutSquare, SizeMedium, 720, 720},
+		{LayoutSquare, SizeSmall, 540, 540},
+	}
+	for _, tt := range tests {
+		w, h, err := Dimensions(tt.layout, tt.size)
+		if err != nil || w != tt.width || h != tt.height {
+			t.Errorf("Dimensions(%s, %s) = %d, %d, %v, want %d, %d", tt.layout, tt.size, w, h, err, tt.width, tt.height)
+		}
+	}
+	for _, bad := range [][2]string{{"banner", SizeLarge}, {LayoutOG, "huge"}, {"", ""}} {
+		if _, _, err := Dimensions(bad[0], bad[1]); !errors.Is(err, ErrUnknownLayout) {
+			t.Errorf("Dimensions(%q, %q) error = %v", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestRenderSizes(t *testing.T) {
+	data := Data{
+		DisplayName:    "plmwa",
+		GithubUserName: "plmwa",
+		Current: &CurrentMonster{
+			Monster:               Monster{MonsterId: "002", Name: "Slime"},
+			ProgressContributions: 3,
+			RequiredContributions: 10,
+		},
+		Collection:         []CollectionItem{{Monster: Monster{MonsterId: "001", Name: "Goblin"}, Count: 2}},
+		SealedMonsterCount: 2,
+	}
+	for _, layoutName := range []string{LayoutOG, LayoutSquare} {
+		for _, size := range []string{SizeSmall, SizeMedium, SizeLarge} {
+			for name, theme := range Themes {
+				img := render(t, data, Options{Layout: layoutName, Size: size, Theme: theme})
+				w, h, _ := Dimensions(layoutName, size)
+				if b := img.Bounds(); b.Dx() != w || b.Dy() != h {
+					t.Errorf("%s/%s/%s: 大きさ = %v, want %dx%d", layoutName, size, name, b, w, h)
+				}
+				assertColor(t, img, 1, 0, 0, theme.Background, layoutName+"/"+size+"/"+name+" の背景")
+			}
+		}
+	}
+}
+
+func TestRenderHPBar(t *testing.T) {
+	theme := Themes[ThemeLight]
+	tests := []struct {
+		name     string
+		progress int
+		// バーの左端と右端付近の色
+		left, right color.RGBA
+	}{
+		{"残り6割", 4, theme.Bar, theme.BarBackground},
+		{"ダメージなし", 0, theme.Bar, theme.Bar},
+		{"封印済み", 10, theme.BarBackground, theme.BarBackground},
+		{"超過", 15, theme.BarBackground, theme.BarBackground},
+	}
+	for _, size := range []string{SizeMedium, SizeLarge} {
+		scale := sizeScales[size]
+		for _, tt := range tests {
+			data := Data{GithubUserName: "plmwa", Current: &CurrentMonster{
+				Monster:               Monster{MonsterId: "002"},
+				ProgressContributions: tt.progress,
+				RequiredContributions: 10,
+			}}
+			img := render(t, data, Options{Layout: LayoutOG, Size: size, Theme: theme})
+			// OGのHPバーは (255, 285) から幅855・高さ32
+			assertColor(t, img, scale, 255+100, 285+16, tt.left, size+"/"+tt.name+" のバーの左側")
+			assertColor(t, img, scale, 255+800, 285+16, tt.right, size+"/"+tt.name+" のバーの右側")
+		}
+	}
+}
+
+func TestRenderImages(t *testing.T) {
+	theme := Themes[ThemeDark]
+	data := Data{
+		GithubUserName: "plmwa",
+		Avatar:         solid(red),
+		Current:        &CurrentMonster{Monster: Monster{MonsterId: "002", Icon: solid(blue)}, RequiredContributions: 10},
+		Collection:     []CollectionItem{{Monster: Monster{MonsterId: "001", Icon: solid(blue)}}},
+	}
+	img := render(t, data, Options{Layout: LayoutOG, Size: SizeLarge, Theme: theme})
+
+	// アバター（60, 50, 120x120）は円形に切り抜く
+	assertColor(t, img, 1, 60+60, 50+60, red, "アバターの中心")
+	assertColor(t, img, 1, 60+2, 50+2, theme.Background, "アバターの角")
+	// 育成中のモンスター（85, 225, 140x140）
+	assertColor(t, img, 1, 85+70, 225+70, blue, "モンスターの中心")
+	// コレクションの最初のマス（640, 415、アイコンはマスの3/4）
+	assertColor(t, img, 1, 640+27, 415+27, blue, "コレクションの最初のマス")
+	// 2つ目のマスは空
+	assertColor(t, img, 1, 640+72+27, 415+27, theme.Background, "コレクションの2つ目のマス")
+}
+
+func TestRenderCollectionOverflow(t *testing.T) {
+	theme := Themes[ThemeLight]
+	items := make([]CollectionItem, 20)
+	for i := range items {
+		items[i] = CollectionItem{Monster: Monster{MonsterId: "001", Icon: solid(blue)}}
+	}
+	img := render(t, Data{GithubUserName: "plmwa", Collection: items}, Options{Layout: LayoutOG, Size: SizeLarge, Theme: theme})
+
+	// OGのコレクションは6列x2行。11個を並べ、最後のマスには残りの数（+9）を表示する
+	assertColor(t, img, 1, 640+4*72+27, 415+72+27, blue, "11個目のマス")
+	assertColor(t, img, 1, 640+5*72+27, 415+72+2, theme.Panel, "最後のマス")
+}
+
+func TestRenderWithoutImages(t *testing.T) {
+	// アバター・モンスター・コレクションがなくても描画できる
+	for _, layoutName := range []string{LayoutOG, LayoutSquare} {
+		img := render(t, Data{DisplayName: "プラム", GithubUserName: "plmwa"}, Options{Layout: layoutName, Size: SizeSmall, Theme: Themes[ThemeLight]})
+		if img.Bounds().Empty() {
+			t.Errorf("%s: 画像が空です", layoutName)
+		}
+	}
+}
+
+func TestRenderUnknownLayout(t *testing.T) {
+	r := newRenderer(t)
+	for _, opts := range []Options{{Layout: "banner", Size: SizeLarge}, {Layout: LayoutOG, Size: "huge"}} {
+		if err := r.Render(io.Discard, Data{}, opts); !errors.Is(err, ErrUnknownLayout) {
+			t.Errorf("Render(%+v) error = %v", opts, err)
+		}
+	}
+}
+
+func TestNewRendererFontFile(t *testing.T) {
+	if _, err := NewRenderer(filepath.Join(t.TempDir(), "missing.ttf")); err == nil {
+		t.Error("存在しないフォント: NewRenderer() error = nil")
+	}
+	// 読み込めてもフォントでなければエラー
+	if _, err := NewRenderer("card.go"); err == nil {
+		t.Error("フォントでないファイル: NewRenderer() error = nil")
+	}
+}
+
+func TestMonsterNumber(t *testing.T) {
+	tests := map[string]string{
+		"002":  "2",
+		"120":  "120",
+		"000":  "0",
+		"":     "?",
+		"abc":  "abc",
+		"スライム": "スライム",
+	}
+	for id, want := range tests {
+		if got := monsterNumber(id); got != want {
+			t.Errorf("monsterNumber(%q) = %q, want %q", id, got, want)
+		}
+	}
+	if got := initial("プラム"); got != "プ" {
+		t.Errorf("initial() = %q", got)
+	}
+}
@@ -1,57 +1,444 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	// コンテナなどタイムゾーンのデータベースがない環境でもDEFAULT_TIME_ZONEを読み込めるよう埋め込む
+	_ "time/tzdata"
+
+	"geekcamp-vol10-backend/internal/ratelimit"
 
 	"github.com/joho/godotenv"
 )
 
+// デフォルトで読み込む設定ファイル
+const defaultConfigFile = ".env"
+
 // Config アプリケーションの設定構造体
 type Config struct {
 	// Firebase関連
 	FirebaseCredentials string
-	GCloudProject      string
-	
+	GCloudProject       string
+
+	// 認証関連
+	// trueの場合、/health・/metrics 以外のエンドポイントでFirebaseのIDトークンを検証する
+	// 省略時はtrue。falseはエミュレータなどローカルでの確認にだけ使う
+	AuthEnabled bool
+
 	// GitHub関連
-	GitHubToken    string
-	
+	// 認証ミドルウェアを使わない環境で、IDトークンのgithubAccessTokenの代わりに使うトークン
+	GitHubToken string
+	// GitHub APIの1回の呼び出しの制限時間と、5xx・タイムアウト時の再試行回数
+	GitHubTimeout    time.Duration
+	GitHubMaxRetries int
+	// 連続してこの回数失敗したらGitHub APIの呼び出しを止め、GitHubBreakerCooldownの間は前回の進捗を返す
+	GitHubBreakerFailures int
+	GitHubBreakerCooldown time.Duration
+	// GitHubのコントリビューションの取得結果をキャッシュする時間（0でキャッシュしない）と保存先 (memory, firestore)
+	// memoryの場合はContributionsCacheSize件を超えると最も長く使われていないものから削除する
+	ContributionsCacheTTL   time.Duration
+	ContributionsCacheStore string
+	ContributionsCacheSize  int
+	// タイムゾーンを設定していないユーザーのコントリビューションの履歴を集計するタイムゾーン（IANAの名前）
+	DefaultTimeZone string
+
+	// 共有用カード画像（GET /users/:id/card.png）関連
+	// 日本語を描画するフォントのパス（空の場合は埋め込みのGoフォント）
+	CardFontFile string
+	// アバターを取得してよいホスト（photoURLはユーザーが変更できるため、任意のURLには接続しない）
+	CardAvatarHosts []string
+	// 描画したカードをメモリにキャッシュする枚数
+	CardCacheSize int
+
+	// プッシュ通知関連
+	// 通知の送信方法 (fcm, fake, none)。fakeは送信せずにログに出す（省略時はエミュレータ利用時はfake、それ以外はfcm）
+	Notifier string
+	// 連続記録が途切れそうなユーザーを確認する間隔と、記録が途切れる何時間前に通知するか
+	StreakReminderInterval time.Duration
+	StreakReminderBefore   time.Duration
+
+	// ドメインイベントの配送関連
+	// 配送を待つイベントを確認する間隔と、1回に取り出す件数
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// 配送を試みる回数の上限（超えたイベントはoutboxDeadLettersに移す）と、再試行の間隔の初期値・上限
+	OutboxMaxAttempts int
+	OutboxRetryBase   time.Duration
+	OutboxRetryMax    time.Duration
+	// 1つのイベントの配送にかけられる時間。過ぎると他のサーバーが再び取り出す
+	OutboxLease time.Duration
+
+	// webhook関連
+	// 1回の送信のタイムアウト
+	WebhookTimeout time.Duration
+	// trueの場合はループバック・プライベートアドレスとhttpのURLへの送信を許可する（ローカルでの確認用）
+	WebhookAllowPrivate bool
+
 	// エミュレータ関連
-	FirestoreEmulatorHost string
+	FirestoreEmulatorHost    string
 	FirebaseAuthEmulatorHost string
-	
+
 	// サーバー関連
+	// Hostが空の場合はすべてのインターフェースで待ち受ける
+	Host string
 	Port string
+	// http.Serverのタイムアウトとヘッダーサイズの上限
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// SIGINT/SIGTERMを受けてから処理中のリクエストの完了を待つ時間
+	ShutdownTimeout time.Duration
+
+	// ClientIPの判定にX-Forwarded-Forなどを信頼するプロキシ（IPアドレスまたはCIDR）
+	// 空の場合はヘッダーを信頼せず、接続元のアドレスを使う
+	TrustedProxies []string
+
+	// レート制限関連
+	// バケットの保存先 (memory, firestore)
+	RateLimitStore string
+	RateLimits     RateLimits
+
+	// ヘルスチェック関連
+	// /readyz の依存先ごとのチェックの制限時間と、結果をキャッシュする時間
+	ReadinessTimeout  time.Duration
+	ReadinessCacheTTL time.Duration
+
+	// ログ関連 (debug, info, warn, error)
+	LogLevel string
+
+	// トレースのエクスポーター (none, stdout, otlp)
+	TraceExporter string
+}
+
+// RateLimits はルートごとのレート制限です
+// キーは認証済みのUID（認証されていない場合はIPアドレス）で、Requestsが0のルートは制限しません
+type RateLimits struct {
+	// POST /users
+	CreateUser ratelimit.Limit
+	// GET /contributions/:id（GitHub APIとFirestoreへの書き込みを伴う）
+	Contributions ratelimit.Limit
+	// GET /users/:id/export
+	Export ratelimit.Limit
+	// 上記以外のAPI
+	Default ratelimit.Limit
 }
 
-// LoadConfig 環境変数から設定を読み込みます
-func LoadConfig() *Config {
-	// .envファイルを読み込み（エラーは無視）
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env ファイルが見つかりません（本番環境では正常です）")
+// Load は設定を読み込んで検証します
+// 優先順位は コマンドラインフラグ > 環境変数 > 設定ファイル > デフォルト値 です。
+// 設定ファイルは.env形式で、-config フラグか環境変数 CONFIG_FILE で指定します（省略時は ./.env があれば読み込みます）。
+// 設定ファイルの値は環境変数として読み込まれるため、Firebase SDKが直接参照する
+// FIRESTORE_EMULATOR_HOST なども設定ファイルに書けます
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", "", "設定ファイル(.env形式)のパス（環境変数 CONFIG_FILE）")
+	host := flags.String("host", "", "待ち受けるホスト（環境変数 HOST）")
+	port := flags.String("port", "", "待ち受けるポート（環境変数 PORT）")
+	logLevel := flags.String("log-level", "", "ログの出力レベル: debug, info, warn, error（環境変数 LOG_LEVEL）")
+	traceExporter := flags.String("trace-exporter", "", "トレースの出力先: none, stdout, otlp（環境変数 TRACE_EXPORTER）")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
 	}
 
-	config := &Config{
+	if err := loadConfigFile(*configFile); err != nil {
+		return nil, err
+	}
+
+	env := &envReader{}
+	cfg := &Config{
 		FirebaseCredentials:      os.Getenv("CREDENTIALS"),
-		GCloudProject:           os.Getenv("GCLOUD_PROJECT"),
-		GitHubToken:             os.Getenv("GITHUB_TOKEN"),
-		FirestoreEmulatorHost:   os.Getenv("FIRESTORE_EMULATOR_HOST"),
+		GCloudProject:            os.Getenv("GCLOUD_PROJECT"),
+		AuthEnabled:              env.bool("AUTH_ENABLED", true),
+		GitHubToken:              os.Getenv("GITHUB_TOKEN"),
+		GitHubTimeout:            env.duration("GITHUB_TIMEOUT", 10*time.Second),
+		GitHubMaxRetries:         env.int("GITHUB_MAX_RETRIES", 2),
+		GitHubBreakerFailures:    env.int("GITHUB_BREAKER_FAILURES", 5),
+		GitHubBreakerCooldown:    env.duration("GITHUB_BREAKER_COOLDOWN", 30*time.Second),
+		ContributionsCacheTTL:    env.duration("CONTRIBUTIONS_CACHE_TTL", 5*time.Minute),
+		ContributionsCacheStore:  env.string("CONTRIBUTIONS_CACHE_STORE", "memory"),
+		ContributionsCacheSize:   env.int("CONTRIBUTIONS_CACHE_SIZE", 1000),
+		DefaultTimeZone:          env.string("DEFAULT_TIME_ZONE", "Asia/Tokyo"),
+		CardFontFile:             os.Getenv("CARD_FONT_FILE"),
+		CardAvatarHosts:          env.list("CARD_AVATAR_HOSTS", "avatars.githubusercontent.com"),
+		CardCacheSize:            env.int("CARD_CACHE_SIZE", 200),
+		Notifier:                 os.Getenv("NOTIFIER"),
+		StreakReminderInterval:   env.duration("STREAK_REMINDER_INTERVAL", 15*time.Minute),
+		StreakReminderBefore:     env.duration("STREAK_REMINDER_BEFORE", 4*time.Hour),
+		OutboxPollInterval:       env.duration("OUTBOX_POLL_INTERVAL", 5*time.Second),
+		OutboxBatchSize:          env.int("OUTBOX_BATCH_SIZE", 50),
+		OutboxMaxAttempts:        env.int("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxRetryBase:          env.duration("OUTBOX_RETRY_BASE", 10*time.Second),
+		OutboxRetryMax:           env.duration("OUTBOX_RETRY_MAX", time.Hour),
+		OutboxLease:              env.duration("OUTBOX_LEASE", time.Minute),
+		WebhookTimeout:           env.duration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookAllowPrivate:      env.bool("WEBHOOK_ALLOW_PRIVATE", false),
+		FirestoreEmulatorHost:    os.Getenv("FIRESTORE_EMULATOR_HOST"),
 		FirebaseAuthEmulatorHost: os.Getenv("FIREBASE_AUTH_EMULATOR_HOST"),
-		Port:                    getEnvWithDefault("PORT", "8081"),
+		Host:                     os.Getenv("HOST"),
+		Port:                     env.string("PORT", "8081"),
+		ReadTimeout:              env.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout:        env.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:             env.duration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:              env.duration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:           env.int("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:          env.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		TrustedProxies:           env.list("TRUSTED_PROXIES"),
+		RateLimitStore:           env.string("RATE_LIMIT_STORE", "memory"),
+		RateLimits: RateLimits{
+			CreateUser:    env.rateLimit("RATE_LIMIT_CREATE_USER", "5/1m"),
+			Contributions: env.rateLimit("RATE_LIMIT_CONTRIBUTIONS", "10/1m"),
+			Export:        env.rateLimit("RATE_LIMIT_EXPORT", "5/1m"),
+			Default:       env.rateLimit("RATE_LIMIT_DEFAULT", "60/1m"),
+		},
+		ReadinessTimeout:  env.duration("READINESS_TIMEOUT", 3*time.Second),
+		ReadinessCacheTTL: env.duration("READINESS_CACHE_TTL", 10*time.Second),
+		LogLevel:          env.string("LOG_LEVEL", "info"),
+		TraceExporter:     env.string("TRACE_EXPORTER", "none"),
+	}
+
+	// 明示的に指定されたフラグだけで上書きする
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Host = *host
+		case "port":
+			cfg.Port = *port
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "trace-exporter":
+			cfg.TraceExporter = *traceExporter
+		}
+	})
+
+	// FCMにはエミュレータがないため、エミュレータ利用時は送信しない
+	if cfg.Notifier == "" {
+		cfg.Notifier = "fcm"
+		if cfg.IsEmulatorMode() {
+			cfg.Notifier = "fake"
+		}
 	}
 
-	return config
+	if err := errors.Join(append(env.errs, cfg.Validate())...); err != nil {
+		return nil, fmt.Errorf("設定が正しくありません:\n%w", err)
+	}
+	return cfg, nil
 }
 
-// getEnvWithDefault 環境変数を取得し、存在しない場合はデフォルト値を返します
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Validate は設定値を検証し、問題をすべてまとめたエラーを返します
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.IsEmulatorMode() {
+		if c.GCloudProject == "" {
+			errs = append(errs, errors.New("エミュレータ利用時は GCLOUD_PROJECT が必要です"))
+		}
+	} else {
+		if c.FirebaseCredentials == "" {
+			errs = append(errs, errors.New("本番環境利用時は CREDENTIALS（サービスアカウントキーのパス）が必要です。エミュレータを使う場合は FIRESTORE_EMULATOR_HOST を設定してください"))
+		} else if _, err := os.Stat(c.FirebaseCredentials); err != nil {
+			errs = append(errs, fmt.Errorf("CREDENTIALS のファイルを読み込めません: %w", err))
+		}
 	}
-	return defaultValue
+
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		errs = append(errs, fmt.Errorf("PORT は1〜65535の数値で指定してください: %q", c.Port))
+	}
+	if c.GitHubMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("GITHUB_MAX_RETRIES は0以上で指定してください: %d", c.GitHubMaxRetries))
+	}
+	if c.GitHubBreakerFailures <= 0 {
+		errs = append(errs, fmt.Errorf("GITHUB_BREAKER_FAILURES は正の数で指定してください: %d", c.GitHubBreakerFailures))
+	}
+	if c.ContributionsCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("CONTRIBUTIONS_CACHE_TTL は0以上で指定してください: %s", c.ContributionsCacheTTL))
+	}
+	if !oneOf(c.ContributionsCacheStore, "memory", "firestore") {
+		errs = append(errs, fmt.Errorf("CONTRIBUTIONS_CACHE_STORE は memory, firestore のいずれかで指定してください: %q", c.ContributionsCacheStore))
+	}
+	if c.ContributionsCacheSize <= 0 {
+		errs = append(errs, fmt.Errorf("CONTRIBUTIONS_CACHE_SIZE は正の数で指定してください: %d", c.ContributionsCacheSize))
+	}
+	if _, err := time.LoadLocation(c.DefaultTimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("DEFAULT_TIME_ZONE はIANAのタイムゾーン名（Asia/Tokyo など）で指定してください: %q", c.DefaultTimeZone))
+	}
+	if c.CardFontFile != "" {
+		if _, err := os.Stat(c.CardFontFile); err != nil {
+			errs = append(errs, fmt.Errorf("CARD_FONT_FILE のファイルを読み込めません: %w", err))
+		}
+	}
+	if c.CardCacheSize <= 0 {
+		errs = append(errs, fmt.Errorf("CARD_CACHE_SIZE は正の数で指定してください: %d", c.CardCacheSize))
+	}
+	if !oneOf(c.Notifier, "fcm", "fake", "none") {
+		errs = append(errs, fmt.Errorf("NOTIFIER は fcm, fake, none のいずれかで指定してください: %q", c.Notifier))
+	}
+	if c.StreakReminderBefore >= 24*time.Hour {
+		errs = append(errs, fmt.Errorf("STREAK_REMINDER_BEFORE は24時間未満で指定してください: %s", c.StreakReminderBefore))
+	}
+	// 1件ずつトランザクションで取り出すため、1回の確認が長くなりすぎないよう上限を設ける
+	if c.OutboxBatchSize <= 0 || c.OutboxBatchSize > 500 {
+		errs = append(errs, fmt.Errorf("OUTBOX_BATCH_SIZE は1〜500で指定してください: %d", c.OutboxBatchSize))
+	}
+	if c.OutboxMaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("OUTBOX_MAX_ATTEMPTS は正の数で指定してください: %d", c.OutboxMaxAttempts))
+	}
+	if c.OutboxRetryBase > c.OutboxRetryMax {
+		errs = append(errs, fmt.Errorf("OUTBOX_RETRY_BASE は OUTBOX_RETRY_MAX 以下で指定してください: %s > %s", c.OutboxRetryBase, c.OutboxRetryMax))
+	}
+	// webhookの送信は1つのイベントの配送の中で行うため
+	if c.WebhookTimeout >= c.OutboxLease {
+		errs = append(errs, fmt.Errorf("WEBHOOK_TIMEOUT は OUTBOX_LEASE より短く指定してください: %s >= %s", c.WebhookTimeout, c.OutboxLease))
+	}
+	if c.MaxHeaderBytes <= 0 {
+		errs = append(errs, fmt.Errorf("HTTP_MAX_HEADER_BYTES は正の数で指定してください: %d", c.MaxHeaderBytes))
+	}
+	if !oneOf(c.LogLevel, "debug", "info", "warn", "warning", "error") {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL は debug, info, warn, error のいずれかで指定してください: %q", c.LogLevel))
+	}
+	if !oneOf(c.TraceExporter, "none", "stdout", "otlp") {
+		errs = append(errs, fmt.Errorf("TRACE_EXPORTER は none, stdout, otlp のいずれかで指定してください: %q", c.TraceExporter))
+	}
+	if !oneOf(c.RateLimitStore, "memory", "firestore") {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE は memory, firestore のいずれかで指定してください: %q", c.RateLimitStore))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES にはIPアドレスかCIDRを指定してください: %q", proxy))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Addr はhttp.Serverに渡す待ち受けアドレス (host:port) を返します
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Host, c.Port)
 }
 
 // IsEmulatorMode エミュレータモードかどうかを判定します
 func (c *Config) IsEmulatorMode() bool {
 	return c.FirestoreEmulatorHost != "" || c.FirebaseAuthEmulatorHost != ""
 }
+
+// 設定ファイルを読み込み、まだ設定されていない環境変数として反映します
+// 明示的に指定されたファイルが存在しない場合はエラー、デフォルトの.envが存在しない場合は何もしません
+func loadConfigFile(path string) error {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	if err := godotenv.Load(path); err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("設定ファイル %s を読み込めません: %w", path, err)
+	}
+	return nil
+}
+
+// envReader は環境変数を型付きで読み込み、不正な値をエラーとして蓄積します
+type envReader struct {
+	errs []error
+}
+
+// string 環境変数を取得し、存在しない場合はデフォルト値を返します
+func (r *envReader) string(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// duration 環境変数を "30s" のような形式で取得し、存在しない場合はデフォルト値を返します
+func (r *envReader) duration(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		r.errs = append(r.errs, fmt.Errorf("%s は \"30s\" のような正の時間で指定してください: %q", key, value))
+		return defaultValue
+	}
+	return d
+}
+
+// bool 環境変数を真偽値 (true/false/1/0) として取得し、存在しない場合はデフォルト値を返します
+func (r *envReader) bool(key string, defaultValue bool) bool {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s は true または false で指定してください: %q", key, value))
+		return defaultValue
+	}
+	return b
+}
+
+// int 環境変数を整数として取得し、存在しない場合はデフォルト値を返します
+func (r *envReader) int(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s は整数で指定してください: %q", key, value))
+		return defaultValue
+	}
+	return n
+}
+
+// list 環境変数をカンマ区切りのリストとして取得し、存在しない場合はデフォルト値を返します
+func (r *envReader) list(key string, defaultValues ...string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if values == nil {
+		return defaultValues
+	}
+	return values
+}
+
+// rateLimit 環境変数を "10/1m" のような "<回数>/<期間>" 形式で取得し、存在しない場合はデフォルト値を返します
+// "off" を指定すると制限しません
+func (r *envReader) rateLimit(key, defaultValue string) ratelimit.Limit {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		value = defaultValue
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s は \"10/1m\" のような形式か off で指定してください: %q", key, value))
+		return ratelimit.Limit{}
+	}
+	return limit
+}
+
+func oneOf(value string, candidates ...string) bool {
+	for _, c := range candidates {
+		if strings.EqualFold(value, c) {
+			return true
+		}
+	}
+	return false
+}
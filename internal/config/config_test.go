@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+// エミュレータモードの最小限の設定（Loadの検証を通すため）
+func setEmulatorEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("GCLOUD_PROJECT", "config-test")
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "127.0.0.1:8080")
+}
+
+func TestLoadAuthEnabled(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		// 省略時は認証を有効にする
+		{value: "", want: true},
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "0", want: false},
+		{value: "yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		setEmulatorEnv(t)
+		t.Setenv("AUTH_ENABLED", tt.value)
+		cfg, err := Load(nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("AUTH_ENABLED=%q: Load() error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.AuthEnabled != tt.want {
+			t.Errorf("AUTH_ENABLED=%q: AuthEnabled = %v, want %v", tt.value, cfg.AuthEnabled, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package docid はFirestoreのドキュメントIDの判定を提供します
+// validation（リクエストの検証）とrepositories（カーソルの検証）の両方から使うため、他のパッケージに依存しません
+package docid
+
+import "strings"
+
+// Valid はFirestoreのドキュメントIDとして安全に使えるかを判定します
+// 空文字やスラッシュを含む値をDoc()に渡すと、パニックや意図しないパスへのアクセスになるため弾きます
+func Valid(s string) bool {
+	if s == "" || len(s) > 1500 {
+		return false
+	}
+	if s == "." || s == ".." || strings.Contains(s, "/") {
+		return false
+	}
+	if strings.HasPrefix(s, "__") && strings.HasSuffix(s, "__") {
+		return false
+	}
+	return true
+}
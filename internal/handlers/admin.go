@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /admin/users の件数（limit省略時）
+const defaultAdminUsersLimit = 50
+
+// AdminHandler は管理者APIを処理します
+// ルートではmiddleware.RequireAdminで管理者のカスタムクレームを確認してください
+type AdminHandler struct {
+	admin *services.AdminService
+	// 強制同期はユーザーの同期と同じ処理で行う
+	contributions *ContributionHandler
+}
+
+// NewAdminHandler はAdminHandlerを作成します
+func NewAdminHandler(admin *services.AdminService, contributions *ContributionHandler) *AdminHandler {
+	return &AdminHandler{
+		admin:         admin,
+		contributions: contributions,
+	}
+}
+
+// 操作の理由は必須にして、後からログで追えるようにする
+type adminActionRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+func adminAudit(c *gin.Context, reason string) services.AdminAudit {
+	return services.AdminAudit{
+		AdminUID: c.GetString(middleware.ContextKeyFirebaseUID),
+		Reason:   reason,
+	}
+}
+
+// ユーザーの一覧を取得するハンドラー
+// qを指定した場合はGitHubユーザー名の前方一致で絞り込みます
+// GET /admin/users?limit=&cursor=&q=
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var query struct {
+		Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+		Cursor string `form:"cursor"`
+		Q      string `form:"q" binding:"omitempty,max=39"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultAdminUsersLimit
+	}
+
+	page, err := h.admin.ListUsers(c.Request.Context(), repositories.UsersQuery{
+		Limit:                limit,
+		GithubUserNamePrefix: query.Q,
+		Cursor:               query.Cursor,
+	})
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "cursor", Rule: "cursor", Message: "カーソルが正しくありません"}})
+		return
+	}
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	res := AdminUsersResponse{
+		Users:      make([]UserProfileResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+	for _, user := range page.Users {
+		res.Users = append(res.Users, newUserProfileResponse(user))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// ユーザーの状態をまとめて取得するハンドラー
+// GET /admin/users/:id
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	id := c.Param("id")
+
+	state, err := h.admin.GetUserState(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminUserStateResponse(*state))
+}
+
+// 進捗をリセットするハンドラー
+// POST /admin/users/:id/reset-progress
+func (h *AdminHandler) ResetProgress(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		adminActionRequest
+		// trueの場合は封印済みモンスターと最高記録も削除する
+		DeleteSealedMonsters bool `json:"deleteSealedMonsters"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	current, err := h.admin.ResetProgress(ctx, id, req.DeleteSealedMonsters, adminAudit(c, req.Reason))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminCurrentMonsterResponse(current, nil))
+}
+
+// コントリビューションを付与（負の値で取り消し）するハンドラー
+// POST /admin/users/:id/contributions
+func (h *AdminHandler) AdjustContributions(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		adminActionRequest
+		// requiredで0を拒否する
+		Delta int `json:"delta" binding:"required,min=-10000,max=10000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	current, sealed, err := h.admin.AdjustContributions(ctx, id, req.Delta, adminAudit(c, req.Reason))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminCurrentMonsterResponse(current, sealed))
+}
+
+// 育成中のモンスターを置き換えるハンドラー
+// PUT /admin/users/:id/current-monster
+func (h *AdminHandler) ReassignMonster(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		adminActionRequest
+		MonsterId             string `json:"monsterId" binding:"required,firestore_id"`
+		ProgressContributions int    `json:"progressContributions" binding:"gte=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	current, err := h.admin.ReassignMonster(ctx, id, req.MonsterId, req.ProgressContributions, adminAudit(c, req.Reason))
+	if errors.Is(err, repositories.ErrProgressExceedsRequired) {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "progressContributions", Rule: "lt_required", Message: err.Error()}})
+		return
+	}
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminCurrentMonsterResponse(current, nil))
+}
+
+// GitHubのコントリビューションをキャッシュを使わずに同期するハンドラー
+// レスポンスは GET /contributions/:id と同じです。GitHubのトークンはmiddleware.TargetUserGitHubTokenで設定した対象のユーザーのものを使います
+// POST /admin/users/:id/sync
+func (h *AdminHandler) ForceSync(c *gin.Context) {
+	id := c.Param("id")
+	var req adminActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	h.admin.LogForceSync(c.Request.Context(), id, adminAudit(c, req.Reason))
+	c.Request = c.Request.WithContext(services.WithFreshContributions(c.Request.Context()))
+	h.contributions.GetContribution(c)
+}
+
+func newAdminCurrentMonsterResponse(current models.CurrentMonster, sealed *models.SealedMonster) AdminCurrentMonsterResponse {
+	res := AdminCurrentMonsterResponse{CurrentMonster: newCurrentMonsterResponse(current)}
+	if sealed != nil {
+		res.SealedMonster = &newSealedMonsterResponses([]models.SealedMonster{*sealed})[0]
+	}
+	return res
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// メモリ上のAdminUsersとAdminContributions
+type fakeAdmin struct {
+	// 修正が返すエラー
+	err error
+
+	lastQuery    repositories.UsersQuery
+	deleteSealed bool
+	delta        int
+	called       bool
+}
+
+func (f *fakeAdmin) ListUsers(_ context.Context, q repositories.UsersQuery) (*repositories.UsersPage, error) {
+	f.lastQuery = q
+	if q.Cursor == "broken" {
+		return nil, repositories.ErrInvalidCursor
+	}
+	return &repositories.UsersPage{Users: []models.User{{FirebaseId: "user-1", GithubUserName: "plmwa"}}, NextCursor: "next"}, nil
+}
+
+func (f *fakeAdmin) GetUserDocument(context.Context, string) (map[string]interface{}, error) {
+	return map[string]interface{}{"githubUserName": "plmwa"}, nil
+}
+
+func (f *fakeAdmin) ListDeviceTokens(context.Context, string) ([]models.DeviceToken, error) {
+	return []models.DeviceToken{{DeviceId: "device-1", Token: "fcm-secret-token", Platform: "ios"}}, nil
+}
+
+func (f *fakeAdmin) ListWebhooks(context.Context, string) ([]models.Webhook, error) {
+	return []models.Webhook{{WebhookId: "webhook-1", URL: "https://example.com/hook", Secret: "whsec-secret"}}, nil
+}
+
+func (f *fakeAdmin) ResetProgress(_ context.Context, _ string, deleteSealed bool) (models.CurrentMonster, error) {
+	f.called, f.deleteSealed = true, deleteSealed
+	if f.err != nil {
+		return models.CurrentMonster{}, f.err
+	}
+	return models.CurrentMonster{MonsterId: "001", RequiredContributions: 30}, nil
+}
+
+func (f *fakeAdmin) AdjustContributions(_ context.Context, _ string, delta int) (models.CurrentMonster, *models.SealedMonster, error) {
+	f.called, f.delta = true, delta
+	if f.err != nil {
+		return models.CurrentMonster{}, nil, f.err
+	}
+	return models.CurrentMonster{MonsterId: "002", ProgressContributions: 5, RequiredContributions: 50},
+		&models.SealedMonster{MonsterId: "001", MonsterName: "スライム"}, nil
+}
+
+func (f *fakeAdmin) ReassignCurrentMonster(_ context.Context, _, monsterId string, progress int) (models.CurrentMonster, error) {
+	f.called = true
+	if f.err != nil {
+		return models.CurrentMonster{}, f.err
+	}
+	return models.CurrentMonster{MonsterId: monsterId, ProgressContributions: progress, RequiredContributions: 50}, nil
+}
+
+func newAdminRouter() (*gin.Engine, *fakeAdmin) {
+	users := newMemoryUsers()
+	users.users["user-1"] = &models.User{FirebaseId: "user-1", GithubUserName: "plmwa"}
+	admin := &fakeAdmin{}
+	events := outbox.NewDispatcher(nil, outbox.DispatcherConfig{})
+	h := NewAdminHandler(services.NewAdminService(admin, admin, services.NewUserService(users, fakeViewers{}), events), nil)
+
+	r := gin.New()
+	r.Use(apperrors.Middleware())
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ContextKeyFirebaseUID, "admin-1")
+		c.Set(middleware.ContextKeyAdmin, true)
+	})
+	r.GET("/admin/users", h.ListUsers)
+	r.GET("/admin/users/:id", h.GetUser)
+	r.POST("/admin/users/:id/reset-progress", h.ResetProgress)
+	r.POST("/admin/users/:id/contributions", h.AdjustContributions)
+	r.PUT("/admin/users/:id/current-monster", h.ReassignMonster)
+	return r, admin
+}
+
+// detailFields はバリデーションエラーのフィールド名を返す
+func detailFields(got map[string]interface{}) []string {
+	details, _ := got["details"].([]interface{})
+	var fields []string
+	for _, d := range details {
+		if detail, ok := d.(map[string]interface{}); ok {
+			fields = append(fields, detail["field"].(string))
+		}
+	}
+	return fields
+}
+
+func TestAdminActionsRequireReason(t *testing.T) {
+	tests := []struct{ method, path, body string }{
+		{http.MethodPost, "/admin/users/user-1/reset-progress", `{}`},
+		{http.MethodPost, "/admin/users/user-1/contributions", `{"delta":5}`},
+		{http.MethodPut, "/admin/users/user-1/current-monster", `{"monsterId":"002"}`},
+	}
+	for _, tt := range tests {
+		r, admin := newAdminRouter()
+		w, got := serve(r, tt.method, tt.path, tt.body)
+		if w.Code != http.StatusBadRequest || strings.Join(detailFields(got), ",") != "reason" {
+			t.Errorf("%s %s = %d %s", tt.method, tt.path, w.Code, w.Body)
+		}
+		if admin.called {
+			t.Errorf("%s %s: 理由がないのに変更しました", tt.method, tt.path)
+		}
+	}
+}
+
+func TestAdminResetProgress(t *testing.T) {
+	r, admin := newAdminRouter()
+	w, got := serve(r, http.MethodPost, "/admin/users/user-1/reset-progress", `{"reason":"問い合わせ #42","deleteSealedMonsters":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST = %d %s", w.Code, w.Body)
+	}
+	if !admin.deleteSealed {
+		t.Error("deleteSealedMonstersが渡されていません")
+	}
+	current, _ := got["currentMonster"].(map[string]interface{})
+	if current["monsterId"] != "001" || current["remainingHP"] != float64(30) {
+		t.Errorf("currentMonster = %v", current)
+	}
+	if _, ok := got["sealedMonster"]; ok {
+		t.Errorf("リセットのレスポンスにsealedMonsterがあります: %v", got)
+	}
+}
+
+func TestAdminResetProgressUserNotFound(t *testing.T) {
+	r, admin := newAdminRouter()
+	admin.err = apperrors.ErrUserNotFound
+	w, got := serve(r, http.MethodPost, "/admin/users/user-2/reset-progress", `{"reason":"問い合わせ #42"}`)
+	if w.Code != http.StatusNotFound || got["code"] != "user_not_found" {
+		t.Errorf("POST = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminAdjustContributions(t *testing.T) {
+	r, admin := newAdminRouter()
+
+	// 0と範囲外は拒否する
+	for _, delta := range []string{"0", "10001", "-10001"} {
+		w, got := serve(r, http.MethodPost, "/admin/users/user-1/contributions", `{"reason":"補填","delta":`+delta+`}`)
+		if w.Code != http.StatusBadRequest || strings.Join(detailFields(got), ",") != "delta" {
+			t.Errorf("delta=%s: POST = %d %s", delta, w.Code, w.Body)
+		}
+	}
+	if admin.called {
+		t.Fatal("不正なdeltaで変更しました")
+	}
+
+	w, got := serve(r, http.MethodPost, "/admin/users/user-1/contributions", `{"reason":"補填","delta":-3}`)
+	if w.Code != http.StatusOK || admin.delta != -3 {
+		t.Fatalf("POST = %d %s (delta %d)", w.Code, w.Body, admin.delta)
+	}
+	sealed, _ := got["sealedMonster"].(map[string]interface{})
+	if sealed["monsterId"] != "001" || sealed["monsterName"] != "スライム" {
+		t.Errorf("sealedMonster = %v", got["sealedMonster"])
+	}
+}
+
+func TestAdminReassignMonster(t *testing.T) {
+	r, admin := newAdminRouter()
+	body := `{"reason":"問い合わせ #42","monsterId":"003","progressContributions":60}`
+
+	admin.err = repositories.ErrProgressExceedsRequired
+	w, got := serve(r, http.MethodPut, "/admin/users/user-1/current-monster", body)
+	if w.Code != http.StatusBadRequest || strings.Join(detailFields(got), ",") != "progressContributions" {
+		t.Errorf("必要数以上: PUT = %d %s", w.Code, w.Body)
+	}
+
+	admin.err = apperrors.ErrMonsterNotFound
+	w, got = serve(r, http.MethodPut, "/admin/users/user-1/current-monster", body)
+	if w.Code != http.StatusNotFound || got["code"] != "monster_not_found" {
+		t.Errorf("存在しないモンスター: PUT = %d %s", w.Code, w.Body)
+	}
+
+	admin.err = nil
+	w, got = serve(r, http.MethodPut, "/admin/users/user-1/current-monster", `{"reason":"問い合わせ #42","monsterId":"003","progressContributions":4}`)
+	current, _ := got["currentMonster"].(map[string]interface{})
+	if w.Code != http.StatusOK || current["monsterId"] != "003" || current["progressContributions"] != float64(4) {
+		t.Errorf("PUT = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminListUsers(t *testing.T) {
+	r, admin := newAdminRouter()
+
+	w, got := serve(r, http.MethodGet, "/admin/users?q=plm", "")
+	if w.Code != http.StatusOK || got["nextCursor"] != "next" {
+		t.Fatalf("GET = %d %s", w.Code, w.Body)
+	}
+	if admin.lastQuery.Limit != defaultAdminUsersLimit || admin.lastQuery.GithubUserNamePrefix != "plm" {
+		t.Errorf("条件 = %+v", admin.lastQuery)
+	}
+
+	w, got = serve(r, http.MethodGet, "/admin/users?cursor=broken", "")
+	if w.Code != http.StatusBadRequest || strings.Join(detailFields(got), ",") != "cursor" {
+		t.Errorf("不正なカーソル: GET = %d %s", w.Code, w.Body)
+	}
+	w, _ = serve(r, http.MethodGet, "/admin/users?limit=101", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("limit=101: GET = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminGetUserOmitsSecrets(t *testing.T) {
+	r, _ := newAdminRouter()
+
+	w, got := serve(r, http.MethodGet, "/admin/users/user-1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d %s", w.Code, w.Body)
+	}
+	for _, secret := range []string{"fcm-secret-token", "whsec-secret"} {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("レスポンスに %s が含まれています: %s", secret, w.Body)
+		}
+	}
+	if devices, _ := got["devices"].([]interface{}); len(devices) != 1 {
+		t.Errorf("devices = %v", got["devices"])
+	}
+	if webhooks, _ := got["webhooks"].([]interface{}); len(webhooks) != 1 {
+		t.Errorf("webhooks = %v", got["webhooks"])
+	}
+
+	w, got = serve(r, http.MethodGet, "/admin/users/user-2", "")
+	if w.Code != http.StatusNotFound || got["code"] != "user_not_found" {
+		t.Errorf("存在しないユーザー: GET = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/card"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CardHandler はユーザーの共有用カード画像を処理します
+type CardHandler struct {
+	cards *services.CardService
+}
+
+// NewCardHandler はCardHandlerを作成します
+func NewCardHandler(cards *services.CardService) *CardHandler {
+	return &CardHandler{
+		cards: cards,
+	}
+}
+
+// 共有用カード画像をPNGで返すハンドラー
+// GET /users/:id/card.png?layout=og|square&size=small|medium|large&theme=light|dark
+func (h *CardHandler) GetCard(c *gin.Context) {
+	id := c.Param("id")
+
+	var query struct {
+		Layout string `form:"layout" binding:"omitempty,oneof=og square"`
+		Size   string `form:"size" binding:"omitempty,oneof=small medium large"`
+		Theme  string `form:"theme" binding:"omitempty,oneof=light dark"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	opts := services.CardOptions{Layout: query.Layout, Size: query.Size, Theme: query.Theme}
+	if opts.Layout == "" {
+		opts.Layout = card.LayoutOG
+	}
+	if opts.Size == "" {
+		opts.Size = card.SizeLarge
+	}
+
+	ctx := c.Request.Context()
+	state, err := h.cards.State(ctx, id)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	// ユーザーの状態が変わるまでは同じ画像を使わせる（毎回ETagで確認させ、変わっていなければ描画しない）
+	etag := state.ETag(opts)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	png, err := h.cards.Render(ctx, state, opts)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -2,69 +2,163 @@ package handlers
 
 import (
 	"context"
-	"net/http"
-	"os"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/outbox"
 	"geekcamp-vol10-backend/internal/services"
 	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/validation"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
+// ContributionHandler はGitHubのコントリビューションの同期を処理します
+type ContributionHandler struct {
+	users         *repositories.UserRepository
+	contributions *repositories.ContributionRepository
+	github        *services.ContributionService
+	history       *services.ContributionHistoryService
+	// 保存したドメインイベントの配送
+	events *outbox.Dispatcher
+
+	// 処理中のコントリビューション同期
+	// サーバー終了時にFirestoreを閉じる前に、すべての同期が書き込みを終えるのを待つために使う
+	inflight sync.WaitGroup
+}
+
+// NewContributionHandler はContributionHandlerを作成します
+func NewContributionHandler(users *repositories.UserRepository, contributions *repositories.ContributionRepository, github *services.ContributionService, history *services.ContributionHistoryService, events *outbox.Dispatcher) *ContributionHandler {
+	return &ContributionHandler{
+		users:         users,
+		contributions: contributions,
+		github:        github,
+		history:       history,
+		events:        events,
+	}
+}
+
+// WaitForSyncs は処理中のコントリビューション同期がすべて完了するまで待ちます
+// ctxがキャンセルされた場合は待つのをやめてctx.Err()を返します
+func (h *ContributionHandler) WaitForSyncs(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GET /contributions/:id のレスポンス
+// GitHubに接続できない場合は前回の進捗をstale=trueで返す
+type contributionResponse struct {
+	CurrentMonsterResponse
+	Stale bool `json:"stale"`
+	// staleの理由（github_unavailable, github_rate_limited などエラーレスポンスのcode）
+	StaleReason string `json:"staleReason,omitempty"`
+}
 
 // githubのコントリビューション数を取得するハンドラー
 // GET /contributions/:id
-func GetContribution(c *gin.Context) {
+func (h *ContributionHandler) GetContribution(c *gin.Context) {
+	h.inflight.Add(1)
+	defer h.inflight.Done()
+
 	id := c.Param("id")
-	// 一旦アクセストークンとユーザー名はenvから
-	err := godotenv.Load()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load environment variables"})
-		return
-	}
 
 	// UserNameは:idを用いてDBから抽出する
-	// アクセストークンはHeaderの中のAuthorizationから取得する
-	ctx := context.Background()
-	githubUserName, err := repositories.GetGitHubUserNameByID(ctx, id)
+	// アクセストークンはMiddlewareがContextに設定したものを使う
+	// 書き込みの途中でクライアントが切断しても同期が中途半端にならないよう、キャンセルは引き継がない
+	// リクエストIDなどの値はログのために引き継ぐ
+	ctx := context.WithoutCancel(c.Request.Context())
+	githubUserName, err := h.contributions.GetGitHubUserNameByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "ユーザーが見つかりません"})
+		apperrors.Abort(c, err)
 		return
 	}
-	githubToken := os.Getenv("GITHUB_TOKEN")
-	// tokenはc.Contextの中に入っているので、そこから取得する
-	// Middlewareで設定した値を取得
-    /*
-	accessToken, exists := c.Get("githubAccessToken")
-    if !exists {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "アクセストークンがContextにありません"})
-        return
-    }
-	*/
+	// AuthMiddlewareがIDトークンから、無効な環境ではGitHubTokenFallbackが設定のGITHUB_TOKENを設定する
+	githubToken := c.GetString(middleware.ContextKeyGitHubAccessToken)
 
 	// GitHubのAPIを叩くための準備
 	if githubUserName == "" || githubToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "GitHubのユーザー名とトークンは必須です"})
+		apperrors.Abort(c, apperrors.ErrGitHubCredentials)
 		return
 	}
 
 	// サービス層を呼び出してコントリビューション数を取得する
-	githubData, err := services.GetContributions(githubUserName, githubToken)
+	githubData, err := h.github.GetContributions(ctx, githubUserName, githubToken)
 	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "コントリビューションの取得に失敗しました", // エラーメッセージを少し具体的に
-        })
-        return
-    }
+		// GitHubの障害・レート制限の場合は同期を諦め、前回反映した進捗を返す
+		if services.IsGitHubTemporaryError(err) {
+			if current, cmErr := h.users.GetCurrentMonster(ctx, id); cmErr == nil && current != nil {
+				slog.WarnContext(ctx, "GitHubに接続できないため前回の進捗を返します", "user_id", id, "error", err)
+				metrics.IncContributionSync(metrics.SyncOutcomeStale)
+				c.JSON(http.StatusOK, contributionResponse{CurrentMonsterResponse: newCurrentMonsterResponse(*current), Stale: true, StaleReason: apperrors.Code(err)})
+				return
+			}
+		}
+		apperrors.Abort(c, err)
+		return
+	}
 
-	currentMonster, err := repositories.SaveContribution(id, githubData)
+	currentMonster, err := h.contributions.SaveContribution(ctx, id, githubData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "内部エラーが発生しました",
-		})
+		apperrors.Abort(c, err)
 		return
 	}
-	// githubDataの中身をlogに出力
-	fmt.Printf("GitHub Data: %+v\n", githubData)
-	c.JSON(http.StatusOK, currentMonster)
-}
\ No newline at end of file
+	// 履歴はグラフ表示用のため、保存に失敗しても進捗の反映は成功として返す（次の同期で保存し直す）
+	if err := h.history.Record(ctx, id, githubData); err != nil {
+		slog.WarnContext(ctx, "日ごとのコントリビューションの保存に失敗しました", "user_id", id, "error", err)
+	}
+	// 封印の通知などは同期と同じトランザクションで保存したイベントから行う。次の確認を待たずにすぐ配送させる
+	h.events.Kick()
+	slog.DebugContext(ctx, "GitHubのコントリビューションを反映しました",
+		"user_id", id,
+		"repositories", len(githubData.Data.User.ContributionsCollection.CommitContributionsByRepository),
+	)
+	c.JSON(http.StatusOK, contributionResponse{CurrentMonsterResponse: newCurrentMonsterResponse(currentMonster)})
+}
+
+// コントリビューションの履歴を取得するハンドラー
+// GET /users/:id/contributions/history?from=&to=&granularity=day|week|month
+func (h *ContributionHandler) GetContributionHistory(c *gin.Context) {
+	id := c.Param("id")
+
+	var query struct {
+		From        string `form:"from" binding:"omitempty,date"`
+		To          string `form:"to" binding:"omitempty,date"`
+		Granularity string `form:"granularity" binding:"omitempty,oneof=day week month"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	if query.Granularity == "" {
+		query.Granularity = services.GranularityDay
+	}
+
+	history, err := h.history.History(c.Request.Context(), id, services.ContributionHistoryQuery{
+		From:        query.From,
+		To:          query.To,
+		Granularity: query.Granularity,
+	})
+	if errors.Is(err, services.ErrInvalidHistoryRange) {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "from", Rule: "range", Message: fmt.Sprintf("toより前の日付で、期間が%d日以内になるよう指定してください", services.MaxContributionHistoryDays)}})
+		return
+	}
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newContributionHistoryResponse(*history))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler はユーザーの保持データのエクスポートを処理します
+type ExportHandler struct {
+	users   *repositories.UserRepository
+	exports *services.ExportService
+}
+
+// NewExportHandler はExportHandlerを作成します
+func NewExportHandler(users *repositories.UserRepository, exports *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		users:   users,
+		exports: exports,
+	}
+}
+
+// ユーザーの保持データをまとめてダウンロードさせるハンドラー
+// GET /users/:id/export?format=json|zip
+func (h *ExportHandler) ExportUser(c *gin.Context) {
+	id := c.Param("id")
+
+	var query struct {
+		Format string `form:"format" binding:"omitempty,oneof=json zip"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	format := query.Format
+	if format == "" {
+		format = services.ExportFormatJSON
+	}
+
+	// ストリーミングを始めるとステータスコードを変えられないため、先に存在確認をしておく
+	ctx := c.Request.Context()
+	if _, err := h.users.GetUserDocument(ctx, id); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	var contentType string
+	var export func(ctx context.Context, w io.Writer, id string) error
+	switch format {
+	case services.ExportFormatZip:
+		contentType = "application/zip"
+		export = h.exports.ExportUserZip
+	default:
+		contentType = "application/json; charset=utf-8"
+		export = h.exports.ExportUserJSON
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s-export.%s"`, id, format))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	if err := export(ctx, c.Writer, id); err != nil {
+		// 途中まで送信済みのためレスポンスは変更できない。ログのみ出力して接続を終える
+		slog.ErrorContext(ctx, "エクスポート中にエラーが発生しました", "user_id", id, "format", format, "error", err)
+		c.Abort()
+	}
+}
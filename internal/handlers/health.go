@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"geekcamp-vol10-backend/internal/buildinfo"
+	"geekcamp-vol10-backend/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler はプロセスと依存先の状態、ビルド情報を返します
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler はHealthHandlerを作成します
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// プロセスが応答できるかを返すハンドラー（liveness）
+// 依存先の状態は見ないため、Firestoreの障害でプロセスが再起動されることはありません
+// GET /livez
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": health.StatusOK,
+	})
+}
+
+// FirestoreとGitHubに接続できるかを返すハンドラー（readiness）
+// いずれかに接続できない場合は503を返します
+// GET /readyz
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ready, results := h.checker.Check(c.Request.Context())
+
+	status := http.StatusOK
+	body := gin.H{
+		"status": health.StatusOK,
+		"checks": results,
+	}
+	if !ready {
+		status = http.StatusServiceUnavailable
+		body["status"] = health.StatusUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, body)
+}
+
+// ビルド時に埋め込んだバージョン・コミットと起動時刻を返すハンドラー
+// GET /version
+func (h *HealthHandler) Version(c *gin.Context) {
+	info := buildinfo.Get()
+	c.JSON(http.StatusOK, gin.H{
+		"version":       info.Version,
+		"commit":        info.Commit,
+		"buildTime":     info.BuildTime,
+		"goVersion":     info.GoVersion,
+		"startTime":     info.StartTime,
+		"uptimeSeconds": int64(time.Since(info.StartTime).Seconds()),
+	})
+}
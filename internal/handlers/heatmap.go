@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/heatmap"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ヒートマップをブラウザにキャッシュさせる時間
+// 日ごとのコントリビューションは同期したときにしか変わらないため、数分は同じ画像を使わせる
+const heatmapMaxAge = 5 * time.Minute
+
+// HeatmapHandler はコントリビューションのヒートマップの画像を処理します
+type HeatmapHandler struct {
+	heatmaps *services.HeatmapService
+}
+
+// NewHeatmapHandler はHeatmapHandlerを作成します
+func NewHeatmapHandler(heatmaps *services.HeatmapService) *HeatmapHandler {
+	return &HeatmapHandler{
+		heatmaps: heatmaps,
+	}
+}
+
+// コントリビューションのヒートマップをSVGで返すハンドラー
+// GET /users/:id/heatmap.svg?weeks=&theme=light|dark&colors=
+func (h *HeatmapHandler) GetHeatmap(c *gin.Context) {
+	id := c.Param("id")
+
+	var query struct {
+		Weeks  int    `form:"weeks" binding:"omitempty,gte=1,lte=53"`
+		Theme  string `form:"theme" binding:"omitempty,oneof=light dark"`
+		Colors string `form:"colors"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	var colors []string
+	if query.Colors != "" {
+		var err error
+		if colors, err = heatmap.ParseColors(query.Colors); err != nil {
+			validation.AbortWithDetails(c, []validation.FieldError{{Field: "colors", Rule: "colors", Message: "16進数の色（例: ebedf0,9be9a8,216e39）をカンマ区切りで2〜10色指定してください"}})
+			return
+		}
+	}
+	weeks := query.Weeks
+	if weeks == 0 {
+		weeks = heatmap.MaxWeeks
+	}
+
+	data, err := h.heatmaps.Heatmap(c.Request.Context(), id, weeks)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	// テーマを指定しない場合はユーザーの表示設定に合わせる
+	themeName := query.Theme
+	if themeName == "" {
+		themeName = data.Theme
+	}
+	theme, ok := heatmap.Themes[themeName]
+	if !ok {
+		theme = heatmap.Themes[heatmap.ThemeLight]
+	}
+	if colors != nil {
+		theme.Colors = colors
+	}
+
+	var buf bytes.Buffer
+	if err := heatmap.Render(&buf, data.Calendar, theme); err != nil {
+		apperrors.Abort(c, fmt.Errorf("ヒートマップの描画に失敗しました: %w", err))
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(heatmapMaxAge.Seconds())))
+	c.Header("ETag", etag)
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", buf.Bytes())
+}
+
+// If-None-Matchのいずれかのタグ（弱いタグを含む）がetagと一致するか
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
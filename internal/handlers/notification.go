@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/notify"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler はプッシュ通知の端末の登録と通知の設定を処理します
+type NotificationHandler struct {
+	notifications *services.NotificationService
+}
+
+// NewNotificationHandler はNotificationHandlerを作成します
+func NewNotificationHandler(notifications *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notifications: notifications,
+	}
+}
+
+// 通知を送る端末を登録するハンドラー
+// POST /users/:id/devices
+func (h *NotificationHandler) RegisterDevice(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		// FCMの登録トークン
+		Token    string `json:"token" binding:"required,max=4096"`
+		Platform string `json:"platform" binding:"required,oneof=ios android web"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	device, created, err := h.notifications.RegisterDevice(ctx, id, req.Token, req.Platform)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, newDeviceResponse(*device))
+}
+
+// 端末の登録を解除するハンドラー
+// DELETE /users/:id/devices/:deviceId
+func (h *NotificationHandler) UnregisterDevice(c *gin.Context) {
+	id := c.Param("id")
+	deviceId := c.Param("deviceId")
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := h.notifications.UnregisterDevice(ctx, id, deviceId); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 通知の設定を取得するハンドラー
+// GET /users/:id/notification-settings
+func (h *NotificationHandler) GetSettings(c *gin.Context) {
+	id := c.Param("id")
+
+	settings, err := h.notifications.Settings(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, NotificationSettingsResponse{Categories: settings})
+}
+
+// 通知の設定を更新するハンドラー
+// 指定したカテゴリだけを変更します
+// PATCH /users/:id/notification-settings
+func (h *NotificationHandler) PatchSettings(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Categories map[string]bool `json:"categories" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	if len(req.Categories) == 0 {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "categories", Rule: "required", Message: "更新する項目がありません"}})
+		return
+	}
+	var details []validation.FieldError
+	for category := range req.Categories {
+		if !notify.IsCategory(category) {
+			details = append(details, validation.FieldError{
+				Field:   "categories." + category,
+				Rule:    "oneof",
+				Message: "カテゴリは " + strings.Join(notify.Categories, ", ") + " のいずれかで指定してください",
+			})
+		}
+	}
+	if len(details) > 0 {
+		// mapの順序によらず同じレスポンスにする
+		sort.Slice(details, func(i, j int) bool { return details[i].Field < details[j].Field })
+		validation.AbortWithDetails(c, details)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	settings, err := h.notifications.UpdateSettings(ctx, id, req.Categories)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, NotificationSettingsResponse{Categories: settings})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"time"
+
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/services"
+)
+
+// UserResponseVersion はユーザーのレスポンスの形式のバージョンです
+// フィールドの削除・型や意味の変更など、クライアントが対応を必要とする変更をした場合に上げます（フィールドの追加では上げません）
+const UserResponseVersion = 2
+
+// UserProfileResponse はユーザーのプロフィールのレスポンスです
+// POST /users・PATCH /users/:id の "user" に使います
+type UserProfileResponse struct {
+	Version              int                      `json:"version"`
+	FirebaseId           string                   `json:"firebaseId"`
+	GithubUserName       string                   `json:"githubUserName"`
+	PhotoURL             string                   `json:"photoURL"`
+	CreatedAt            time.Time                `json:"createdAt"`
+	ContinuousSealRecord int64                    `json:"continuousSealRecord"`
+	MaxSealRecord        int64                    `json:"maxSealRecord"`
+	DisplaySettings      *DisplaySettingsResponse `json:"displaySettings,omitempty"`
+	// 設定していない場合は省略し、サーバーのデフォルト（DEFAULT_TIME_ZONE）で集計します
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// UserResponse はGET /users/:id のレスポンスです
+// currentMonsterが存在しない場合はnull、封印済みモンスターがない場合は空の配列を返します。
+// 封印済みモンスターは件数と新しいものから数件のみで、すべて取得する場合は GET /users/:id/sealed-monsters を使います
+type UserResponse struct {
+	UserProfileResponse
+	CurrentMonster       *CurrentMonsterResponse `json:"currentMonster"`
+	SealedMonsterCount   int64                   `json:"sealedMonsterCount"`
+	RecentSealedMonsters []SealedMonsterResponse `json:"recentSealedMonsters"`
+}
+
+// SealedMonstersResponse はGET /users/:id/sealed-monsters のレスポンスです
+type SealedMonstersResponse struct {
+	SealedMonsters []SealedMonsterResponse `json:"sealedMonsters"`
+	// 次のページを取得するときにcursorに指定する値。次のページがない場合は省略
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// DisplaySettingsResponse はアプリ上での表示に関する設定です
+type DisplaySettingsResponse struct {
+	DisplayName string `json:"displayName"`
+	Theme       string `json:"theme"`
+}
+
+// CurrentMonsterResponse は育成中のモンスターです
+// remainingHP・progressPercentは保存された値から計算します
+type CurrentMonsterResponse struct {
+	MonsterId             string `json:"monsterId"`
+	ProgressContributions int    `json:"progressContributions"`
+	RequiredContributions int    `json:"requiredContributions"`
+	// 封印までに必要な残りのコントリビューション数（0以上）
+	RemainingHP int `json:"remainingHP"`
+	// 封印までの進捗（0〜100の整数、切り捨て）
+	ProgressPercent             int       `json:"progressPercent"`
+	LastContributionReflectedAt time.Time `json:"lastContributionReflectedAt"`
+	AssignedAt                  time.Time `json:"assignedAt"`
+}
+
+// SealedMonsterResponse は封印済みのモンスターです
+type SealedMonsterResponse struct {
+	MonsterId   string    `json:"monsterId"`
+	MonsterName string    `json:"monsterName"`
+	SealedAt    time.Time `json:"sealedAt"`
+}
+
+func newUserProfileResponse(user models.User) UserProfileResponse {
+	res := UserProfileResponse{
+		Version:              UserResponseVersion,
+		FirebaseId:           user.FirebaseId,
+		GithubUserName:       user.GithubUserName,
+		PhotoURL:             user.PhotoURL,
+		CreatedAt:            user.CreatedAt,
+		ContinuousSealRecord: user.ContinuousSealRecord,
+		MaxSealRecord:        user.MaxSealRecord,
+		TimeZone:             user.TimeZone,
+	}
+	if user.DisplaySettings != nil {
+		res.DisplaySettings = &DisplaySettingsResponse{
+			DisplayName: user.DisplaySettings.DisplayName,
+			Theme:       user.DisplaySettings.Theme,
+		}
+	}
+	return res
+}
+
+func newUserResponse(user models.User) UserResponse {
+	res := UserResponse{
+		UserProfileResponse:  newUserProfileResponse(user),
+		SealedMonsterCount:   user.SealedMonsterCount,
+		RecentSealedMonsters: newSealedMonsterResponses(user.SealedMonsters),
+	}
+	if user.CurrentMonster != nil {
+		current := newCurrentMonsterResponse(*user.CurrentMonster)
+		res.CurrentMonster = &current
+	}
+	return res
+}
+
+// nilの場合もnullではなく空の配列にする
+func newSealedMonsterResponses(sealedMonsters []models.SealedMonster) []SealedMonsterResponse {
+	res := make([]SealedMonsterResponse, 0, len(sealedMonsters))
+	for _, sealed := range sealedMonsters {
+		res = append(res, SealedMonsterResponse{
+			MonsterId:   sealed.MonsterId,
+			MonsterName: sealed.MonsterName,
+			SealedAt:    sealed.SealedAt,
+		})
+	}
+	return res
+}
+
+func newCurrentMonsterResponse(monster models.CurrentMonster) CurrentMonsterResponse {
+	remaining := max(monster.RequiredContributions-monster.ProgressContributions, 0)
+	percent := 0
+	if monster.RequiredContributions > 0 {
+		percent = min(max(monster.ProgressContributions, 0)*100/monster.RequiredContributions, 100)
+	}
+	return CurrentMonsterResponse{
+		MonsterId:                   monster.MonsterId,
+		ProgressContributions:       monster.ProgressContributions,
+		RequiredContributions:       monster.RequiredContributions,
+		RemainingHP:                 remaining,
+		ProgressPercent:             percent,
+		LastContributionReflectedAt: monster.LastContributionReflectedAt,
+		AssignedAt:                  monster.AssignedAt,
+	}
+}
+
+// ContributionHistoryResponse はGET /users/:id/contributions/history のレスポンスです
+// seriesはfromからtoまでを集計の単位ごとに区切り、コントリビューションがない期間も0で含めます
+type ContributionHistoryResponse struct {
+	TimeZone    string                       `json:"timeZone"`
+	Granularity string                       `json:"granularity"`
+	From        string                       `json:"from"`
+	To          string                       `json:"to"`
+	Total       int                          `json:"total"`
+	Series      []ContributionBucketResponse `json:"series"`
+}
+
+// ContributionBucketResponse は集計の単位1つ分（日・週・月）のコントリビューションです
+type ContributionBucketResponse struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Total int    `json:"total"`
+	// 種類ごとの数（commit, issue, pull_request, pull_request_review）。0の種類も含めます
+	ByType map[string]int `json:"byType"`
+	// リポジトリ（owner/name）ごとの数。コントリビューションがない期間は省略します
+	ByRepository map[string]int `json:"byRepository,omitempty"`
+}
+
+func newContributionHistoryResponse(history services.ContributionHistory) ContributionHistoryResponse {
+	res := ContributionHistoryResponse{
+		TimeZone:    history.TimeZone,
+		Granularity: history.Granularity,
+		From:        history.From,
+		To:          history.To,
+		Total:       history.Total,
+		Series:      make([]ContributionBucketResponse, 0, len(history.Buckets)),
+	}
+	for _, bucket := range history.Buckets {
+		res.Series = append(res.Series, ContributionBucketResponse{
+			Start:        bucket.Start,
+			End:          bucket.End,
+			Total:        bucket.Total,
+			ByType:       bucket.ByType,
+			ByRepository: bucket.ByRepository,
+		})
+	}
+	return res
+}
+
+// DeviceResponse はPOST /users/:id/devices のレスポンスです
+// トークンは登録した端末自身しか知る必要がないため返しません
+type DeviceResponse struct {
+	DeviceId   string    `json:"deviceId"`
+	Platform   string    `json:"platform"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// NotificationSettingsResponse はGET・PATCH /users/:id/notification-settings のレスポンスです
+// categoriesはすべてのカテゴリを含み、通知を受け取る場合はtrueです
+type NotificationSettingsResponse struct {
+	Categories map[string]bool `json:"categories"`
+}
+
+func newDeviceResponse(device models.DeviceToken) DeviceResponse {
+	return DeviceResponse{
+		DeviceId:   device.DeviceId,
+		Platform:   device.Platform,
+		CreatedAt:  device.CreatedAt,
+		LastSeenAt: device.LastSeenAt,
+	}
+}
+
+// WebhookResponse は登録したwebhookです
+// シークレットは作成時のレスポンス（CreatedWebhookResponse）でのみ返します
+type WebhookResponse struct {
+	WebhookId string    `json:"webhookId"`
+	URL       string    `json:"url"`
+	Format    string    `json:"format"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreatedWebhookResponse はPOST /users/:id/webhooks のレスポンスです
+type CreatedWebhookResponse struct {
+	WebhookResponse
+	// 署名の検証に使うシークレット。再取得できないため、受信側で保存してください
+	Secret string `json:"secret"`
+}
+
+// WebhooksResponse はGET /users/:id/webhooks のレスポンスです
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse はイベント1件のwebhookへの配送の記録です
+type WebhookDeliveryResponse struct {
+	// イベントのID（送信したリクエストの X-GrassChain-Delivery と同じ値）
+	DeliveryId string `json:"deliveryId"`
+	EventType  string `json:"eventType"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+	Attempts   int    `json:"attempts"`
+	DurationMs int64  `json:"durationMs"`
+	// 最後に送信を試みた日時
+	LastAttemptAt time.Time `json:"lastAttemptAt"`
+	Test          bool      `json:"test"`
+}
+
+// WebhookDeliveriesResponse はGET /users/:id/webhooks/:webhookId/deliveries のレスポンスです
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+func newWebhookResponse(webhook models.Webhook) WebhookResponse {
+	return WebhookResponse{
+		WebhookId: webhook.WebhookId,
+		URL:       webhook.URL,
+		Format:    webhook.Format,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func newWebhookDeliveryResponse(delivery models.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		DeliveryId:    delivery.DeliveryId,
+		EventType:     delivery.EventType,
+		Status:        delivery.Status,
+		StatusCode:    delivery.StatusCode,
+		Error:         delivery.Error,
+		Attempts:      delivery.Attempts,
+		DurationMs:    delivery.DurationMs,
+		LastAttemptAt: delivery.LastAttemptAt,
+		Test:          delivery.Test,
+	}
+}
+
+// AdminUsersResponse はGET /admin/users のレスポンスです
+type AdminUsersResponse struct {
+	Users []UserProfileResponse `json:"users"`
+	// 次のページを取得するときにcursorに指定する値。次のページがない場合は省略
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// AdminUserStateResponse はGET /admin/users/:id のレスポンスです
+// profileはusersドキュメントのすべてのフィールドで、userに含めない内部のフィールドの確認に使います
+type AdminUserStateResponse struct {
+	User     UserResponse           `json:"user"`
+	Profile  map[string]interface{} `json:"profile"`
+	Devices  []DeviceResponse       `json:"devices"`
+	Webhooks []WebhookResponse      `json:"webhooks"`
+}
+
+// AdminCurrentMonsterResponse は管理者APIで進捗を変更した後の育成中のモンスターです
+type AdminCurrentMonsterResponse struct {
+	CurrentMonster CurrentMonsterResponse `json:"currentMonster"`
+	// 進捗の付与で封印したモンスター。封印していない場合は省略
+	SealedMonster *SealedMonsterResponse `json:"sealedMonster,omitempty"`
+}
+
+func newAdminUserStateResponse(state services.UserState) AdminUserStateResponse {
+	res := AdminUserStateResponse{
+		User:     newUserResponse(*state.User),
+		Profile:  state.Profile,
+		Devices:  make([]DeviceResponse, 0, len(state.Devices)),
+		Webhooks: make([]WebhookResponse, 0, len(state.Webhooks)),
+	}
+	for _, device := range state.Devices {
+		res.Devices = append(res.Devices, newDeviceResponse(device))
+	}
+	for _, webhook := range state.Webhooks {
+		res.Webhooks = append(res.Webhooks, newWebhookResponse(webhook))
+	}
+	return res
+}
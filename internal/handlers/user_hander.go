@@ -2,71 +2,200 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
 	"geekcamp-vol10-backend/internal/services"
-	"geekcamp-vol10-backend/pkg/database"
+	"geekcamp-vol10-backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Users ハンドラー
-func Users(c *gin.Context) {
-	log.Printf("POST /users エンドポイントが呼び出されました")
-	
+// GET /users/:id/sealed-monsters の1ページの件数（limit省略時）
+const defaultSealedMonstersLimit = 20
+
+// UserHandler はユーザーのエンドポイントを処理します
+type UserHandler struct {
+	users *services.UserService
+}
+
+// NewUserHandler はUserHandlerを作成します
+func NewUserHandler(users *services.UserService) *UserHandler {
+	return &UserHandler{
+		users: users,
+	}
+}
+
+// ユーザー登録ハンドラー
+// POST /users
+func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req struct {
-		FirebaseId     string `json:"firebaseId"`
-		GithubUserName string `json:"githubUserName"`
-		PhotoURL       string `json:"photoURL"`
+		FirebaseId     string `json:"firebaseId" binding:"required,firestore_id"`
+		GithubUserName string `json:"githubUserName" binding:"required,github_login"`
+		PhotoURL       string `json:"photoURL" binding:"required,https_url"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("JSONバインドエラー: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		validation.Abort(c, err)
 		return
 	}
-	
-	log.Printf("受信したリクエスト: FirebaseId='%s', GithubUserName='%s', PhotoURL='%s'", 
-		req.FirebaseId, req.GithubUserName, req.PhotoURL)
 
-	ctx := context.Background()
-	client := database.GetFirestoreClient()
-	if client == nil {
-		log.Printf("Firestoreクライアントの取得に失敗")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection failed"})
+	// 登録できるのはIDトークンのユーザー本人のIDだけ（管理者を除く）
+	// 認証が無効な環境ではUIDがないため確認しない
+	uid := c.GetString(middleware.ContextKeyFirebaseUID)
+	if uid != "" && uid != req.FirebaseId && !c.GetBool(middleware.ContextKeyAdmin) {
+		apperrors.Abort(c, fmt.Errorf("他のユーザーのIDでは登録できません: %w", apperrors.ErrForbidden))
 		return
 	}
-	
-	log.Printf("Firestoreクライアントの取得成功")
 
-	// services.CreateUserを使用してユーザーを作成
-	userData, err := services.CreateUser(ctx, client, req.FirebaseId, req.GithubUserName, req.PhotoURL)
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	// UserService.CreateUserを使用してユーザーを作成
+	user, created, err := h.users.CreateUser(ctx, req.FirebaseId, req.GithubUserName, req.PhotoURL)
 	if err != nil {
-		log.Printf("CreateUser: ユーザー作成に失敗: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		// 異なる内容で登録済みの場合は、既存のユーザー情報をエラーレスポンスに含める
+		// 本人以外（管理者や認証が無効な環境）に他のアカウントの情報を返さないよう、UIDが一致する場合だけにする
+		if errors.Is(err, apperrors.ErrConflict) && user != nil && uid == req.FirebaseId {
+			err = apperrors.WithExtensions(err, map[string]interface{}{"user": newUserProfileResponse(*user)})
+		}
+		apperrors.Abort(c, err)
 		return
 	}
 
-	log.Printf("ユーザーデータの保存に成功しました: FirebaseId='%s'", req.FirebaseId)
-	c.JSON(http.StatusOK, gin.H{
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User already exists",
+			"user":    newUserProfileResponse(*user),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
 		"message": "User created successfully",
-		"user":    userData,
+		"user":    newUserProfileResponse(*user),
 	})
 }
 
-func GETUser(c *gin.Context) {
+// ユーザー取得ハンドラー
+// GET /users/:id
+func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id") // URL の :id 部分を取得
-	log.Printf("ユーザーID '%s' の情報を取得中...", id)
 
-	user, err := services.GetUserByIDService(c, id)
+	user, err := h.users.GetUserByID(c.Request.Context(), id)
 	if err != nil {
-		log.Printf("ユーザーID '%s' の取得に失敗しました: %v", id, err)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "not found",
-		})
+		apperrors.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(*user))
+}
+
+// 封印済みモンスターの一覧を取得するハンドラー
+// GET /users/:id/sealed-monsters?limit=&cursor=&order=asc|desc&from=&to=&monsterId=
+func (h *UserHandler) ListSealedMonsters(c *gin.Context) {
+	id := c.Param("id")
+
+	var query struct {
+		Limit     int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+		Cursor    string `form:"cursor"`
+		Order     string `form:"order" binding:"omitempty,oneof=asc desc"`
+		From      string `form:"from" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+		To        string `form:"to" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+		MonsterId string `form:"monsterId" binding:"omitempty,firestore_id"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+
+	// バインド時にdatetimeで形式を検証済み
+	from, _ := time.Parse(time.RFC3339, query.From)
+	to, _ := time.Parse(time.RFC3339, query.To)
+	if query.From != "" && query.To != "" && !from.Before(to) {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "to", Rule: "gtfield", Message: "fromより後の日時を指定してください"}})
+		return
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultSealedMonstersLimit
+	}
+	page, err := h.users.ListSealedMonsters(c.Request.Context(), id, repositories.SealedMonstersQuery{
+		Limit:      limit,
+		Descending: query.Order != "asc",
+		From:       from,
+		To:         to,
+		MonsterId:  query.MonsterId,
+		Cursor:     query.Cursor,
+	})
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "cursor", Rule: "cursor", Message: "カーソルが正しくありません"}})
+		return
+	}
+	if err != nil {
+		apperrors.Abort(c, err)
 		return
 	}
 
-	log.Printf("ユーザーID '%s' の情報を正常に取得しました", id)
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, SealedMonstersResponse{
+		SealedMonsters: newSealedMonsterResponses(page.SealedMonsters),
+		NextCursor:     page.NextCursor,
+	})
+}
+
+// プロフィール更新ハンドラー
+// PATCH /users/:id
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		GithubUserName  *string                 `json:"githubUserName" binding:"omitempty,github_login"`
+		PhotoURL        *string                 `json:"photoURL" binding:"omitempty,https_url"`
+		DisplaySettings *models.DisplaySettings `json:"displaySettings"`
+		TimeZone        *string                 `json:"timeZone" binding:"omitempty,timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	if req.GithubUserName == nil && req.PhotoURL == nil && req.DisplaySettings == nil && req.TimeZone == nil {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "", Rule: "required", Message: "更新する項目がありません"}})
+		return
+	}
+
+	// GitHubユーザー名の確認にはユーザー本人のトークンを使う
+	// 管理者が他のユーザーを更新する場合はトークンが管理者のものになり、本人のログイン名を確認できないため変更させない
+	// （認証が無効な環境ではUIDがないため確認しない）
+	if uid := c.GetString(middleware.ContextKeyFirebaseUID); req.GithubUserName != nil && uid != "" && uid != id {
+		apperrors.Abort(c, fmt.Errorf("GitHubユーザー名はユーザー本人しか変更できません: %w", apperrors.ErrForbidden))
+		return
+	}
+
+	// Middlewareが無効な環境では、GetContributionと同様に設定のGITHUB_TOKENが入る
+	githubToken := c.GetString(middleware.ContextKeyGitHubAccessToken)
+	if req.GithubUserName != nil && githubToken == "" {
+		apperrors.Abort(c, apperrors.ErrGitHubCredentials)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	user, err := h.users.UpdateUserProfile(ctx, id, services.UpdateUserProfileInput{
+		GithubUserName:  req.GithubUserName,
+		PhotoURL:        req.PhotoURL,
+		DisplaySettings: req.DisplaySettings,
+		TimeZone:        req.TimeZone,
+	}, githubToken)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User updated successfully",
+		"user":    newUserProfileResponse(*user),
+	})
 }
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// メモリ上のUserServiceUsers
+type memoryUsers struct {
+	users   map[string]*models.User
+	updates map[string][]firestore.Update
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{users: make(map[string]*models.User), updates: make(map[string][]firestore.Update)}
+}
+
+func (m *memoryUsers) CreateUserIfAbsent(_ context.Context, user models.User) (*models.User, error) {
+	if existing, ok := m.users[user.FirebaseId]; ok {
+		copied := *existing
+		return &copied, repositories.ErrUserAlreadyExists
+	}
+	m.users[user.FirebaseId] = &user
+	return nil, nil
+}
+
+func (m *memoryUsers) GetUserByID(_ context.Context, id string) (*models.User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (m *memoryUsers) GetCurrentMonster(context.Context, string) (*models.CurrentMonster, error) {
+	return nil, nil
+}
+
+func (m *memoryUsers) CountSealedMonsters(context.Context, string) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryUsers) ListSealedMonsters(context.Context, string, repositories.SealedMonstersQuery) (*repositories.SealedMonstersPage, error) {
+	return &repositories.SealedMonstersPage{}, nil
+}
+
+func (m *memoryUsers) UpdateUserFields(_ context.Context, id string, updates []firestore.Update) error {
+	m.updates[id] = append(m.updates[id], updates...)
+	return nil
+}
+
+// トークンごとのviewerを返すGitHubViewers
+type fakeViewers map[string]models.GithubViewer
+
+func (f fakeViewers) GetViewer(_ context.Context, githubToken string) (models.GithubViewer, error) {
+	viewer, ok := f[githubToken]
+	if !ok {
+		return models.GithubViewer{}, errors.New("未知のトークンです")
+	}
+	return viewer, nil
+}
+
+// caller はAuthMiddlewareが設定する呼び出し元です（uidが空の場合は認証が無効な環境）
+type caller struct {
+	uid         string
+	admin       bool
+	githubToken string
+}
+
+func newUserRouter(users *memoryUsers, viewers fakeViewers, who caller) *gin.Engine {
+	h := NewUserHandler(services.NewUserService(users, viewers))
+	r := gin.New()
+	r.Use(apperrors.Middleware())
+	r.Use(func(c *gin.Context) {
+		if who.uid != "" {
+			c.Set(middleware.ContextKeyFirebaseUID, who.uid)
+			c.Set(middleware.ContextKeyAdmin, who.admin)
+		}
+		c.Set(middleware.ContextKeyGitHubAccessToken, who.githubToken)
+	})
+	r.POST("/users", h.CreateUser)
+	r.PATCH("/users/:id", h.PatchUser)
+	return r
+}
+
+func serve(r http.Handler, method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var got map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	return w, got
+}
+
+func TestPatchUserGitHubUserNameBySelf(t *testing.T) {
+	users := newMemoryUsers()
+	users.users["user-1"] = &models.User{FirebaseId: "user-1", GithubUserName: "old-login"}
+	viewers := fakeViewers{"token-1": {Login: "New-Login", AvatarURL: "https://avatars.githubusercontent.com/u/1"}}
+	r := newUserRouter(users, viewers, caller{uid: "user-1", githubToken: "token-1"})
+
+	w, got := serve(r, http.MethodPatch, "/users/user-1", `{"githubUserName":"new-login"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s", w.Code, w.Body)
+	}
+	// 表記はGitHubのログイン名に揃え、アバターも更新する
+	user, _ := got["user"].(map[string]interface{})
+	if user["githubUserName"] != "New-Login" || user["photoURL"] != "https://avatars.githubusercontent.com/u/1" {
+		t.Errorf("user = %v", user)
+	}
+	if len(users.updates["user-1"]) != 2 {
+		t.Errorf("更新したフィールド = %+v", users.updates["user-1"])
+	}
+}
+
+func TestPatchUserGitHubUserNameByAdmin(t *testing.T) {
+	users := newMemoryUsers()
+	users.users["user-1"] = &models.User{FirebaseId: "user-1", GithubUserName: "old-login"}
+	// 管理者のトークンのviewerは管理者自身
+	viewers := fakeViewers{"admin-token": {Login: "admin-login"}}
+	r := newUserRouter(users, viewers, caller{uid: "admin-1", admin: true, githubToken: "admin-token"})
+
+	for _, login := range []string{"admin-login", "new-login"} {
+		w, got := serve(r, http.MethodPatch, "/users/user-1", `{"githubUserName":"`+login+`"}`)
+		if w.Code != http.StatusForbidden || got["code"] != "forbidden" {
+			t.Errorf("githubUserName=%s: PATCH = %d %s", login, w.Code, w.Body)
+		}
+	}
+	if len(users.updates) != 0 {
+		t.Errorf("拒否したのに更新しました: %+v", users.updates)
+	}
+
+	// GitHubユーザー名以外は管理者も変更できる
+	w, _ := serve(r, http.MethodPatch, "/users/user-1", `{"timeZone":"Asia/Tokyo"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("timeZone: PATCH = %d %s", w.Code, w.Body)
+	}
+	if u := users.updates["user-1"]; len(u) != 1 || u[0].Path != "timeZone" {
+		t.Errorf("更新したフィールド = %+v", u)
+	}
+}
+
+func TestPatchUserGitHubUserNameMismatch(t *testing.T) {
+	users := newMemoryUsers()
+	users.users["user-1"] = &models.User{FirebaseId: "user-1", GithubUserName: "old-login"}
+	viewers := fakeViewers{"token-1": {Login: "someone-else"}}
+	r := newUserRouter(users, viewers, caller{uid: "user-1", githubToken: "token-1"})
+
+	w, got := serve(r, http.MethodPatch, "/users/user-1", `{"githubUserName":"new-login"}`)
+	if w.Code != http.StatusForbidden || got["code"] != "github_user_mismatch" {
+		t.Errorf("PATCH = %d %s", w.Code, w.Body)
+	}
+}
+
+const createUserBody = `{"firebaseId":"user-1","githubUserName":"plmwa","photoURL":"https://avatars.githubusercontent.com/u/1"}`
+
+func TestCreateUserRequiresOwnID(t *testing.T) {
+	tests := []struct {
+		name string
+		who  caller
+		want int
+	}{
+		{"本人", caller{uid: "user-1"}, http.StatusCreated},
+		{"他のユーザー", caller{uid: "user-2"}, http.StatusForbidden},
+		{"管理者", caller{uid: "admin-1", admin: true}, http.StatusCreated},
+		{"認証が無効", caller{}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		users := newMemoryUsers()
+		r := newUserRouter(users, fakeViewers{}, tt.who)
+		w, _ := serve(r, http.MethodPost, "/users", createUserBody)
+		if w.Code != tt.want {
+			t.Errorf("%s: POST /users = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if _, created := users.users["user-1"]; created != (tt.want == http.StatusCreated) {
+			t.Errorf("%s: 登録された = %v", tt.name, created)
+		}
+	}
+}
+
+func TestCreateUserConflictExtensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		who      caller
+		wantUser bool
+	}{
+		{"本人", caller{uid: "user-1"}, true},
+		{"管理者", caller{uid: "admin-1", admin: true}, false},
+		{"認証が無効", caller{}, false},
+	}
+	for _, tt := range tests {
+		users := newMemoryUsers()
+		users.users["user-1"] = &models.User{FirebaseId: "user-1", GithubUserName: "other-login", PhotoURL: "https://example.com/a.png"}
+		r := newUserRouter(users, fakeViewers{}, tt.who)
+		w, got := serve(r, http.MethodPost, "/users", createUserBody)
+		if w.Code != http.StatusConflict || got["code"] != "conflict" {
+			t.Errorf("%s: POST /users = %d %s", tt.name, w.Code, w.Body)
+			continue
+		}
+		if _, ok := got["user"]; ok != tt.wantUser {
+			t.Errorf("%s: レスポンスに既存のユーザーを含む = %v, want %v", tt.name, ok, tt.wantUser)
+		}
+	}
+}
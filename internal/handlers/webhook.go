@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /users/:id/webhooks/:webhookId/deliveries の件数（limit省略時）
+const defaultWebhookDeliveriesLimit = 20
+
+// WebhookHandler はwebhookの登録と配送の記録を処理します
+type WebhookHandler struct {
+	webhooks *services.WebhookService
+}
+
+// NewWebhookHandler はWebhookHandlerを作成します
+func NewWebhookHandler(webhooks *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks: webhooks,
+	}
+}
+
+// webhookを登録するハンドラー
+// POST /users/:id/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		URL    string `json:"url" binding:"required,max=2048"`
+		Format string `json:"format" binding:"required,oneof=discord slack json"`
+		// 送信するイベントの種類（outbox.Types）
+		Events []string `json:"events" binding:"required,min=1,dive,oneof=MonsterSealed MonsterAssigned StreakExtended ContributionsCredited"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	if err := h.webhooks.ValidateURL(req.URL); err != nil {
+		validation.AbortWithDetails(c, []validation.FieldError{{Field: "url", Rule: "webhook_url", Message: err.Error()}})
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	webhook, err := h.webhooks.CreateWebhook(ctx, id, req.URL, req.Format, req.Events)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, CreatedWebhookResponse{
+		WebhookResponse: newWebhookResponse(*webhook),
+		Secret:          webhook.Secret,
+	})
+}
+
+// 登録したwebhookの一覧を取得するハンドラー
+// GET /users/:id/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	id := c.Param("id")
+
+	webhooks, err := h.webhooks.ListWebhooks(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	res := WebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		res.Webhooks = append(res.Webhooks, newWebhookResponse(webhook))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// webhookの登録を解除するハンドラー
+// DELETE /users/:id/webhooks/:webhookId
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+	webhookId := c.Param("webhookId")
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := h.webhooks.DeleteWebhook(ctx, id, webhookId); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// webhookへの配送の記録を取得するハンドラー
+// GET /users/:id/webhooks/:webhookId/deliveries?limit=
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	webhookId := c.Param("webhookId")
+	var query struct {
+		Limit int `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validation.Abort(c, err)
+		return
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultWebhookDeliveriesLimit
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(c.Request.Context(), id, webhookId, limit)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	res := WebhookDeliveriesResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		res.Deliveries = append(res.Deliveries, newWebhookDeliveryResponse(delivery))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// webhookにテストイベントを送信するハンドラー
+// 受信側が失敗を返した場合も200で配送の記録を返します
+// POST /users/:id/webhooks/:webhookId/test
+func (h *WebhookHandler) SendTest(c *gin.Context) {
+	id := c.Param("id")
+	webhookId := c.Param("webhookId")
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	delivery, err := h.webhooks.SendTest(ctx, id, webhookId)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookDeliveryResponse(*delivery))
+}
@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// チェック結果の状態
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// CheckFunc は依存先（Firestore・GitHubなど）に接続できるかを確認する関数です
+type CheckFunc func(ctx context.Context) error
+
+// Result は1つのチェックの結果です
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Checker は登録されたチェックを実行し、結果を一定時間キャッシュします
+// readinessプローブやロードバランサーから頻繁に呼ばれても、依存先へのリクエストはTTLごとに1回に抑えられます
+type Checker struct {
+	timeout time.Duration
+	ttl     time.Duration
+	checks  []*check
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+
+	// 実行中は同じチェックを呼んだ他のリクエストを待たせ、結果を共有する
+	mu      sync.Mutex
+	result  Result
+	expires time.Time
+}
+
+// NewChecker はCheckerを作成します
+// timeoutは1回のチェックの制限時間、ttlは結果をキャッシュする時間です
+func NewChecker(timeout, ttl time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		ttl:     ttl,
+	}
+}
+
+// Register はチェックを追加します
+// ルートを登録する前（リクエストを受け付ける前）に呼び出してください
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, &check{name: name, fn: fn})
+}
+
+// Check はすべてのチェックを並行に実行し、すべて成功した場合にtrueを返します
+// キャッシュが有効なチェックは実行せず、前回の結果を返します
+func (c *Checker) Check(ctx context.Context) (bool, map[string]Result) {
+	results := make(map[string]Result, len(c.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk *check) {
+			defer wg.Done()
+			r := c.run(ctx, chk)
+			mu.Lock()
+			results[chk.name] = r
+			mu.Unlock()
+		}(chk)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		if r.Status != StatusOK {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+func (c *Checker) run(ctx context.Context, chk *check) Result {
+	chk.mu.Lock()
+	defer chk.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(chk.expires) {
+		return chk.result
+	}
+
+	// 結果は他のリクエストとも共有するため、呼び出し元のキャンセルは引き継がない
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	err := chk.fn(checkCtx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: time.Since(now).Milliseconds(),
+		CheckedAt: now,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+
+	chk.result = result
+	chk.expires = now.Add(c.ttl)
+	return result
+}
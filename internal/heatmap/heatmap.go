@@ -0,0 +1,269 @@
+// Package heatmap はGitHubのコントリビューションカレンダーと同じ形式のヒートマップをSVGで描画します
+// データの取得は行わず、渡された日ごとの数と封印の記録だけから描画します
+package heatmap
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxWeeks は描画できる週の数の上限です（GitHubのカレンダーと同じ約1年分）
+const MaxWeeks = 53
+
+// 色の段階数の範囲（0件の色を含む）
+const (
+	minColors = 2
+	maxColors = 10
+)
+
+// ErrInvalidColors は色の指定が正しくない場合のエラーです
+var ErrInvalidColors = errors.New("色の指定が正しくありません")
+
+// Theme は背景や文字など、色の段階以外の配色です
+type Theme struct {
+	Background string
+	Text       string
+	// 封印の印の縁取り
+	Seal string
+	// 0件から多い順の色
+	Colors []string
+}
+
+// テーマ名
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// Themes はテーマ名ごとの配色です（GitHubの配色に合わせています）
+var Themes = map[string]Theme{
+	ThemeLight: {
+		Background: "#ffffff",
+		Text:       "#57606a",
+		Seal:       "#cf222e",
+		Colors:     []string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"},
+	},
+	ThemeDark: {
+		Background: "#0d1117",
+		Text:       "#8b949e",
+		Seal:       "#ff7b72",
+		Colors:     []string{"#161b22", "#0e4429", "#006d32", "#26a641", "#39d353"},
+	},
+}
+
+// Day は1日分のコントリビューションです
+type Day struct {
+	// 日付（時刻は使いません）
+	Date  time.Time
+	Count int
+	// その日のコントリビューションでダメージを与えたモンスターの名前（不明の場合は空）
+	Monster string
+}
+
+// Seal はモンスターを封印した記録です
+type Seal struct {
+	Date        time.Time
+	MonsterName string
+	// モンスターのアイコンのURL。空の場合は印だけを描画します
+	IconURL string
+}
+
+// Calendar は描画するデータです
+type Calendar struct {
+	// 1列目の週の最初の日（月曜日）
+	Start time.Time
+	// 最後の日。これより後のマスは描画しません
+	End   time.Time
+	Weeks int
+	Days  []Day
+	Seals []Seal
+}
+
+// ParseColors はカンマ区切りの色（"ebedf0,9be9a8,216e39" や "#fff,#000"）を色の段階として解釈します
+// 1つ目が0件の色で、2〜10色を指定できます
+func ParseColors(s string) ([]string, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < minColors || len(parts) > maxColors {
+		return nil, fmt.Errorf("%d〜%d色を指定してください: %w", minColors, maxColors, ErrInvalidColors)
+	}
+	colors := make([]string, 0, len(parts))
+	for _, part := range parts {
+		hex := strings.TrimPrefix(strings.TrimSpace(part), "#")
+		if !isHexColor(hex) {
+			return nil, fmt.Errorf("%q は16進数の色ではありません: %w", part, ErrInvalidColors)
+		}
+		colors = append(colors, "#"+strings.ToLower(hex))
+	}
+	return colors, nil
+}
+
+func isHexColor(s string) bool {
+	if len(s) != 3 && len(s) != 6 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// レイアウト（px）
+const (
+	cellSize    = 11
+	cellStep    = 14
+	leftMargin  = 30
+	topMargin   = 20
+	rightMargin = 10
+	legendSpace = 26
+)
+
+var weekdayLabels = []string{"月", "", "水", "", "金", "", ""}
+
+// Render はカレンダーをSVGとしてwに書き出します
+// 色の段階は期間中の最大値を段階数で等分して決めます
+func Render(w io.Writer, cal Calendar, theme Theme) error {
+	if cal.Weeks < 1 || cal.Weeks > MaxWeeks {
+		return fmt.Errorf("週の数は1〜%dで指定してください: %d", MaxWeeks, cal.Weeks)
+	}
+	if len(theme.Colors) < minColors {
+		return fmt.Errorf("色を%d色以上指定してください: %w", minColors, ErrInvalidColors)
+	}
+
+	days := make(map[string]Day, len(cal.Days))
+	maxCount := 0
+	for _, day := range cal.Days {
+		days[dateKey(day.Date)] = day
+		maxCount = max(maxCount, day.Count)
+	}
+	seals := make(map[string][]Seal)
+	for _, seal := range cal.Seals {
+		key := dateKey(seal.Date)
+		seals[key] = append(seals[key], seal)
+	}
+
+	width := leftMargin + cal.Weeks*cellStep + rightMargin
+	height := topMargin + 7*cellStep + legendSpace
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, width, height, width, height)
+	b.WriteString("\n")
+	fmt.Fprintf(b, `<style>text{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Helvetica,Arial,sans-serif;font-size:9px;fill:%s}</style>`, escape(theme.Text))
+	b.WriteString("\n")
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="%s"/>`, escape(theme.Background))
+	b.WriteString("\n")
+
+	// 曜日
+	for i, label := range weekdayLabels {
+		if label != "" {
+			fmt.Fprintf(b, `<text x="4" y="%d">%s</text>`, topMargin+i*cellStep+9, label)
+			b.WriteString("\n")
+		}
+	}
+
+	// 月（その月の最初の月曜日の列に表示する。1列目は次の月の表示と重ならない場合のみ）
+	for week := 0; week < cal.Weeks; week++ {
+		monday := cal.Start.AddDate(0, 0, week*7)
+		if week == 0 {
+			if monday.Day() > 21 {
+				continue
+			}
+		} else if monday.Month() == monday.AddDate(0, 0, -7).Month() {
+			continue
+		}
+		fmt.Fprintf(b, `<text x="%d" y="%d">%d月</text>`, leftMargin+week*cellStep, topMargin-8, int(monday.Month()))
+		b.WriteString("\n")
+	}
+
+	// マス
+	var markers []string
+	end := dateKey(cal.End)
+	for week := 0; week < cal.Weeks; week++ {
+		for weekday := 0; weekday < 7; weekday++ {
+			date := cal.Start.AddDate(0, 0, week*7+weekday)
+			key := dateKey(date)
+			if key > end {
+				break
+			}
+			day := days[key]
+			x := leftMargin + week*cellStep
+			y := topMargin + weekday*cellStep
+
+			title := fmt.Sprintf("%s: %dコントリビューション", key, day.Count)
+			if day.Monster != "" && day.Count > 0 {
+				title += "（" + day.Monster + "）"
+			}
+			for _, seal := range seals[key] {
+				title += "\n" + seal.MonsterName + "を封印"
+			}
+			fmt.Fprintf(b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" ry="2" fill="%s" data-date="%s" data-count="%d"><title>%s</title></rect>`,
+				x, y, cellSize, cellSize, escape(theme.Colors[level(day.Count, maxCount, len(theme.Colors))]), key, day.Count, escape(title))
+			b.WriteString("\n")
+
+			if daySeals := seals[key]; len(daySeals) > 0 {
+				// 同じ日に複数封印した場合は最後に封印したモンスターのアイコンを表示する
+				markers = append(markers, sealMarker(x, y, daySeals[len(daySeals)-1], theme))
+			}
+		}
+	}
+	// 封印の印は隣のマスにはみ出すため、すべてのマスの後に描画する
+	for _, marker := range markers {
+		b.WriteString(marker)
+		b.WriteString("\n")
+	}
+
+	// 凡例
+	legendY := topMargin + 7*cellStep + 8
+	legendX := width - rightMargin - len(theme.Colors)*cellStep - 28
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end">少ない</text>`, legendX-4, legendY+9)
+	b.WriteString("\n")
+	for i, color := range theme.Colors {
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" ry="2" fill="%s"/>`, legendX+i*cellStep, legendY, cellSize, cellSize, escape(color))
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, `<text x="%d" y="%d">多い</text>`, legendX+len(theme.Colors)*cellStep+2, legendY+9)
+	b.WriteString("\n</svg>\n")
+
+	return b.Flush()
+}
+
+// 封印した日のマスに重ねる印
+// アイコンを読み込めない環境（<img>で埋め込んだ場合など）でも分かるよう、縁取りの円の上にアイコンを重ねる
+func sealMarker(x, y int, seal Seal, theme Theme) string {
+	cx, cy := x+cellSize/2, y+cellSize/2
+	var s strings.Builder
+	fmt.Fprintf(&s, `<g class="seal"><title>%s</title>`, escape(seal.MonsterName+"を封印"))
+	fmt.Fprintf(&s, `<circle cx="%d" cy="%d" r="%d" fill="%s" stroke="%s" stroke-width="1.5"/>`, cx, cy, cellSize/2+2, escape(theme.Background), escape(theme.Seal))
+	if seal.IconURL != "" {
+		fmt.Fprintf(&s, `<image x="%d" y="%d" width="%d" height="%d" href="%s" xlink:href="%s" preserveAspectRatio="xMidYMid meet"/>`,
+			x-1, y-1, cellSize+2, cellSize+2, escape(seal.IconURL), escape(seal.IconURL))
+	}
+	s.WriteString(`</g>`)
+	return s.String()
+}
+
+// 0件は0段階目、それ以外は最大値を残りの段階数で等分して1段階目以上に割り当てる
+func level(count, maxCount, levels int) int {
+	if count <= 0 || maxCount <= 0 {
+		return 0
+	}
+	n := (count*(levels-1) + maxCount - 1) / maxCount
+	return min(max(n, 1), levels-1)
+}
+
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	// strings.Builderへの書き込みは失敗しない
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
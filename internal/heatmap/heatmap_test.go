@@ -0,0 +1,228 @@
+package heatmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// SVGの要素
+type element struct {
+	name  string
+	attrs map[string]string
+}
+
+// XMLとして読み込み、要素を出現順に返す（整形式でない場合は失敗する）
+func parseSVG(t *testing.T, svg []byte) []element {
+	t.Helper()
+	var elements []element
+	dec := xml.NewDecoder(bytes.NewReader(svg))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return elements
+		}
+		if err != nil {
+			t.Fatalf("SVGを読み込めません: %v\n%s", err, svg)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			attrs := make(map[string]string, len(start.Attr))
+			for _, a := range start.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			elements = append(elements, element{name: start.Name.Local, attrs: attrs})
+		}
+	}
+}
+
+func render(t *testing.T, cal Calendar, theme Theme) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, cal, theme); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRenderCells(t *testing.T) {
+	theme := Themes[ThemeLight]
+	// 2025-07-28は月曜日。2週目は水曜日（08-06）まで
+	cal := Calendar{
+		Start: date("2025-07-28"),
+		End:   date("2025-08-06"),
+		Weeks: 2,
+		Days: []Day{
+			{Date: date("2025-07-28"), Count: 1},
+			{Date: date("2025-07-30"), Count: 4, Monster: "スライム"},
+			{Date: date("2025-08-01"), Count: 2},
+			{Date: date("2025-08-04"), Count: 3},
+		},
+	}
+
+	cells := make(map[string]element)
+	var dates []string
+	for _, el := range parseSVG(t, render(t, cal, theme)) {
+		if d := el.attrs["data-date"]; el.name == "rect" && d != "" {
+			cells[d] = el
+			dates = append(dates, d)
+		}
+	}
+
+	// 1週目の7日と2週目の3日
+	if len(dates) != 10 || dates[0] != "2025-07-28" || dates[9] != "2025-08-06" {
+		t.Fatalf("マス = %v", dates)
+	}
+	// 最大（4件）を4段階に等分する
+	want := map[string]string{
+		"2025-07-28": theme.Colors[1],
+		"2025-07-29": theme.Colors[0],
+		"2025-07-30": theme.Colors[4],
+		"2025-08-01": theme.Colors[2],
+		"2025-08-04": theme.Colors[3],
+		"2025-08-06": theme.Colors[0],
+	}
+	for d, color := range want {
+		if got := cells[d].attrs["fill"]; got != color {
+			t.Errorf("%s の色 = %s, want %s", d, got, color)
+		}
+	}
+	if got := cells["2025-07-30"].attrs["data-count"]; got != "4" {
+		t.Errorf("data-count = %s", got)
+	}
+}
+
+func TestRenderSealMarkers(t *testing.T) {
+	theme := Themes[ThemeDark]
+	cal := Calendar{
+		Start: date("2025-07-28"),
+		End:   date("2025-08-03"),
+		Weeks: 1,
+		Days:  []Day{{Date: date("2025-07-30"), Count: 2, Monster: "<ドラゴン&>"}},
+		Seals: []Seal{
+			{Date: date("2025-07-29"), MonsterName: "スライム"},
+			{Date: date("2025-07-30"), MonsterName: "ゴブリン", IconURL: "https://example.com/goblin.png"},
+			{Date: date("2025-07-30"), MonsterName: "<ドラゴン&>", IconURL: "https://example.com/dragon.png?size=1&v=2"},
+		},
+	}
+	svg := render(t, cal, theme)
+	elements := parseSVG(t, svg)
+
+	var circles, images []element
+	for _, el := range elements {
+		switch el.name {
+		case "circle":
+			circles = append(circles, el)
+		case "image":
+			images = append(images, el)
+		}
+	}
+	// 封印した日ごとに1つ。同じ日に複数封印した場合は最後のモンスターのアイコン
+	if len(circles) != 2 {
+		t.Errorf("封印の印 = %d, want 2", len(circles))
+	}
+	for _, c := range circles {
+		if c.attrs["stroke"] != theme.Seal {
+			t.Errorf("印の縁取り = %s, want %s", c.attrs["stroke"], theme.Seal)
+		}
+	}
+	if len(images) != 1 || images[0].attrs["href"] != "https://example.com/dragon.png?size=1&v=2" {
+		t.Errorf("アイコン = %+v", images)
+	}
+	// 印はマスより後に描画する（隣のマスに隠れないように）
+	lastCell := slices.IndexFunc(elements, func(el element) bool { return el.attrs["data-date"] == "2025-08-03" })
+	firstCircle := slices.IndexFunc(elements, func(el element) bool { return el.name == "circle" })
+	if lastCell < 0 || firstCircle < lastCell {
+		t.Errorf("最後のマス = %d, 最初の印 = %d", lastCell, firstCircle)
+	}
+	// マスのツールチップに封印したモンスターを並べる（エスケープ済み）
+	if !strings.Contains(string(svg), "ゴブリンを封印&#xA;&lt;ドラゴン&amp;&gt;を封印") {
+		t.Errorf("ツールチップに封印したモンスターがありません:\n%s", svg)
+	}
+}
+
+func TestRenderCustomColors(t *testing.T) {
+	theme := Themes[ThemeLight]
+	theme.Colors = []string{"#000000", "#ffffff"}
+	cal := Calendar{Start: date("2025-07-28"), End: date("2025-07-29"), Weeks: 1, Days: []Day{{Date: date("2025-07-29"), Count: 1}}}
+
+	var fills []string
+	for _, el := range parseSVG(t, render(t, cal, theme)) {
+		if el.attrs["data-date"] != "" {
+			fills = append(fills, el.attrs["fill"])
+		}
+	}
+	if !slices.Equal(fills, []string{"#000000", "#ffffff"}) {
+		t.Errorf("色 = %v", fills)
+	}
+}
+
+func TestRenderValidates(t *testing.T) {
+	for _, weeks := range []int{0, MaxWeeks + 1} {
+		if err := Render(io.Discard, Calendar{Weeks: weeks}, Themes[ThemeLight]); err == nil {
+			t.Errorf("Weeks=%d: Render() error = nil", weeks)
+		}
+	}
+	theme := Themes[ThemeLight]
+	theme.Colors = theme.Colors[:1]
+	if err := Render(io.Discard, Calendar{Weeks: 1}, theme); !errors.Is(err, ErrInvalidColors) {
+		t.Errorf("1色: Render() error = %v", err)
+	}
+}
+
+func TestParseColors(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "ebedf0,9be9a8,216e39", want: []string{"#ebedf0", "#9be9a8", "#216e39"}},
+		{in: "#FFF, #000", want: []string{"#fff", "#000"}},
+		{in: "ebedf0", wantErr: true},
+		{in: "1,2,3,4,5,6,7,8,9,0,1", wantErr: true},
+		{in: "ebedf0,red", wantErr: true},
+		{in: "ebedf0,12345", wantErr: true},
+		{in: "ebedf0,", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColors(tt.in)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidColors)) {
+			t.Errorf("ParseColors(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("ParseColors(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct{ count, maxCount, levels, want int }{
+		{0, 10, 5, 0},
+		{1, 10, 5, 1},
+		{3, 10, 5, 2},
+		{5, 10, 5, 2},
+		{6, 10, 5, 3},
+		{10, 10, 5, 4},
+		{1, 100, 5, 1},
+		{1, 1, 2, 1},
+		{5, 0, 5, 0},
+	}
+	for _, tt := range tests {
+		if got := level(tt.count, tt.maxCount, tt.levels); got != tt.want {
+			t.Errorf("level(%d, %d, %d) = %d, want %d", tt.count, tt.maxCount, tt.levels, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted はマスクした値の代わりに出力する文字列です
+const Redacted = "[REDACTED]"
+
+// キー名で判定してマスクする属性（小文字・記号なしで比較）
+var sensitiveKeys = map[string]bool{
+	"token":             true,
+	"accesstoken":       true,
+	"githubtoken":       true,
+	"githubaccesstoken": true,
+	"idtoken":           true,
+	"authorization":     true,
+	"password":          true,
+	"secret":            true,
+	"email":             true,
+	"photourl":          true,
+	"avatarurl":         true,
+	"credentials":       true,
+}
+
+// 値の中身で判定してマスクするパターン（キー名に関係なく適用）
+var sensitiveValuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`),
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`https://avatars\.githubusercontent\.com/\S*`),
+}
+
+// ParseLevel は "debug" / "info" / "warn" / "error" をslog.Levelに変換します
+// 不明な値の場合はinfoを返します
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Setup はJSON形式のロガーを作成し、slogとlogパッケージのデフォルトに設定します
+// トークン・メールアドレス・写真URLなどは自動でマスクされ、
+// contextにリクエストIDがあれば request_id として出力されます
+func Setup(w io.Writer, level string) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	})
+	logger := slog.New(&contextHandler{Handler: handler})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// redactAttr は機密情報を含む属性をマスクします
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		// エラーメッセージにトークンなどが含まれる場合があるため文字列化してマスクする
+		if err, ok := a.Value.Any().(error); ok && err != nil {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[normalized]
+}
+
+// RedactString は文字列に含まれるトークン・メールアドレス・アバターURLをマスクします
+func RedactString(s string) string {
+	for _, p := range sensitiveValuePatterns {
+		s = p.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+type requestIDKey struct{}
+
+// WithRequestID はリクエストIDをcontextに設定します
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID はcontextからリクエストIDを取得します
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// contextHandler はcontextのリクエストID・トレースIDをログに追加するハンドラーです
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	// トレースが有効な場合は、ログとトレースを紐付けられるようにIDを出力する
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader はリクエストIDを受け渡すヘッダー名です
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware はリクエストIDの付与とアクセスログの出力を行うミドルウェアを返します
+// クライアントから有効なX-Request-IDが送られた場合はそれを引き継ぎ、なければ生成します。
+// リクエストIDはc.Request.Context()経由でサービス・リポジトリ層のログにも出力されます
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		ctx := WithRequestID(c.Request.Context(), requestID)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.Last().Error()))
+		}
+		slog.LogAttrs(ctx, level, "リクエストを処理しました", attrs...)
+	}
+}
+
+// 外部から受け取るリクエストIDはログを汚さないよう英数字と一部の記号のみ許可する
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
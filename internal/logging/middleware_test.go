@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareAccessLogRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/contributions/:id", func(c *gin.Context) {
+		_ = c.Error(errors.New("GitHubのトークン " + testGitHubToken + " が無効です: " + testEmail))
+		c.Status(http.StatusUnauthorized)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/contributions/u1", nil)
+	req.Header.Set("Authorization", "Bearer "+testIDToken)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assertNotLogged(t, &buf, testIDToken, testGitHubToken, testEmail)
+	line := decodeLines(t, &buf)[0]
+	if line["level"] != "WARN" || line["status"] != float64(http.StatusUnauthorized) || line["route"] != "/contributions/:id" || line["request_id"] != "req-1" {
+		t.Errorf("アクセスログ = %v", line)
+	}
+	if w.Header().Get(RequestIDHeader) != "req-1" {
+		t.Errorf("%s = %q", RequestIDHeader, w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestMiddlewareRejectsInvalidRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(t, &buf)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n{\"level\":\"ERROR\"}")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	id := w.Header().Get(RequestIDHeader)
+	if !isValidRequestID(id) || len(id) != 32 {
+		t.Errorf("生成したリクエストID = %q", id)
+	}
+	if line := decodeLines(t, &buf)[0]; line["request_id"] != id {
+		t.Errorf("request_id = %v, want %s", line["request_id"], id)
+	}
+}
@@ -0,0 +1,263 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
+)
+
+// メトリクス名のプレフィックス
+const namespace = "grasschain"
+
+// コントリビューション同期の結果
+const (
+	// 新しいコントリビューションがなかった
+	SyncOutcomeNoop = "noop"
+	// 進捗が増えた（封印には至らなかった）
+	SyncOutcomeProgress = "progress"
+	// モンスターを封印した
+	SyncOutcomeSeal = "seal"
+	// GitHubに接続できず、前回の進捗を返した
+	SyncOutcomeStale = "stale"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "ルート・ステータスごとのHTTPリクエスト数",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "ルートごとのHTTPリクエストの処理時間",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	githubRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "github_graphql_request_duration_seconds",
+		Help:      "GitHub GraphQL APIの呼び出し時間",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15},
+	}, []string{"operation", "outcome"})
+
+	githubErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_graphql_errors_total",
+		Help:      "GitHub GraphQL APIの呼び出しエラー数（codeはエラーレスポンスのcode）",
+	}, []string{"operation", "code"})
+
+	githubRateLimitRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_rate_limit_remaining",
+		Help:      "直近のGitHub APIレスポンスのX-RateLimit-Remaining",
+	})
+
+	githubRetriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_graphql_retries_total",
+		Help:      "GitHub GraphQL APIの呼び出しを再試行した回数",
+	})
+
+	githubCircuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_circuit_breaker_state",
+		Help:      "GitHub APIのサーキットブレーカーの状態 (0: closed, 1: half-open, 2: open)",
+	})
+
+	firestoreOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "firestore_operations_total",
+		Help:      "Firestoreの操作回数",
+	}, []string{"operation", "collection"})
+
+	firestoreErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "firestore_operation_errors_total",
+		Help:      "Firestoreの操作エラー数（codeはgRPCのステータスコード）",
+	}, []string{"operation", "collection", "code"})
+
+	contributionsCreditedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "contributions_credited_total",
+		Help:      "モンスターの進捗に反映したコントリビューション数",
+	})
+
+	monstersSealedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "monsters_sealed_total",
+		Help:      "モンスターIDごとの封印数",
+	}, []string{"monster_id"})
+
+	contributionSyncsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "contribution_syncs_total",
+		Help:      "コントリビューション同期の結果ごとの回数 (noop, progress, seal, stale)",
+	}, []string{"outcome"})
+
+	cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "キャッシュの参照結果ごとの回数 (hit, miss, error)",
+	}, []string{"cache", "result"})
+
+	notificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "プッシュ通知のカテゴリ・結果ごとの回数 (sent, error, opted_out, no_devices)",
+	}, []string{"category", "result"})
+
+	webhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "webhookへの送信の形式・結果ごとの回数 (succeeded, failed)",
+	}, []string{"format", "result"})
+
+	adminActionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admin_actions_total",
+		Help:      "管理者APIでユーザーのデータを変更した操作ごとの回数",
+	}, []string{"action"})
+
+	outboxEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_total",
+		Help:      "ドメインイベントの種類・配送結果ごとの回数 (delivered, retry, dead_letter)",
+	}, []string{"type", "result"})
+
+	rateLimitRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejected_total",
+		Help:      "レート制限で拒否したリクエスト数",
+	}, []string{"policy"})
+)
+
+// Handler はPrometheusのテキスト形式でメトリクスを返すハンドラーです
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware はルートごとのHTTPリクエスト数と処理時間を記録するミドルウェアを返します
+// ラベルにはパスではなくルート（/users/:id など）を使うため、ユーザーIDごとに系列が増えることはありません
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveGitHubRequest はGitHub GraphQL APIの呼び出し結果を記録します
+func ObserveGitHubRequest(operation string, elapsed time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		githubErrorsTotal.WithLabelValues(operation, apperrors.Code(err)).Inc()
+	}
+	githubRequestDuration.WithLabelValues(operation, outcome).Observe(elapsed.Seconds())
+}
+
+// SetGitHubRateLimitRemaining はGitHub APIの残りリクエスト数を記録します
+func SetGitHubRateLimitRemaining(remaining int) {
+	githubRateLimitRemaining.Set(float64(remaining))
+}
+
+// IncGitHubRetry はGitHub GraphQL APIの再試行回数を加算します
+func IncGitHubRetry() {
+	githubRetriesTotal.Inc()
+}
+
+// SetGitHubCircuitState はGitHub APIのサーキットブレーカーの状態を記録します
+// stateはgobreaker.Stateの値 (0: closed, 1: half-open, 2: open) です
+func SetGitHubCircuitState(state int) {
+	githubCircuitState.Set(float64(state))
+}
+
+// ObserveFirestoreOperation はFirestoreの操作結果を記録します
+// operationは get, query, set, update, delete, add, transaction, list_collections のいずれかです
+func ObserveFirestoreOperation(operation, collection string, err error) {
+	firestoreOperationsTotal.WithLabelValues(operation, collection).Inc()
+	if err != nil {
+		// ラップされたエラーからもgRPCのステータスコードを取り出す。ステータスを持たないエラーはUnknownになる
+		firestoreErrorsTotal.WithLabelValues(operation, collection, status.Code(err).String()).Inc()
+	}
+}
+
+// AddContributionsCredited はモンスターの進捗に反映したコントリビューション数を加算します
+func AddContributionsCredited(n int) {
+	if n > 0 {
+		contributionsCreditedTotal.Add(float64(n))
+	}
+}
+
+// IncMonsterSealed はモンスターの封印数を加算します
+func IncMonsterSealed(monsterID string) {
+	monstersSealedTotal.WithLabelValues(monsterID).Inc()
+}
+
+// IncContributionSync はコントリビューション同期の結果を記録します
+func IncContributionSync(outcome string) {
+	contributionSyncsTotal.WithLabelValues(outcome).Inc()
+}
+
+// キャッシュの参照結果
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// IncCacheRequest はキャッシュの参照結果を記録します
+func IncCacheRequest(cache, result string) {
+	cacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// プッシュ通知の結果
+const (
+	NotificationSent      = "sent"
+	NotificationError     = "error"
+	NotificationOptedOut  = "opted_out"
+	NotificationNoDevices = "no_devices"
+)
+
+// IncNotification はプッシュ通知の結果を記録します
+func IncNotification(category, result string) {
+	notificationsTotal.WithLabelValues(category, result).Inc()
+}
+
+// IncWebhookDelivery はwebhookへの送信の結果を記録します
+// resultにはmodels.WebhookDeliverySucceeded・models.WebhookDeliveryFailedを渡します
+func IncWebhookDelivery(format, result string) {
+	webhookDeliveriesTotal.WithLabelValues(format, result).Inc()
+}
+
+// IncAdminAction は管理者APIでユーザーのデータを変更した操作を記録します
+func IncAdminAction(action string) {
+	adminActionsTotal.WithLabelValues(action).Inc()
+}
+
+// IncOutboxEvent はドメインイベントの配送結果を記録します
+func IncOutboxEvent(eventType, result string) {
+	outboxEventsTotal.WithLabelValues(eventType, result).Inc()
+}
+
+// IncRateLimitRejected はレート制限で拒否したリクエスト数を加算します
+func IncRateLimitRejected(policy string) {
+	rateLimitRejectedTotal.WithLabelValues(policy).Inc()
+}
@@ -2,114 +2,166 @@ package middleware
 
 import (
 	"context"
-	"log"
-	"net/http"
-	"os"
+	"fmt"
+	"log/slog"
 	"strings"
 
+	"geekcamp-vol10-backend/internal/apperrors"
+
 	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"google.golang.org/api/option"
 )
 
-// firebaseAppをグローバル変数として保持
-var firebaseApp *firebase.App
-
-func init() {
-	ctx := context.Background()
-	var err error
-
-	// --- エミュレータ利用判定 ---
-	// FIREBASE_AUTH_EMULATOR_HOST 環境変数が設定されている場合、エミュレータに接続します。
-	// 例: FIREBASE_AUTH_EMULATOR_HOST="localhost:9099"
-	godotenv.Load()
-	if os.Getenv("FIREBASE_AUTH_EMULATOR_HOST") != "" {
-		// エミュレータモード
-		log.Println("エミュレータモードで初期化します。")
-
-		// エミュレータ利用時はサービスアカウントキーは不要ですが、プロジェクトIDが必要です。
-		// GCLOUD_PROJECT 環境変数から取得します。
-		// 例: GCLOUD_PROJECT="your-project-id"
-		projectID := os.Getenv("GCLOUD_PROJECT")
-		if projectID == "" {
-			log.Fatalf("エミュレータ利用時は環境変数 'GCLOUD_PROJECT' が必要です。")
-		}
-
-		conf := &firebase.Config{
-			ProjectID: projectID,
-		}
-		firebaseApp, err = firebase.NewApp(ctx, conf)
+// Contextに保存するキー
+const (
+	// 検証済みのFirebaseのUID
+	ContextKeyFirebaseUID = "firebase_uid"
+	// GitHubのアクセストークン
+	ContextKeyGitHubAccessToken = "githubAccessToken"
+	// IDトークンに管理者のカスタムクレーム（admin: true）があるか
+	ContextKeyAdmin = "admin"
+)
 
-	} else {
-		// 本番環境モード
-		log.Println("本番環境のFirebaseで初期化します。")
+// カスタムクレームの名前
+const (
+	// 管理者
+	adminClaim = "admin"
+	// GitHubのアクセストークン
+	githubAccessTokenClaim = "githubAccessToken"
+)
 
-		// 従来通り、サービスアカウントキーのJSONファイルへのパスを環境変数から取得します。
-		// 例: CREDENTIALS="./path/to/your/serviceAccountKey.json"
-		credentialsPath := os.Getenv("CREDENTIALS")
-		if credentialsPath == "" {
-			log.Fatalf("本番環境利用時は環境変数 'CREDENTIALS' が設定されていません。")
-		}
-		opt := option.WithCredentialsFile(credentialsPath)
-		firebaseApp, err = firebase.NewApp(ctx, nil, opt)
+// AuthMiddleware はFirebase AuthenticationのIDトークンを検証するミドルウェアを返します
+// Firebaseアプリはdatabase.NewFirebaseで作成したものを共有します（エミュレータの判定もそちらで行います）。
+// Authクライアントはここで1度だけ作成し、公開鍵のキャッシュをリクエスト間で使い回します
+func AuthMiddleware(ctx context.Context, app *firebase.App) (gin.HandlerFunc, error) {
+	if app == nil {
+		return nil, fmt.Errorf("Firebaseアプリが初期化されていません")
 	}
-
+	authClient, err := app.Auth(ctx)
 	if err != nil {
-		log.Fatalf("Firebaseの初期化に失敗しました: %v", err)
+		return nil, fmt.Errorf("Authクライアントの取得に失敗しました: %w", err)
 	}
-}
 
-// AuthMiddleware はGinのミドルウェアとして機能する関数を返す
-func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Firebase Authクライアントを取得
-		authClient, err := firebaseApp.Auth(context.Background())
-		if err != nil {
-			log.Printf("Authクライアントの取得に失敗しました: %v", err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラー"})
-			return
-		}
+		ctx := c.Request.Context()
 
 		// リクエストヘッダーから "Authorization" を取得
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorizationヘッダーが必要です"})
+			apperrors.Abort(c, fmt.Errorf("Authorizationヘッダーがありません: %w", apperrors.ErrUnauthenticated))
 			return
 		}
 
 		// "Bearer "の部分を削除してIDトークンを抽出
-		idToken := strings.Replace(authHeader, "Bearer ", "", 1)
+		idToken := strings.TrimSpace(strings.Replace(authHeader, "Bearer ", "", 1))
 		if idToken == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "トークンが空です"})
+			apperrors.Abort(c, fmt.Errorf("トークンが空です: %w", apperrors.ErrUnauthenticated))
 			return
 		}
 
 		// IDトークンを検証
-		token, err := authClient.VerifyIDToken(context.Background(), idToken)
+		token, err := authClient.VerifyIDToken(ctx, idToken)
 		if err != nil {
-			log.Printf("IDトークンの検証に失敗しました: %v", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "無効なトークンです"})
+			slog.WarnContext(ctx, "IDトークンの検証に失敗しました", "error", err)
+			apperrors.Abort(c, fmt.Errorf("IDトークンの検証に失敗しました: %w", apperrors.ErrUnauthenticated))
 			return
 		}
 
 		// トークンのClaims(クレーム)からGitHubのアクセストークンを抽出
-		claims := token.Claims
-		githubAccessToken, ok := claims["githubAccessToken"].(string) // ゆうきさんに確認、claimsに入れてもらう？
+		githubAccessToken, ok := token.Claims[githubAccessTokenClaim].(string) // ゆうきさんに確認、claimsに入れてもらう？
 		if !ok {
 			// カスタムクレームが存在しない、または文字列ではない場合
-			log.Printf("UID: %s のトークンに 'githubAccessToken' が見つかりません。", token.UID)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "GitHubアクセストークンが見つかりません"})
+			slog.WarnContext(ctx, "トークンに 'githubAccessToken' が見つかりません。", "uid", token.UID)
+			apperrors.Abort(c, apperrors.ErrGitHubTokenMissing)
 			return
 		}
 
-		// (オプション) 検証したユーザーIDをContextに保存して、後続のハンドラで利用できるようにします
-		c.Set("firebase_uid", token.UID)
-		c.Set("githubAccessToken", githubAccessToken)
+		// 検証したユーザーIDをContextに保存して、後続のハンドラで利用できるようにします
+		c.Set(ContextKeyFirebaseUID, token.UID)
+		c.Set(ContextKeyGitHubAccessToken, githubAccessToken)
+		c.Set(ContextKeyAdmin, token.Claims[adminClaim] == true)
 
 		// 検証に成功した場合、次の処理（ハンドラ）へ進みます
 		c.Next()
+	}, nil
+}
+
+// RequireAdmin は管理者のカスタムクレームのないリクエストを403で拒否するミドルウェアを返します
+// AuthMiddlewareの後に使います
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(ContextKeyAdmin) {
+			slog.WarnContext(c.Request.Context(), "管理者ではないユーザーが管理者APIを呼び出しました", "uid", c.GetString(ContextKeyFirebaseUID), "path", c.FullPath())
+			apperrors.Abort(c, apperrors.ErrAdminRequired)
+			return
+		}
+		c.Next()
 	}
 }
 
-// func Auth
+// RequireSelf はパスパラメータnameのユーザーが認証したユーザー本人ではないリクエストを403で拒否するミドルウェアを返します
+// 管理者のカスタムクレームがある場合は他のユーザーへのリクエストも許可します。AuthMiddlewareの後に使います
+func RequireSelf(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := c.GetString(ContextKeyFirebaseUID)
+		if uid == "" || (c.Param(name) != uid && !c.GetBool(ContextKeyAdmin)) {
+			slog.WarnContext(c.Request.Context(), "他のユーザーのデータへのリクエストを拒否しました", "uid", uid, "path", c.FullPath())
+			apperrors.Abort(c, apperrors.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// TargetUserGitHubToken はパスパラメータnameのユーザーのGitHubアクセストークンを、Firebase Authenticationのカスタムクレームから取得して
+// Contextに設定するミドルウェアを返します（管理者が他のユーザーのデータをGitHubから同期する場合に使います）
+// 管理者自身のトークンでは対象のユーザーから見えないリポジトリのコントリビューションまで数えてしまうため、
+// カスタムクレームにトークンがない場合は管理者のトークンを使わずに403で拒否します。AuthMiddlewareの後に使います
+func TargetUserGitHubToken(ctx context.Context, app *firebase.App, name string) (gin.HandlerFunc, error) {
+	if app == nil {
+		return nil, fmt.Errorf("Firebaseアプリが初期化されていません")
+	}
+	authClient, err := app.Auth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Authクライアントの取得に失敗しました: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := c.Param(name)
+
+		user, err := authClient.GetUser(ctx, uid)
+		if auth.IsUserNotFound(err) {
+			apperrors.Abort(c, fmt.Errorf("Firebase Authenticationにユーザー %s がいません: %w", uid, apperrors.ErrUserNotFound))
+			return
+		}
+		if err != nil {
+			apperrors.Abort(c, fmt.Errorf("ユーザー %s の取得に失敗しました: %w", uid, err))
+			return
+		}
+		githubAccessToken, ok := user.CustomClaims[githubAccessTokenClaim].(string)
+		if !ok || githubAccessToken == "" {
+			slog.WarnContext(ctx, "対象のユーザーのカスタムクレームに 'githubAccessToken' が見つかりません。", "uid", uid, "admin_uid", c.GetString(ContextKeyFirebaseUID))
+			apperrors.Abort(c, fmt.Errorf("ユーザー %s のGitHubアクセストークンがありません: %w", uid, apperrors.ErrGitHubTokenMissing))
+			return
+		}
+
+		c.Set(ContextKeyGitHubAccessToken, githubAccessToken)
+		c.Next()
+	}, nil
+}
+
+// GitHubTokenFallback は認証ミドルウェアを使わない環境（ローカル開発など）向けに、
+// 設定のGITHUB_TOKENをGitHubのアクセストークンとしてContextに設定するミドルウェアを返します
+// AuthMiddlewareでトークンが設定済みの場合は上書きしません
+func GitHubTokenFallback(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			if _, exists := c.Get(ContextKeyGitHubAccessToken); !exists {
+				c.Set(ContextKeyGitHubAccessToken, token)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,263 @@
+// Package migrations はFirestoreのユーザーデータの形式を段階的に変換します
+//
+// usersドキュメントのschemaVersionに適用済みのマイグレーションのバージョンを記録し、
+// それより新しいマイグレーションだけを順番に適用します。
+// マイグレーション1つ分の読み込み・書き込みとschemaVersionの更新は1つのトランザクションで行うため、
+// 途中で中断しても、再実行すると続きから適用されます。
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Migration はユーザー1人分のデータを変換する処理です
+type Migration struct {
+	// 適用後のschemaVersion。1から連番で定義します
+	Version int
+	Name    string
+	// Apply はuのユーザーのデータを変換します
+	// 書き込みはuのメソッドで行い、変換が不要な場合は何も書き込まずにnilを返してください
+	Apply func(ctx context.Context, u *User) error
+}
+
+// User はマイグレーション中のユーザーです
+// 読み込み・書き込みはすべてマイグレーションのトランザクション内で行います
+type User struct {
+	ID   string
+	Ref  *firestore.DocumentRef
+	Data map[string]interface{}
+
+	tx      *firestore.Transaction
+	changes []Change
+}
+
+// Change はマイグレーションによる1ドキュメント分の変更です
+type Change struct {
+	// users/{id}/... の形式のパス
+	Path   string
+	Op     string
+	Detail string
+}
+
+// Documents はトランザクション内でクエリを実行します
+func (u *User) Documents(q firestore.Queryer) ([]*firestore.DocumentSnapshot, error) {
+	return u.tx.Documents(q).GetAll()
+}
+
+// Set はドキュメントを上書きします。detailには変更内容の説明を渡します
+func (u *User) Set(ref *firestore.DocumentRef, data map[string]interface{}, detail string) error {
+	u.record(ref, "set", detail)
+	return u.tx.Set(ref, data)
+}
+
+// Update はドキュメントの指定フィールドを更新します
+func (u *User) Update(ref *firestore.DocumentRef, updates []firestore.Update, detail string) error {
+	u.record(ref, "update", detail)
+	return u.tx.Update(ref, updates)
+}
+
+// Delete はドキュメントを削除します
+func (u *User) Delete(ref *firestore.DocumentRef, detail string) error {
+	u.record(ref, "delete", detail)
+	return u.tx.Delete(ref)
+}
+
+func (u *User) record(ref *firestore.DocumentRef, op, detail string) {
+	u.changes = append(u.changes, Change{Path: documentPath(ref), Op: op, Detail: detail})
+}
+
+// "projects/{p}/databases/{d}/documents/users/..." から "users/..." を取り出す
+func documentPath(ref *firestore.DocumentRef) string {
+	if _, path, ok := strings.Cut(ref.Path, "/documents/"); ok {
+		return path
+	}
+	return ref.Path
+}
+
+// Options はマイグレーションの実行方法です
+type Options struct {
+	// trueの場合は変更内容をログに出すだけで書き込みません
+	DryRun bool
+	// このバージョンまで適用します。0の場合は最新まで
+	Target int
+	// 指定した場合はそのユーザーのみ
+	UserID string
+}
+
+// Result はマイグレーションの実行結果です
+type Result struct {
+	Users    int
+	Migrated int
+	UpToDate int
+	Failed   int
+	// 書き込んだ（dry-runの場合は書き込む予定の）変更の数
+	Changes int
+}
+
+// dry-runの場合に書き込みを破棄するため、トランザクションの関数から返すエラー
+var errDryRun = errors.New("dry-run")
+
+// 1回のクエリで読み込むユーザー数
+const userPageSize = 100
+
+// Runner はユーザーごとにマイグレーションを適用します
+type Runner struct {
+	client     *firestore.Client
+	migrations []Migration
+}
+
+// NewRunner はRunnerを作成します
+// migrationsはVersionが1から連番で並び、最後がmodels.UserSchemaVersionである必要があります
+func NewRunner(client *firestore.Client, migrations []Migration) (*Runner, error) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("マイグレーション %q のバージョンが連番になっていません: %d（期待値 %d）", m.Name, m.Version, i+1)
+		}
+	}
+	if len(migrations) != models.UserSchemaVersion {
+		return nil, fmt.Errorf("最新のマイグレーション(%d)と models.UserSchemaVersion(%d) が一致しません", len(migrations), models.UserSchemaVersion)
+	}
+	return &Runner{
+		client:     client,
+		migrations: migrations,
+	}, nil
+}
+
+// Run はユーザーをドキュメントID順に読み込み、未適用のマイグレーションを適用します
+// 失敗したユーザーはそのマイグレーションの前のバージョンのまま残し、次のユーザーに進みます
+func (r *Runner) Run(ctx context.Context, opts Options) (Result, error) {
+	target := opts.Target
+	if target == 0 {
+		target = len(r.migrations)
+	}
+	if target < 0 || target > len(r.migrations) {
+		return Result{}, fmt.Errorf("バージョンは1〜%dで指定してください: %d", len(r.migrations), target)
+	}
+
+	var result Result
+	users := r.client.Collection("users")
+	if opts.UserID != "" {
+		r.migrateUser(ctx, users.Doc(opts.UserID), target, opts.DryRun, &result)
+		return result, nil
+	}
+
+	var last *firestore.DocumentSnapshot
+	for {
+		query := users.OrderBy(firestore.DocumentID, firestore.Asc).Limit(userPageSize)
+		if last != nil {
+			query = query.StartAfter(last)
+		}
+		// スナップショットの中身は使わず、IDだけを使う（トランザクション内で読み直す）
+		docs, err := query.Select().Documents(ctx).GetAll()
+		if err != nil {
+			return result, fmt.Errorf("ユーザーの一覧の取得に失敗しました: %w", err)
+		}
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			r.migrateUser(ctx, doc.Ref, target, opts.DryRun, &result)
+		}
+		if len(docs) < userPageSize {
+			return result, nil
+		}
+		last = docs[len(docs)-1]
+	}
+}
+
+// migrateUser はユーザー1人にtargetまでのマイグレーションを順に適用します
+func (r *Runner) migrateUser(ctx context.Context, ref *firestore.DocumentRef, target int, dryRun bool, result *Result) {
+	result.Users++
+	migrated := false
+
+	for _, m := range r.migrations[:target] {
+		var (
+			applied bool
+			changes []Change
+			from    int
+		)
+		err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			applied, changes = false, nil
+
+			snap, err := tx.Get(ref)
+			if err != nil {
+				return err
+			}
+			from = SchemaVersion(snap.Data())
+			if from >= m.Version {
+				return nil
+			}
+
+			u := &User{ID: ref.ID, Ref: ref, Data: snap.Data(), tx: tx}
+			if err := m.Apply(ctx, u); err != nil {
+				return err
+			}
+			applied, changes = true, u.changes
+
+			if dryRun {
+				return errDryRun
+			}
+			return tx.Update(ref, []firestore.Update{{Path: "schemaVersion", Value: m.Version}})
+		})
+		if errors.Is(err, errDryRun) {
+			err = nil
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "マイグレーションに失敗しました", "user_id", ref.ID, "version", m.Version, "migration", m.Name, "error", err)
+			result.Failed++
+			return
+		}
+		if !applied {
+			continue
+		}
+
+		migrated = true
+		result.Changes += len(changes)
+		for _, c := range changes {
+			slog.InfoContext(ctx, "変更",
+				"dry_run", dryRun,
+				"user_id", ref.ID,
+				"version", m.Version,
+				"migration", m.Name,
+				"path", c.Path,
+				"op", c.Op,
+				"detail", c.Detail,
+			)
+		}
+		slog.InfoContext(ctx, "マイグレーションを適用しました",
+			"dry_run", dryRun,
+			"user_id", ref.ID,
+			"from", from,
+			"to", m.Version,
+			"migration", m.Name,
+			"changes", len(changes),
+		)
+	}
+
+	if migrated {
+		result.Migrated++
+	} else {
+		result.UpToDate++
+	}
+}
+
+// SchemaVersion はusersドキュメントのschemaVersionを返します
+// schemaVersionがない（マイグレーションの仕組みを入れる前に作成された）場合は0を返します
+func SchemaVersion(data map[string]interface{}) int {
+	switch v := data["schemaVersion"].(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,153 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+func TestAllVersions(t *testing.T) {
+	if _, err := NewRunner(nil, All); err != nil {
+		t.Fatalf("NewRunner(All) error = %v", err)
+	}
+}
+
+func TestNewRunnerValidatesVersions(t *testing.T) {
+	noop := func(context.Context, *User) error { return nil }
+	tests := []struct {
+		name       string
+		migrations []Migration
+	}{
+		{name: "連番ではない", migrations: []Migration{{Version: 1, Apply: noop}, {Version: 3, Apply: noop}, {Version: 4, Apply: noop}}},
+		{name: "UserSchemaVersionと一致しない", migrations: All[:len(All)-1]},
+	}
+	for _, tt := range tests {
+		if _, err := NewRunner(nil, tt.migrations); err == nil {
+			t.Errorf("%s: NewRunner() error = nil", tt.name)
+		}
+	}
+}
+
+func TestRunValidatesTarget(t *testing.T) {
+	r, err := NewRunner(nil, All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []int{-1, len(All) + 1} {
+		if _, err := r.Run(context.Background(), Options{Target: target}); err == nil {
+			t.Errorf("Run(Target: %d) error = nil", target)
+		}
+	}
+}
+
+func TestDocumentPath(t *testing.T) {
+	ref := &firestore.DocumentRef{Path: "projects/p/databases/(default)/documents/users/u1/sealedMonsters/s1"}
+	if got := documentPath(ref); got != "users/u1/sealedMonsters/s1" {
+		t.Errorf("documentPath() = %q", got)
+	}
+}
+
+// Firestoreエミュレータ（FIRESTORE_EMULATOR_HOST）に接続する。設定されていない場合はテストをスキップする
+func newEmulatorClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST が設定されていないためスキップします")
+	}
+	client, err := firestore.NewClient(context.Background(), "migrations-test")
+	if err != nil {
+		t.Fatalf("Firestoreクライアントの作成に失敗しました: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// schemaVersionのない（バージョン0の）ユーザーを作成する
+func seedLegacyUser(t *testing.T, client *firestore.Client, id string) *firestore.DocumentRef {
+	t.Helper()
+	ctx := context.Background()
+	createdAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	ref := client.Collection("users").Doc(id)
+	writes := []struct {
+		ref  *firestore.DocumentRef
+		data map[string]interface{}
+	}{
+		{ref, map[string]interface{}{"githubUserName": "plmwa", "createdAt": createdAt}},
+		{ref.Collection("sealedMonsters").Doc("s1"), map[string]interface{}{"monsterId": "monster-1", "sealedAt": "2025-07-01T09:00:00+09:00"}},
+		{ref.Collection("currentMonster").Doc("monster"), map[string]interface{}{"monsterId": "monster-2", "lastContributionReflectedAt": createdAt}},
+	}
+	for _, w := range writes {
+		if _, err := w.ref.Set(ctx, w.data); err != nil {
+			t.Fatalf("%s の作成に失敗しました: %v", w.ref.Path, err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, w := range writes {
+			w.ref.Delete(context.Background())
+		}
+		ref.Collection("currentMonster").Doc("monster-2").Delete(context.Background())
+	})
+	return ref
+}
+
+func TestRunDryRun(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+	id := "dry-run-" + time.Now().Format("150405.000000000")
+	ref := seedLegacyUser(t, client, id)
+
+	r, err := NewRunner(client, All)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Run(ctx, Options{DryRun: true, UserID: id})
+	if err != nil {
+		t.Fatalf("Run(DryRun) error = %v", err)
+	}
+	// sealedAtの変換、currentMonsterの移動（set・delete）、lastContributionReflectedAtの追加
+	if result.Users != 1 || result.Migrated != 1 || result.Failed != 0 || result.Changes != 4 {
+		t.Errorf("Run(DryRun) = %+v", result)
+	}
+
+	// dry-runでは何も書き込まない
+	snap, err := ref.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SchemaVersion(snap.Data()) != 0 {
+		t.Errorf("dry-runでschemaVersionが更新されました: %v", snap.Data()["schemaVersion"])
+	}
+	if _, ok := snap.Data()["lastContributionReflectedAt"]; ok {
+		t.Error("dry-runでlastContributionReflectedAtが書き込まれました")
+	}
+	sealed, err := ref.Collection("sealedMonsters").Doc("s1").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sealed.Data()["sealedAt"].(string); !ok {
+		t.Errorf("dry-runでsealedAtが変換されました: %T", sealed.Data()["sealedAt"])
+	}
+	if _, err := ref.Collection("currentMonster").Doc("monster").Get(ctx); err != nil {
+		t.Errorf("dry-runでcurrentMonsterが移動されました: %v", err)
+	}
+
+	// 実際に適用した後は最新のバージョンになり、再実行しても変更はない
+	if result, err := r.Run(ctx, Options{UserID: id}); err != nil || result.Migrated != 1 || result.Changes != 4 {
+		t.Fatalf("Run() = %+v, %v", result, err)
+	}
+	snap, err = ref.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := SchemaVersion(snap.Data()); got != models.UserSchemaVersion {
+		t.Errorf("schemaVersion = %d, want %d", got, models.UserSchemaVersion)
+	}
+	if result, err := r.Run(ctx, Options{DryRun: true, UserID: id}); err != nil || result.UpToDate != 1 || result.Changes != 0 {
+		t.Errorf("適用後のRun(DryRun) = %+v, %v", result, err)
+	}
+}
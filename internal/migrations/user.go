@@ -0,0 +1,118 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// All は適用する順に並べたマイグレーションです
+// 追加する場合は末尾にVersionを1つ増やして追加し、models.UserSchemaVersionも合わせて上げてください
+var All = []Migration{
+	{Version: 1, Name: "normalize_sealed_at", Apply: normalizeSealedAt},
+	{Version: 2, Name: "current_monster_doc_id", Apply: normalizeCurrentMonsterDocID},
+	{Version: 3, Name: "user_last_contribution_reflected_at", Apply: backfillUserLastContributionReflectedAt},
+}
+
+// normalizeSealedAt はsealedMonstersのsealedAtをタイムスタンプに揃えます
+// 封印時の処理が以前はRFC3339の文字列で書き込んでいたため、期間での絞り込みや並び替えから漏れていました
+func normalizeSealedAt(ctx context.Context, u *User) error {
+	docs, err := u.Documents(u.Ref.Collection("sealedMonsters"))
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		value, ok := doc.Data()["sealedAt"].(string)
+		if !ok {
+			continue
+		}
+		sealedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			// 推測で日時を埋めるとデータを壊すため、このユーザーは手で直してから再実行する
+			return fmt.Errorf("%s のsealedAtを日時として解釈できません %q: %w", documentPath(doc.Ref), value, err)
+		}
+		detail := fmt.Sprintf("sealedAt: %q -> %s", value, sealedAt.UTC().Format(time.RFC3339))
+		if err := u.Update(doc.Ref, []firestore.Update{{Path: "sealedAt", Value: sealedAt}}, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeCurrentMonsterDocID はcurrentMonsterのドキュメントIDをmonsterIdに揃え、monsterIdフィールドを必ず持たせます
+// 以前は "monster" や自動生成のIDで保存される場合と、monsterIdをドキュメントIDにしてmonsterIdフィールドを持たない場合がありました
+func normalizeCurrentMonsterDocID(ctx context.Context, u *User) error {
+	docs, err := u.Documents(u.Ref.Collection("currentMonster"))
+	if err != nil {
+		return err
+	}
+	switch len(docs) {
+	case 0:
+		slog.WarnContext(ctx, "currentMonsterがありません", "user_id", u.ID)
+		return nil
+	case 1:
+	default:
+		// どれが正しいか判断できないため、このユーザーは手で直してから再実行する
+		return fmt.Errorf("currentMonsterが%d件あります", len(docs))
+	}
+
+	doc := docs[0]
+	data := doc.Data()
+	monsterID, _ := data["monsterId"].(string)
+	if monsterID == "" {
+		// monsterIdをドキュメントIDとして保存していた形式
+		monsterID = doc.Ref.ID
+	}
+
+	if doc.Ref.ID == monsterID {
+		if _, ok := data["monsterId"]; ok {
+			return nil
+		}
+		return u.Update(doc.Ref, []firestore.Update{{Path: "monsterId", Value: monsterID}}, "monsterIdフィールドを追加")
+	}
+
+	data["monsterId"] = monsterID
+	newRef := u.Ref.Collection("currentMonster").Doc(monsterID)
+	if err := u.Set(newRef, data, fmt.Sprintf("%s から移動", documentPath(doc.Ref))); err != nil {
+		return err
+	}
+	return u.Delete(doc.Ref, fmt.Sprintf("%s に移動", documentPath(newRef)))
+}
+
+// backfillUserLastContributionReflectedAt はusersドキュメントのlastContributionReflectedAtをタイムスタンプで埋めます
+// 連続封印記録の判定に使いますが、最初にコントリビューションを反映するまで書き込まれていませんでした。
+// ない場合はcurrentMonsterの値（なければ登録日時）を使います
+func backfillUserLastContributionReflectedAt(ctx context.Context, u *User) error {
+	switch v := u.Data["lastContributionReflectedAt"].(type) {
+	case time.Time:
+		return nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return u.Update(u.Ref, []firestore.Update{{Path: "lastContributionReflectedAt", Value: t}},
+				fmt.Sprintf("lastContributionReflectedAt: %q -> %s", v, t.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	docs, err := u.Documents(u.Ref.Collection("currentMonster").Limit(1))
+	if err != nil {
+		return err
+	}
+	var reflectedAt time.Time
+	source := "currentMonster"
+	if len(docs) > 0 {
+		reflectedAt, _ = docs[0].Data()["lastContributionReflectedAt"].(time.Time)
+	}
+	if reflectedAt.IsZero() {
+		reflectedAt, _ = u.Data["createdAt"].(time.Time)
+		source = "createdAt"
+	}
+	if reflectedAt.IsZero() {
+		return fmt.Errorf("lastContributionReflectedAtの元になる日時がありません")
+	}
+
+	return u.Update(u.Ref, []firestore.Update{{Path: "lastContributionReflectedAt", Value: reflectedAt}},
+		fmt.Sprintf("lastContributionReflectedAt: %s（%sから）", reflectedAt.UTC().Format(time.RFC3339), source))
+}
@@ -1,14 +1,33 @@
 package models
 
+import "time"
+
 // GraphQLリクエストの構造体
 type GraphQLRequest struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
 }
 
+// GraphQLレベルのエラー
+// TypeにはGitHubが返す "NOT_FOUND" や "RATE_LIMITED" などが入る
+type GraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// GraphQL APIのレート制限情報（rateLimitフィールド）
+// Costはそのクエリで消費したポイント数
+type GraphQLRateLimit struct {
+	Cost      int    `json:"cost"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	ResetAt   string `json:"resetAt"`
+}
+
 // GitHubからのレスポンスを格納する構造体
 type GithubResponse struct {
 	Data struct {
+		RateLimit GraphQLRateLimit `json:"rateLimit"`
 		User struct {
 			ContributionsCollection struct {
 				CommitContributionsByRepository []struct {
@@ -28,10 +47,63 @@ type GithubResponse struct {
 						} `json:"nodes"`
 					} `json:"contributions"`
 				} `json:"commitContributionsByRepository"`
+				IssueContributionsByRepository             []RepositoryContributions `json:"issueContributionsByRepository"`
+				PullRequestContributionsByRepository       []RepositoryContributions `json:"pullRequestContributionsByRepository"`
+				PullRequestReviewContributionsByRepository []RepositoryContributions `json:"pullRequestReviewContributionsByRepository"`
 			} `json:"contributionsCollection"`
 		} `json:"user"`
 	} `json:"data"`
-	Errors []struct { // GraphQLレベルのエラーも考慮
-		Message string `json:"message"`
-	} `json:"errors"`
+	Errors []GraphQLError `json:"errors"` // GraphQLレベルのエラーも考慮
+	// GitHubに問い合わせを始めた日時。キャッシュから返した場合も取得した時点の日時で、この日時までのコントリビューションを含む
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Issue・PR・レビューのリポジトリごとのコントリビューション
+// コミットと異なり、1件のコントリビューションが1つのノードになる
+type RepositoryContributions struct {
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Contributions struct {
+		Nodes []struct {
+			OccurredAt string `json:"occurredAt"`
+		} `json:"nodes"`
+	} `json:"contributions"`
+}
+
+// ContributionDay はユーザーの1日分のコントリビューションの集計です
+// users/{id}/contributionDays/{date} に保存します
+type ContributionDay struct {
+	// ユーザーのタイムゾーンでの日付 (YYYY-MM-DD)
+	Date  string `firestore:"date"`
+	Total int    `firestore:"total"`
+	// 種類 (commit, issue, pull_request, pull_request_review) ごとの件数
+	ByType map[string]int `firestore:"byType"`
+	// リポジトリ (owner/name) ごとの件数
+	ByRepository map[string]int `firestore:"byRepository"`
+}
+
+// GitHubのviewer（トークンの持ち主）の情報
+type GithubViewer struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+// viewerクエリのレスポンスを格納する構造体
+type GithubViewerResponse struct {
+	Data struct {
+		Viewer GithubViewer `json:"viewer"`
+	} `json:"data"`
+	Errors []GraphQLError `json:"errors"`
+}
+
+// rateLimitクエリのレスポンスを格納する構造体
+type GithubRateLimitResponse struct {
+	Data struct {
+		RateLimit GraphQLRateLimit `json:"rateLimit"`
+	} `json:"data"`
+	Errors []GraphQLError `json:"errors"`
 }
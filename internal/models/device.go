@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DeviceToken はプッシュ通知を送る端末の登録です
+// users/{id}/deviceTokens/{deviceId} に保存し、deviceIdはトークンのハッシュです
+type DeviceToken struct {
+	DeviceId string `json:"deviceId" firestore:"-"`
+	// FCMの登録トークン
+	Token string `json:"-" firestore:"token"`
+	// ios, android, web
+	Platform   string    `json:"platform" firestore:"platform"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" firestore:"lastSeenAt"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// Monster はmonstersコレクションのモンスターのマスターデータです
+// ドキュメントIDはmonsterId（"001", "002", ...）です
+type Monster struct {
+	MonsterId             string `json:"monsterId" firestore:"-"`
+	Name                  string `json:"name" firestore:"name"`
+	Description           string `json:"description" firestore:"description"`
+	ImageURL              string `json:"imageURL" firestore:"imageURL"`
+	RequiredContributions int    `json:"requiredContributions" firestore:"requiredContributions"`
+}
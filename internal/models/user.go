@@ -2,6 +2,11 @@ package models
 
 import "time"
 
+// UserSchemaVersion はusersドキュメントとそのサブコレクションの形式のバージョンです
+// usersドキュメントのschemaVersionに保存し、古いデータは cmd/migrate で変換します。
+// 形式を変える場合はinternal/migrationsにマイグレーションを追加して、この値を上げてください
+const UserSchemaVersion = 3
+
 type User struct {
 	FirebaseId           string           `json:"firebaseId"`
 	GithubUserName       string           `json:"githubUserName"`
@@ -9,8 +14,29 @@ type User struct {
 	CreatedAt            time.Time            `json:"createdAt" firestore:"createdAt"`
 	ContinuousSealRecord int64            `json:"continuousSealRecord"`
 	MaxSealRecord        int64            `json:"maxSealRecord"`
-	CurrentMonster       *CurrentMonster  `firestore:"currentMonster,omitempty"`
+	CurrentMonster       *CurrentMonster  `json:"currentMonster,omitempty" firestore:"currentMonster,omitempty"`
 	SealedMonsters       []SealedMonster  `json:"sealedMonsters" firestore:"sealedMonsters,omitempty"`
+	// sealedMonstersサブコレクションの件数（保存はしない）
+	SealedMonsterCount int64 `json:"sealedMonsterCount" firestore:"-"`
+	DisplaySettings      *DisplaySettings `json:"displaySettings,omitempty" firestore:"displaySettings,omitempty"`
+	// IANAのタイムゾーン名（例: Asia/Tokyo）。コントリビューションの履歴の日付の区切りに使う。空の場合はDEFAULT_TIME_ZONE
+	TimeZone string `json:"timeZone,omitempty" firestore:"timeZone,omitempty"`
+	// 日ごとのコントリビューションを最後に保存した日時。ゼロ値の場合は未保存
+	ContributionHistorySyncedAt time.Time `json:"-" firestore:"contributionHistorySyncedAt,omitempty"`
+	// 日ごとのコントリビューションを保存したときのタイムゾーン。TimeZoneと異なる場合は次の保存で取得できた期間を集計し直す
+	ContributionHistoryTimeZone string `json:"-" firestore:"contributionHistoryTimeZone,omitempty"`
+	// 最後にコントリビューションを反映した日の終わり。連続記録はこの24時間後までにコントリビューションしないと途切れる
+	LastContributionReflectedAt time.Time `json:"-" firestore:"lastContributionReflectedAt,omitempty"`
+	// 通知を止めたカテゴリ（notify.Categories）。キーがないカテゴリは通知する
+	NotificationOptOuts map[string]bool `json:"-" firestore:"notificationOptOuts,omitempty"`
+	// 連続記録が途切れそうなことを通知した期限（同じ期限について何度も通知しないため）
+	StreakReminderSentFor time.Time `json:"-" firestore:"streakReminderSentFor,omitempty"`
+}
+
+// アプリ上での表示に関する設定
+type DisplaySettings struct {
+	DisplayName string `json:"displayName" firestore:"displayName" binding:"max=50"`
+	Theme       string `json:"theme" firestore:"theme" binding:"omitempty,oneof=light dark"`
 }
 
 
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Webhook はユーザーが登録したイベントの送信先です
+// users/{id}/webhooks/{webhookId} に保存します
+type Webhook struct {
+	WebhookId string `json:"webhookId" firestore:"-"`
+	URL       string `json:"url" firestore:"url"`
+	// ペイロードの形式 (discord, slack, json)
+	Format string `json:"format" firestore:"format"`
+	// 送信するイベントの種類
+	Events []string `json:"events" firestore:"events"`
+	// 署名に使うシークレット。作成時のレスポンスでのみ返す
+	Secret    string    `json:"-" firestore:"secret"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+}
+
+// 配送の状態
+const (
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDelivery はイベント1件のwebhookへの配送の記録です
+// users/{id}/webhooks/{webhookId}/deliveries/{deliveryId} に保存し、deliveryIdはイベントのIDです
+type WebhookDelivery struct {
+	DeliveryId string `json:"deliveryId" firestore:"-"`
+	EventType  string `json:"eventType" firestore:"eventType"`
+	// succeeded, failed
+	Status string `json:"status" firestore:"status"`
+	// 最後の試行で受信側が返したステータスコード（接続できなかった場合は0）
+	StatusCode int    `json:"statusCode" firestore:"statusCode"`
+	Error      string `json:"error,omitempty" firestore:"error,omitempty"`
+	// 送信を試みた回数
+	Attempts      int       `json:"attempts" firestore:"attempts"`
+	DurationMs    int64     `json:"durationMs" firestore:"durationMs"`
+	LastAttemptAt time.Time `json:"lastAttemptAt" firestore:"lastAttemptAt"`
+	// テストイベントの場合はtrue
+	Test bool `json:"test" firestore:"test"`
+	// FirestoreのTTLポリシーで削除する日時
+	ExpireAt time.Time `json:"-" firestore:"expireAt"`
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+)
+
+// FCMの1回の送信で指定できるトークンの上限
+const fcmMaxTokens = 500
+
+// FCM はFirebase Cloud Messagingで通知を送信するNotifierです
+type FCM struct {
+	client *messaging.Client
+}
+
+// NewFCM はFirebaseアプリのFCMクライアントを使うNotifierを作成します
+func NewFCM(ctx context.Context, app *firebase.App) (*FCM, error) {
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FCMクライアントの作成に失敗しました: %w", err)
+	}
+	return &FCM{client: client}, nil
+}
+
+// Send はtokensに通知を送信します
+// 登録が解除されたトークンは無効なトークンとして返し、それ以外の理由で失敗した端末があればエラーを返します
+func (f *FCM) Send(ctx context.Context, tokens []string, msg Message) ([]string, error) {
+	var invalid []string
+	var errs []error
+	for chunk := range slices.Chunk(tokens, fcmMaxTokens) {
+		res, err := f.client.SendEachForMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: chunk,
+			Notification: &messaging.Notification{
+				Title: msg.Title,
+				Body:  msg.Body,
+			},
+			Data: messageData(msg),
+			// アプリがバックグラウンドでもすぐに表示させる
+			Android: &messaging.AndroidConfig{Priority: "high"},
+			APNS: &messaging.APNSConfig{
+				Payload: &messaging.APNSPayload{Aps: &messaging.Aps{Sound: "default"}},
+			},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, r := range res.Responses {
+			if r.Success {
+				continue
+			}
+			if messaging.IsUnregistered(r.Error) || messaging.IsSenderIDMismatch(r.Error) {
+				invalid = append(invalid, chunk[i])
+				continue
+			}
+			errs = append(errs, r.Error)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return invalid, fmt.Errorf("%d件の端末への通知の送信に失敗しました: %w", len(errs), err)
+	}
+	return invalid, nil
+}
@@ -0,0 +1,121 @@
+// Package notify はユーザーの端末へのプッシュ通知の送信を抽象化します
+// 送信先の端末（トークン）の管理や、通知を受け取るかどうかの判定は呼び出し側で行います
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// 通知のカテゴリ。ユーザーはカテゴリごとに通知を止められます
+const (
+	// モンスターを封印した
+	CategorySeal = "seal"
+	// 新しいモンスターが割り当てられた
+	CategoryMonsterAssigned = "monster_assigned"
+	// 今日コントリビューションしないと連続記録が途切れる
+	CategoryStreakAtRisk = "streak_at_risk"
+)
+
+// Categories はすべての通知のカテゴリです
+var Categories = []string{CategorySeal, CategoryMonsterAssigned, CategoryStreakAtRisk}
+
+// IsCategory はcategoryが通知のカテゴリかを判定します
+func IsCategory(category string) bool {
+	return slices.Contains(Categories, category)
+}
+
+// Message は端末に送る通知です
+type Message struct {
+	Category string
+	Title    string
+	Body     string
+	// アプリに渡す追加の値（画面遷移先のモンスターIDなど）。categoryは自動で含めます
+	Data map[string]string
+}
+
+// Notifier は通知を端末に送信します
+type Notifier interface {
+	// Send はtokensの端末にmsgを送信し、無効になっていたトークン（アプリの削除など）を返します
+	// 一部の端末への送信に失敗しても、他の端末には送信します
+	Send(ctx context.Context, tokens []string, msg Message) (invalidTokens []string, err error)
+}
+
+// 送信するデータにカテゴリを含める
+func messageData(msg Message) map[string]string {
+	data := make(map[string]string, len(msg.Data)+1)
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	data["category"] = msg.Category
+	return data
+}
+
+// Nop は何も送信しないNotifierです（NOTIFIER=none）
+type Nop struct{}
+
+// Send は何もしません
+func (Nop) Send(ctx context.Context, tokens []string, msg Message) ([]string, error) {
+	return nil, nil
+}
+
+// Sent はFakeが送信した通知です
+type Sent struct {
+	Tokens  []string
+	Message Message
+}
+
+// Fake は送信した通知をメモリに記録するNotifierです
+// FCMに接続できないエミュレータ環境やテストで使います
+type Fake struct {
+	mu   sync.Mutex
+	sent []Sent
+	// 無効として扱うトークン
+	invalid map[string]bool
+}
+
+// NewFake はFakeを作成します
+func NewFake() *Fake {
+	return &Fake{invalid: make(map[string]bool)}
+}
+
+// Send は通知を記録し、SetInvalidで無効にしたトークンを返します
+func (f *Fake) Send(ctx context.Context, tokens []string, msg Message) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg.Data = messageData(msg)
+	f.sent = append(f.sent, Sent{Tokens: slices.Clone(tokens), Message: msg})
+
+	var invalid []string
+	for _, token := range tokens {
+		if f.invalid[token] {
+			invalid = append(invalid, token)
+		}
+	}
+	slog.InfoContext(ctx, "通知を送信しました（fake）", "category", msg.Category, "title", msg.Title, "devices", len(tokens))
+	return invalid, nil
+}
+
+// SetInvalid はtokenを無効なトークンとして扱うようにします
+func (f *Fake) SetInvalid(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalid[token] = true
+}
+
+// Sent は送信した通知を古い順に返します
+func (f *Fake) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.sent)
+}
+
+// Reset は記録した通知を消去します
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
@@ -0,0 +1,230 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"geekcamp-vol10-backend/internal/metrics"
+)
+
+// 配送の結果（メトリクスのラベル）
+const (
+	resultDelivered  = "delivered"
+	resultRetry      = "retry"
+	resultDeadLetter = "dead_letter"
+)
+
+// Store はイベントの保存先です
+// 複数のサーバーが同じ保存先を使っても、1つのイベントを同時に配送しないようにしてください
+type Store interface {
+	// ListDue は配送予定の日時がnow以前のイベントのIDを古い順に最大limit件返します（取り出しはしません）
+	ListDue(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// Claim はイベントidを取り出し、nowからleaseの間は他から取り出されないようにします
+	// 他のサーバーが既に取り出した場合や配送を終えた場合はnilを返します
+	// 配送中にサーバーが停止した場合、leaseが過ぎると再び取り出されます
+	Claim(ctx context.Context, id string, now time.Time, lease time.Duration) (*Event, error)
+	// Complete はすべてのコンシューマーに配送したイベントを削除します
+	Complete(ctx context.Context, id string) error
+	// Retry は配送に失敗したイベントを、配送済みのコンシューマーと次に配送を試みる日時を記録して戻します
+	Retry(ctx context.Context, ev Event, next time.Time, lastError string) error
+	// DeadLetter は再試行の上限に達したイベントを配送の対象から外し、調査用に残します
+	DeadLetter(ctx context.Context, ev Event, lastError string) error
+}
+
+// Consumer はイベントを処理します
+// 同じイベントが2回以上届くことがある（少なくとも1回の配送）ため、重複して処理しても問題ないようにしてください
+type Consumer func(ctx context.Context, ev Event) error
+
+type consumer struct {
+	name  string
+	types []string
+	fn    Consumer
+}
+
+// DispatcherConfig は配送の設定です
+type DispatcherConfig struct {
+	// 配送を待つイベントを確認する間隔
+	PollInterval time.Duration
+	// 1回の確認で取り出すイベントの数
+	BatchSize int
+	// 配送を試みる回数の上限。超えたイベントはDead letterに移します
+	MaxAttempts int
+	// 再試行の間隔の初期値と上限（失敗するたびに2倍にします）
+	RetryBase time.Duration
+	RetryMax  time.Duration
+	// 1つのイベントの配送にかけられる時間
+	Lease time.Duration
+}
+
+// Dispatcher は保存されたイベントを登録されたコンシューマーに配送します
+type Dispatcher struct {
+	store     Store
+	cfg       DispatcherConfig
+	consumers []consumer
+	// Kickで次の確認を待たずに配送させるためのチャネル
+	wake chan struct{}
+	mu   sync.Mutex
+}
+
+// NewDispatcher はDispatcherを作成します
+func NewDispatcher(store Store, cfg DispatcherConfig) *Dispatcher {
+	return &Dispatcher{
+		store: store,
+		cfg:   cfg,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Register はtypesのイベントを処理するコンシューマーを登録します（typesを省略した場合はすべての種類）
+// nameは配送済みの記録に使うため、コンシューマーごとに一意で、変更しない名前にしてください
+// Runを呼び出す前に登録してください
+func (d *Dispatcher) Register(name string, fn Consumer, types ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.consumers = append(d.consumers, consumer{name: name, types: types, fn: fn})
+}
+
+// Kick は次の確認を待たずに配送を始めさせます
+// イベントを保存した直後に呼び出すと、すぐに配送されます
+func (d *Dispatcher) Kick() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run はctxがキャンセルされるまで、PollIntervalごとかKickされるたびに配送を待つイベントを配送します
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		// 1回で取り出しきれなかった場合は続けて配送する
+		// 1件も配送できなかった場合（他のサーバーがすべて取り出した場合など）は、次の確認まで待つ
+		for {
+			listed, dispatched, err := d.dispatchDue(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "イベントの取り出しに失敗しました", "error", err)
+				break
+			}
+			if listed < d.cfg.BatchSize || dispatched == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchDue は配送を待つイベントを最大BatchSize件配送し、取り出して配送した件数を返します
+// leaseは配送する直前に1件ずつ取るため、前のイベントの配送に時間がかかっても後のイベントのleaseは切れません
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	_, dispatched, err := d.dispatchDue(ctx)
+	return dispatched, err
+}
+
+// 配送を待っていた件数と、そのうち取り出して配送した件数を返す
+func (d *Dispatcher) dispatchDue(ctx context.Context) (listed, dispatched int, err error) {
+	ids, err := d.store.ListDue(ctx, time.Now(), d.cfg.BatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		ev, err := d.store.Claim(ctx, id, time.Now(), d.cfg.Lease)
+		if err != nil {
+			slog.WarnContext(ctx, "イベントの取り出しに失敗しました", "event_id", id, "error", err)
+			continue
+		}
+		if ev == nil {
+			continue
+		}
+		d.dispatch(ctx, *ev)
+		dispatched++
+	}
+	return len(ids), dispatched, nil
+}
+
+// まだ配送していないコンシューマーに配送し、結果に応じてイベントを削除・再試行・Dead letterにする
+func (d *Dispatcher) dispatch(ctx context.Context, ev Event) {
+	d.mu.Lock()
+	consumers := slices.Clone(d.consumers)
+	d.mu.Unlock()
+
+	deliverCtx, cancel := context.WithTimeout(ctx, d.cfg.Lease)
+	defer cancel()
+
+	var failed []string
+	var lastErr error
+	for _, c := range consumers {
+		if (len(c.types) > 0 && !slices.Contains(c.types, ev.Type)) || slices.Contains(ev.Delivered, c.name) {
+			continue
+		}
+		if err := d.deliver(deliverCtx, c, ev); err != nil {
+			slog.WarnContext(ctx, "イベントの配送に失敗しました",
+				"event_id", ev.ID, "type", ev.Type, "consumer", c.name, "attempt", ev.Attempts+1, "error", err)
+			failed = append(failed, c.name)
+			lastErr = fmt.Errorf("%s: %w", c.name, err)
+			continue
+		}
+		ev.Delivered = append(ev.Delivered, c.name)
+	}
+
+	if lastErr == nil {
+		if err := d.store.Complete(ctx, ev.ID); err != nil {
+			// 削除できなかったイベントはleaseが過ぎた後に再び取り出されるが、配送済みのコンシューマーは記録していないため再び届く
+			slog.WarnContext(ctx, "配送したイベントの削除に失敗しました", "event_id", ev.ID, "error", err)
+			return
+		}
+		metrics.IncOutboxEvent(ev.Type, resultDelivered)
+		return
+	}
+
+	ev.Attempts++
+	if ev.Attempts >= d.cfg.MaxAttempts {
+		if err := d.store.DeadLetter(ctx, ev, lastErr.Error()); err != nil {
+			slog.ErrorContext(ctx, "イベントをDead letterに移せませんでした", "event_id", ev.ID, "error", err)
+			return
+		}
+		metrics.IncOutboxEvent(ev.Type, resultDeadLetter)
+		slog.ErrorContext(ctx, "再試行の上限に達したためイベントをDead letterに移しました",
+			"event_id", ev.ID, "type", ev.Type, "user_id", ev.UserID, "failed_consumers", failed, "attempts", ev.Attempts, "error", lastErr)
+		return
+	}
+
+	next := time.Now().Add(d.backoff(ev.Attempts))
+	if err := d.store.Retry(ctx, ev, next, lastErr.Error()); err != nil {
+		slog.WarnContext(ctx, "イベントの再試行の記録に失敗しました", "event_id", ev.ID, "error", err)
+		return
+	}
+	metrics.IncOutboxEvent(ev.Type, resultRetry)
+}
+
+// コンシューマーのパニックで配送全体が止まらないよう、エラーとして扱う
+func (d *Dispatcher) deliver(ctx context.Context, c consumer, ev Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("コンシューマーがパニックしました: %v", r)
+		}
+	}()
+	return c.fn(ctx, ev)
+}
+
+// attempts回目の失敗の後に待つ時間
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.RetryBase
+	for i := 1; i < attempts && wait < d.cfg.RetryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.RetryMax)
+}
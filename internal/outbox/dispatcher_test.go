@@ -0,0 +1,351 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type storedEvent struct {
+	ev  Event
+	due time.Time
+}
+
+// メモリ上のStore
+type memoryStore struct {
+	mu     sync.Mutex
+	events map[string]*storedEvent
+	dead   map[string]Event
+	// 最後に記録した再試行の日時とエラー
+	nextRetry map[string]time.Time
+	lastError map[string]string
+	// trueの場合、Claimは他のサーバーが取り出したものとしてnilを返す
+	lostLease bool
+	listCalls int
+}
+
+func newMemoryStore(events ...Event) *memoryStore {
+	s := &memoryStore{
+		events:    make(map[string]*storedEvent),
+		dead:      make(map[string]Event),
+		nextRetry: make(map[string]time.Time),
+		lastError: make(map[string]string),
+	}
+	for _, ev := range events {
+		s.events[ev.ID] = &storedEvent{ev: ev, due: ev.CreatedAt}
+	}
+	return s
+}
+
+func (s *memoryStore) ListDue(_ context.Context, now time.Time, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listCalls++
+	var ids []string
+	for id, e := range s.events {
+		if !e.due.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (s *memoryStore) Claim(_ context.Context, id string, now time.Time, lease time.Duration) (*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok || e.due.After(now) || s.lostLease {
+		return nil, nil
+	}
+	e.due = now.Add(lease)
+	ev := e.ev
+	ev.Delivered = slices.Clone(ev.Delivered)
+	return &ev, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, id)
+	return nil
+}
+
+func (s *memoryStore) Retry(_ context.Context, ev Event, next time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[ev.ID] = &storedEvent{ev: ev, due: next}
+	s.nextRetry[ev.ID] = next
+	s.lastError[ev.ID] = lastError
+	return nil
+}
+
+func (s *memoryStore) DeadLetter(_ context.Context, ev Event, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, ev.ID)
+	s.dead[ev.ID] = ev
+	s.lastError[ev.ID] = lastError
+	return nil
+}
+
+// makeDue は再試行を待つイベントをすぐに配送できるようにする
+func (s *memoryStore) makeDue(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[id].due = time.Time{}
+}
+
+func (s *memoryStore) event(id string) (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return Event{}, false
+	}
+	return e.ev, true
+}
+
+var testConfig = DispatcherConfig{
+	PollInterval: time.Hour,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	RetryBase:    time.Minute,
+	RetryMax:     10 * time.Minute,
+	Lease:        time.Minute,
+}
+
+func testEvent(id, eventType string) Event {
+	return Event{ID: id, Type: eventType, UserID: "user-1", Payload: []byte(`{}`), CreatedAt: time.Now().Add(-time.Second)}
+}
+
+// recorder は受け取ったイベントのIDを記録し、errを返すコンシューマーです
+type recorder struct {
+	mu   sync.Mutex
+	got  []string
+	err  error
+	fail bool
+}
+
+func (r *recorder) consume(_ context.Context, ev Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, ev.ID)
+	if r.fail {
+		return r.err
+	}
+	return nil
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.got)
+}
+
+func (r *recorder) setFail(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func TestDispatchDueDeliversAndCompletes(t *testing.T) {
+	store := newMemoryStore(testEvent("ev-1", TypeMonsterSealed), testEvent("ev-2", TypeStreakExtended))
+	d := NewDispatcher(store, testConfig)
+	all, sealed := &recorder{}, &recorder{}
+	d.Register("all", all.consume)
+	d.Register("sealed", sealed.consume, TypeMonsterSealed)
+
+	n, err := d.DispatchDue(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("DispatchDue() = %d, %v, want 2", n, err)
+	}
+	if got := all.received(); !slices.Equal(got, []string{"ev-1", "ev-2"}) {
+		t.Errorf("all が受け取ったイベント = %v", got)
+	}
+	// 種類を指定したコンシューマーには、その種類のイベントだけを配送する
+	if got := sealed.received(); !slices.Equal(got, []string{"ev-1"}) {
+		t.Errorf("sealed が受け取ったイベント = %v", got)
+	}
+	if len(store.events) != 0 {
+		t.Errorf("配送したイベントが残っています: %v", store.events)
+	}
+}
+
+func TestDispatchDueRetriesFailedConsumerOnly(t *testing.T) {
+	store := newMemoryStore(testEvent("ev-1", TypeMonsterSealed))
+	d := NewDispatcher(store, testConfig)
+	ok := &recorder{}
+	flaky := &recorder{err: errors.New("接続できません"), fail: true}
+	d.Register("ok", ok.consume)
+	d.Register("flaky", flaky.consume)
+
+	before := time.Now()
+	if _, err := d.DispatchDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ev, found := store.event("ev-1")
+	if !found {
+		t.Fatal("失敗したイベントが削除されました")
+	}
+	if ev.Attempts != 1 || !slices.Equal(ev.Delivered, []string{"ok"}) {
+		t.Errorf("Attempts = %d, Delivered = %v", ev.Attempts, ev.Delivered)
+	}
+	// 1回目の失敗の後はRetryBaseだけ待つ
+	if next := store.nextRetry["ev-1"]; next.Before(before.Add(testConfig.RetryBase)) || next.After(time.Now().Add(testConfig.RetryBase)) {
+		t.Errorf("次の配送 = %v", next)
+	}
+	if store.lastError["ev-1"] != "flaky: 接続できません" {
+		t.Errorf("lastError = %q", store.lastError["ev-1"])
+	}
+
+	// 再試行を待つ間は配送しない
+	if n, _ := d.DispatchDue(context.Background()); n != 0 {
+		t.Errorf("再試行の前の DispatchDue() = %d", n)
+	}
+
+	// 再試行では失敗したコンシューマーにだけ配送する
+	flaky.setFail(false)
+	store.makeDue("ev-1")
+	if n, err := d.DispatchDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("再試行の DispatchDue() = %d, %v", n, err)
+	}
+	if got := ok.received(); !slices.Equal(got, []string{"ev-1"}) {
+		t.Errorf("ok が受け取ったイベント = %v", got)
+	}
+	if got := flaky.received(); !slices.Equal(got, []string{"ev-1", "ev-1"}) {
+		t.Errorf("flaky が受け取ったイベント = %v", got)
+	}
+	if _, found := store.event("ev-1"); found {
+		t.Error("再試行で配送したイベントが残っています")
+	}
+}
+
+func TestDispatchDueDeadLettersAfterMaxAttempts(t *testing.T) {
+	store := newMemoryStore(testEvent("ev-1", TypeMonsterSealed))
+	d := NewDispatcher(store, testConfig)
+	d.Register("panics", func(context.Context, Event) error { panic("壊れています") })
+
+	for attempt := 1; attempt <= testConfig.MaxAttempts; attempt++ {
+		if n, err := d.DispatchDue(context.Background()); err != nil || n != 1 {
+			t.Fatalf("%d回目の DispatchDue() = %d, %v", attempt, n, err)
+		}
+		if attempt < testConfig.MaxAttempts {
+			if ev, _ := store.event("ev-1"); ev.Attempts != attempt {
+				t.Errorf("%d回目の後の Attempts = %d", attempt, ev.Attempts)
+			}
+			store.makeDue("ev-1")
+		}
+	}
+
+	if _, found := store.event("ev-1"); found {
+		t.Error("上限に達したイベントが配送の対象に残っています")
+	}
+	ev, found := store.dead["ev-1"]
+	if !found || ev.Attempts != testConfig.MaxAttempts {
+		t.Fatalf("Dead letter = %+v, %v", ev, found)
+	}
+	// パニックもエラーとして記録する
+	if store.lastError["ev-1"] != "panics: コンシューマーがパニックしました: 壊れています" {
+		t.Errorf("lastError = %q", store.lastError["ev-1"])
+	}
+}
+
+func TestDispatchDueSkipsLostLease(t *testing.T) {
+	store := newMemoryStore(testEvent("ev-1", TypeMonsterSealed))
+	store.lostLease = true
+	d := NewDispatcher(store, testConfig)
+	r := &recorder{}
+	d.Register("r", r.consume)
+
+	n, err := d.DispatchDue(context.Background())
+	if err != nil || n != 0 {
+		t.Errorf("DispatchDue() = %d, %v, want 0", n, err)
+	}
+	if got := r.received(); len(got) != 0 {
+		t.Errorf("取り出せなかったイベントを配送しました: %v", got)
+	}
+	// 他のサーバーが配送するため、削除も再試行の記録もしない
+	if ev, found := store.event("ev-1"); !found || ev.Attempts != 0 {
+		t.Errorf("イベント = %+v, %v", ev, found)
+	}
+}
+
+func TestRunWaitsWhenNothingClaimed(t *testing.T) {
+	// 配送を待つイベントが1回分以上あっても、すべて他のサーバーが取り出している
+	var events []Event
+	for _, id := range []string{"ev-01", "ev-02", "ev-03", "ev-04", "ev-05", "ev-06", "ev-07", "ev-08", "ev-09", "ev-10", "ev-11"} {
+		events = append(events, testEvent(id, TypeMonsterSealed))
+	}
+	store := newMemoryStore(events...)
+	store.lostLease = true
+	d := NewDispatcher(store, testConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	// 取り出せなかった場合は続けて確認せず、次の確認（1時間後）まで待つ
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.listCalls != 1 {
+		t.Errorf("ListDue の呼び出し = %d, want 1", store.listCalls)
+	}
+}
+
+func TestRunDrainsFullBatches(t *testing.T) {
+	cfg := testConfig
+	cfg.BatchSize = 2
+	store := newMemoryStore(testEvent("ev-1", TypeMonsterSealed), testEvent("ev-2", TypeMonsterSealed), testEvent("ev-3", TypeMonsterSealed))
+	d := NewDispatcher(store, cfg)
+	r := &recorder{}
+	d.Register("r", r.consume)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(r.received()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// 1回目で2件、続けて残りの1件を配送する（次の確認を待たない）
+	if got := r.received(); !slices.Equal(got, []string{"ev-1", "ev-2", "ev-3"}) {
+		t.Errorf("受け取ったイベント = %v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, testConfig)
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		4:  8 * time.Minute,
+		5:  10 * time.Minute,
+		50: 10 * time.Minute,
+	}
+	for attempts, want := range tests {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
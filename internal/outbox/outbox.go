@@ -0,0 +1,88 @@
+// Package outbox は状態の変更と同じトランザクションで保存したドメインイベントを、登録されたコンシューマーに届けます
+// イベントの保存はリポジトリがトランザクションの中で行い、このパッケージは保存済みのイベントの配送だけを扱います
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// イベントの種類
+const (
+	// モンスターを封印した
+	TypeMonsterSealed = "MonsterSealed"
+	// 新しいモンスターが割り当てられた
+	TypeMonsterAssigned = "MonsterAssigned"
+	// 連続記録が伸びた
+	TypeStreakExtended = "StreakExtended"
+	// コントリビューションをモンスターの進捗に反映した
+	TypeContributionsCredited = "ContributionsCredited"
+)
+
+// Types はすべてのイベントの種類です
+var Types = []string{TypeMonsterSealed, TypeMonsterAssigned, TypeStreakExtended, TypeContributionsCredited}
+
+// MonsterSealed はTypeMonsterSealedのペイロードです
+type MonsterSealed struct {
+	MonsterId   string    `json:"monsterId"`
+	MonsterName string    `json:"monsterName"`
+	SealedAt    time.Time `json:"sealedAt"`
+}
+
+// MonsterAssigned はTypeMonsterAssignedのペイロードです
+type MonsterAssigned struct {
+	MonsterId string `json:"monsterId"`
+	// 前のモンスターから引き継いだ進捗
+	ProgressContributions int       `json:"progressContributions"`
+	RequiredContributions int       `json:"requiredContributions"`
+	AssignedAt            time.Time `json:"assignedAt"`
+}
+
+// StreakExtended はTypeStreakExtendedのペイロードです
+type StreakExtended struct {
+	ContinuousSealRecord int `json:"continuousSealRecord"`
+	MaxSealRecord        int `json:"maxSealRecord"`
+	// この日時の24時間後までにコントリビューションしないと連続記録が途切れる
+	LastContributionReflectedAt time.Time `json:"lastContributionReflectedAt"`
+}
+
+// ContributionsCredited はTypeContributionsCreditedのペイロードです
+type ContributionsCredited struct {
+	// 反映したモンスター（封印した場合は封印したモンスター）
+	MonsterId     string `json:"monsterId"`
+	Contributions int    `json:"contributions"`
+}
+
+// Event はユーザーに起きたドメインイベントです
+type Event struct {
+	// 保存時に採番するID。コンシューマーが重複して届いたイベントを見分けるのに使えます
+	ID     string
+	Type   string
+	UserID string
+	// 種類ごとのペイロード（JSON）
+	Payload   json.RawMessage
+	CreatedAt time.Time
+
+	// 配送を試みた回数
+	Attempts int
+	// 配送済みのコンシューマー。再試行では残りのコンシューマーにだけ配送します
+	Delivered []string
+}
+
+// NewEvent はpayloadをJSONにしたイベントを作成します
+func NewEvent(eventType, userID string, payload any, createdAt time.Time) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("イベント %s のペイロードを変換できません: %w", eventType, err)
+	}
+	return Event{Type: eventType, UserID: userID, Payload: data, CreatedAt: createdAt}, nil
+}
+
+// Decode はペイロードをvに読み込みます
+func (e Event) Decode(v any) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("イベント %s（%s）のペイロードを読み込めません: %w", e.Type, e.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 満タンに戻ったバケットを削除する間隔
+const memorySweepInterval = time.Minute
+
+// MemoryStore はプロセス内のmapにバケットを保持するStoreです
+// インスタンスごとに独立して数えるため、複数インスタンスで動かす場合は実質的な上限がインスタンス数倍になります
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	// この時刻を過ぎるとバケットは満タンに戻っているため、削除しても結果は変わらない
+	fullAt time.Time
+}
+
+// NewMemoryStore はMemoryStoreを作成します
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take はkeyのバケットからトークンを1つ取り出します
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+	var result Result
+	b.Bucket, result = limit.Take(b.Bucket, now)
+	b.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// 満タンに戻ったバケットを削除し、アクセスのなくなったキーでメモリが増え続けないようにする
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
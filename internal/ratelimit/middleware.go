@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware はpolicyごとのトークンバケットでリクエスト数を制限するミドルウェアを返します
+// キーは認証ミドルウェアが検証したFirebaseのUIDで、認証されていないリクエストはクライアントのIPアドレスで数えます。
+// 制限を超えた場合は429 (rate_limited) とRetry-Afterを返します。
+// ストアのエラーで正常なリクエストまで止めないよう、ストアに接続できない場合は制限せずに通します
+func Middleware(store Store, policy string, limit Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	// RateLimit-Policy: 10;w=60 (10回/60秒)
+	policyHeader := fmt.Sprintf("%d;w=%d", limit.Requests, int64(math.Ceil(limit.Period.Seconds())))
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		key := policy + ":ip:" + c.ClientIP()
+		if uid := c.GetString(middleware.ContextKeyFirebaseUID); uid != "" {
+			key = policy + ":uid:" + uid
+		}
+
+		result, err := store.Take(ctx, key, limit)
+		if err != nil {
+			slog.WarnContext(ctx, "レート制限の確認に失敗したため制限せずに処理します", "policy", policy, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			metrics.IncRateLimitRejected(policy)
+			slog.InfoContext(ctx, "レート制限によりリクエストを拒否しました", "policy", policy, "retry_after", retryAfter)
+			apperrors.Abort(c, apperrors.WithExtensions(
+				fmt.Errorf("%d秒後に再試行してください: %w", retryAfter, apperrors.ErrRateLimited),
+				map[string]interface{}{"retryAfter": retryAfter},
+			))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ヘッダーには秒単位の整数を入れる。0秒にすると即座に再試行されるため切り上げる
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 受け取ったキーを記録するStore
+type recordingStore struct {
+	*MemoryStore
+	keys []string
+}
+
+func (s *recordingStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.keys = append(s.keys, key)
+	return s.MemoryStore.Take(ctx, key, limit)
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("接続できません")
+}
+
+func newTestRouter(store Store, limit Limit, uid string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apperrors.Middleware())
+	r.Use(func(c *gin.Context) {
+		if uid != "" {
+			c.Set(middleware.ContextKeyFirebaseUID, uid)
+		}
+	})
+	r.GET("/", Middleware(store, "test", limit), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+func get(r *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newTestRouter(NewMemoryStore(), Limit{Requests: 2, Period: time.Minute}, "")
+
+	w := get(r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("1回目のstatus = %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Policy":    "2;w=60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "30",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	get(r)
+	w = get(r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("3回目のstatus = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != apperrors.ProblemContentType {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestMiddlewareKey(t *testing.T) {
+	limit := Limit{Requests: 10, Period: time.Minute}
+
+	store := &recordingStore{MemoryStore: NewMemoryStore()}
+	get(newTestRouter(store, limit, "uid-1"))
+	get(newTestRouter(store, limit, ""))
+
+	want := []string{"test:uid:uid-1", "test:ip:192.0.2.1"}
+	if len(store.keys) != len(want) || store.keys[0] != want[0] || store.keys[1] != want[1] {
+		t.Errorf("keys = %v, want %v", store.keys, want)
+	}
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	store := &recordingStore{MemoryStore: NewMemoryStore()}
+	r := newTestRouter(store, Limit{}, "")
+	for i := 0; i < 3; i++ {
+		if w := get(r); w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("制限しない設定で制限されました: status = %d", w.Code)
+		}
+	}
+	if len(store.keys) != 0 {
+		t.Errorf("制限しない設定でストアを使いました: %v", store.keys)
+	}
+}
+
+func TestMiddlewareStoreError(t *testing.T) {
+	// ストアに接続できない場合は制限せずに通す
+	r := newTestRouter(failingStore{}, Limit{Requests: 1, Period: time.Minute}, "")
+	for i := 0; i < 3; i++ {
+		if w := get(r); w.Code != http.StatusNoContent {
+			t.Fatalf("%d回目のstatus = %d", i+1, w.Code)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit はトークンバケットの設定です
+// バケットの容量はRequestsで、Period経過ごとにRequests個のトークンが補充されます（連続して補充）。
+// Requestsが0の場合は制限しません
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit は "10/1m" のような "<回数>/<期間>" 形式の文字列をLimitに変換します
+// "off" または "0" の場合は制限しないLimitを返します
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("\"<回数>/<期間>\" の形式で指定してください: %q", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("回数は0以上の整数で指定してください: %q", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("期間は \"1m\" のような正の時間で指定してください: %q", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Enabled は制限が有効かどうかを返します
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// 1秒あたりに補充されるトークン数
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Bucket はキーごとのトークンバケットの状態です
+// UpdatedAtがゼロ値の場合は新しいバケット（満タン）として扱います
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result はトークンを1つ取り出した結果です
+type Result struct {
+	Allowed bool
+	// 取り出した後に残っているトークン数（RateLimit-Remaining）
+	Remaining int
+	// 次のトークンが補充されるまでの時間（拒否された場合のRetry-After）
+	RetryAfter time.Duration
+	// バケットが満タンに戻るまでの時間（RateLimit-Reset）
+	ResetAfter time.Duration
+}
+
+// Take はnow時点でバケットからトークンを1つ取り出し、更新後のバケットと結果を返します
+// ストアの実装はこの関数でバケットを更新し、結果を保存します
+func (l Limit) Take(b Bucket, now time.Time) (Bucket, Result) {
+	capacity := float64(l.Requests)
+	tokens := capacity
+	if !b.UpdatedAt.IsZero() {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			// インスタンス間の時計のずれで過去になった場合は補充しない
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, b.Tokens+elapsed*l.rate())
+	}
+
+	var result Result
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.durationFor(1 - tokens)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = l.durationFor(capacity - tokens)
+	return Bucket{Tokens: tokens, UpdatedAt: now}, result
+}
+
+// tokensのトークンが補充されるまでの時間
+func (l Limit) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate() * float64(time.Second))
+}
+
+// Store はキーごとのトークンバケットを保持します
+// 1インスタンスならMemoryStore、複数インスタンスで制限を共有する場合はFirestoreに保存する実装を使います
+type Store interface {
+	// Take はkeyのバケットからトークンを1つ取り出します
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
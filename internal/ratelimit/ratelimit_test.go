@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/1m", want: Limit{Requests: 10, Period: time.Minute}},
+		{in: " 3 / 10s ", want: Limit{Requests: 3, Period: 10 * time.Second}},
+		{in: "1/24h", want: Limit{Requests: 1, Period: 24 * time.Hour}},
+		{in: "off", want: Limit{}},
+		{in: "0", want: Limit{}},
+		{in: "0/1m", want: Limit{Period: time.Minute}},
+		{in: "10", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+		{in: "10/1", wantErr: true},
+		{in: "10/0s", wantErr: true},
+		{in: "10/-1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %+v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLimit(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLimitEnabled(t *testing.T) {
+	if (Limit{}).Enabled() {
+		t.Error("Limit{}.Enabled() = true")
+	}
+	if (Limit{Period: time.Minute}).Enabled() {
+		t.Error("0回のLimitが有効になっています")
+	}
+	if !(Limit{Requests: 1, Period: time.Minute}).Enabled() {
+		t.Error("1/1mのLimitが無効になっています")
+	}
+	if got := (Limit{}).String(); got != "off" {
+		t.Errorf("Limit{}.String() = %q", got)
+	}
+}
+
+func TestLimitTake(t *testing.T) {
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	now := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	// 新しいバケットは満タンから始まる
+	var b Bucket
+	var r Result
+	for i, wantRemaining := range []int{2, 1, 0} {
+		b, r = limit.Take(b, now)
+		if !r.Allowed || r.Remaining != wantRemaining {
+			t.Fatalf("%d回目: Take() = %+v, want allowed with remaining %d", i+1, r, wantRemaining)
+		}
+	}
+	if r.ResetAfter != 3*time.Second {
+		t.Errorf("空のバケットのResetAfter = %v, want 3s", r.ResetAfter)
+	}
+
+	// 空になったら拒否し、次のトークンまでの時間を返す
+	b, r = limit.Take(b, now.Add(500*time.Millisecond))
+	if r.Allowed {
+		t.Fatalf("空のバケットから取り出せました: %+v", r)
+	}
+	if r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", r.RetryAfter)
+	}
+
+	// 1秒に1トークン補充される
+	b, r = limit.Take(b, now.Add(time.Second))
+	if !r.Allowed || r.Remaining != 0 {
+		t.Errorf("1秒後のTake() = %+v, want allowed with remaining 0", r)
+	}
+
+	// 長時間空けても容量を超えては補充されない
+	_, r = limit.Take(b, now.Add(time.Hour))
+	if !r.Allowed || r.Remaining != 2 {
+		t.Errorf("1時間後のTake() = %+v, want allowed with remaining 2", r)
+	}
+}
+
+func TestLimitTakeClockSkew(t *testing.T) {
+	limit := Limit{Requests: 1, Period: time.Minute}
+	now := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	b, _ := limit.Take(Bucket{}, now)
+	// 他のインスタンスの時計が遅れていても補充しない
+	if _, r := limit.Take(b, now.Add(-time.Hour)); r.Allowed {
+		t.Errorf("過去の時刻でトークンが補充されました: %+v", r)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Period: time.Hour}
+
+	if r, _ := store.Take(ctx, "a", limit); !r.Allowed {
+		t.Fatal("キーaの1回目が拒否されました")
+	}
+	if r, _ := store.Take(ctx, "a", limit); r.Allowed {
+		t.Fatal("キーaの2回目が許可されました")
+	}
+	// キーごとに独立して数える
+	if r, _ := store.Take(ctx, "b", limit); !r.Allowed {
+		t.Fatal("キーbの1回目が拒否されました")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.buckets["full"] = &memoryBucket{fullAt: now.Add(-time.Second)}
+	store.buckets["refilling"] = &memoryBucket{fullAt: now.Add(time.Hour)}
+
+	// 前回の削除から間隔が空いていない場合は何もしない
+	store.sweep(now)
+	if len(store.buckets) != 2 {
+		t.Fatalf("間隔内に削除されました: %d件", len(store.buckets))
+	}
+
+	store.sweep(now.Add(memorySweepInterval + time.Second))
+	if _, ok := store.buckets["full"]; ok {
+		t.Error("満タンに戻ったバケットが残っています")
+	}
+	if _, ok := store.buckets["refilling"]; !ok {
+		t.Error("補充中のバケットが削除されました")
+	}
+}
@@ -0,0 +1,350 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/outbox"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ErrProgressExceedsRequired はReassignCurrentMonsterで進捗にモンスターの必要数以上を指定した場合のエラーです
+var ErrProgressExceedsRequired = fmt.Errorf("進捗はモンスターの必要数未満で指定してください: %w", apperrors.ErrValidation)
+
+// UsersQuery は管理用のユーザーの一覧の取得条件です
+type UsersQuery struct {
+	// 1ページの件数
+	Limit int
+	// githubUserNameの前方一致（大文字小文字を区別します）。空の場合はすべてのユーザー
+	GithubUserNamePrefix string
+	// 前のページのNextCursor
+	Cursor string
+}
+
+// UsersPage はユーザーの一覧の1ページ分です
+type UsersPage struct {
+	Users []models.User
+	// 次のページがない場合は空
+	NextCursor string
+}
+
+// ListUsers はユーザーを1ページ分取得します
+// 前方一致で絞り込む場合はgithubUserName順、それ以外はFirebase UID順に並べます
+func (r *UserRepository) ListUsers(ctx context.Context, q UsersQuery) (*UsersPage, error) {
+	collection := r.Client.Collection("users")
+
+	query := collection.Query
+	if q.GithubUserNamePrefix != "" {
+		// "\uf8ff" はUnicodeの私用領域の大きい文字で、前方一致の上限として使う
+		query = query.Where("githubUserName", ">=", q.GithubUserNamePrefix).
+			Where("githubUserName", "<", q.GithubUserNamePrefix+"\uf8ff").
+			OrderBy("githubUserName", firestore.Asc)
+	}
+	query = query.OrderBy(firestore.DocumentID, firestore.Asc)
+
+	if q.Cursor != "" {
+		after, err := documentCursor(ctx, collection, q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.StartAfter(after)
+	}
+
+	// 次のページがあるかを判定するため1件多く取得する
+	op := startFirestoreOperation(ctx, "query", "users")
+	docs, err := query.Limit(q.Limit + 1).Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	page := &UsersPage{Users: make([]models.User, 0, min(len(docs), q.Limit))}
+	if len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(docs[len(docs)-1].Ref.ID))
+	}
+	for _, doc := range docs {
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			// 形式の壊れたユーザーも調査のために一覧には出す
+			slog.WarnContext(ctx, "ユーザーの変換に失敗しました", "user_id", doc.Ref.ID, "error", err)
+		}
+		user.FirebaseId = doc.Ref.ID
+		page.Users = append(page.Users, user)
+	}
+	return page, nil
+}
+
+// AdjustContributions は管理者の操作で育成中のモンスターの進捗をdelta増減させ、反映後のcurrentMonsterを返します
+// 進捗は0未満にはなりません。必要数に達した場合は同期と同じようにモンスターを封印して次のモンスターを割り当て、封印したモンスターも返します
+// GitHubのコントリビューションを反映した日時（lastContributionReflectedAt）と連続封印記録は変更しません
+func (r *ContributionRepository) AdjustContributions(ctx context.Context, id string, delta int) (models.CurrentMonster, *models.SealedMonster, error) {
+	db := r.Client
+	userRef := db.Collection("users").Doc(id)
+
+	var current models.CurrentMonster
+	var sealed *models.SealedMonster
+	op := startFirestoreOperation(ctx, "transaction", "currentMonster")
+	err := db.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		sealed = nil
+		doc, currentMonster, err := getCurrentMonsterForUpdate(tx, userRef)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		progress := max(currentMonster.ProgressContributions+delta, 0)
+
+		var events []outbox.Event
+		if progress >= currentMonster.RequiredContributions {
+			s, next, sealEvents, err := sealCurrentMonster(ctx, db, tx, userRef, doc.Ref.ID, currentMonster, progress, currentMonster.LastContributionReflectedAt, now)
+			if err != nil {
+				return err
+			}
+			current, sealed, events = next, &s, sealEvents
+		} else {
+			current = currentMonster
+			current.ProgressContributions = progress
+			if err := setCurrentMonster(ctx, tx, userRef, doc.Ref.ID, current); err != nil {
+				return fmt.Errorf("currentMonster更新に失敗しました: %w", err)
+			}
+		}
+		if delta > 0 {
+			credited, err := newEvent(outbox.TypeContributionsCredited, id, outbox.ContributionsCredited{
+				MonsterId:     currentMonster.MonsterId,
+				Contributions: delta,
+			}, now)
+			if err != nil {
+				return err
+			}
+			events = append(events, credited)
+		}
+		return addOutboxEvents(db, tx, events)
+	})
+	op.end(err)
+	if err != nil {
+		return models.CurrentMonster{}, nil, firestoreError(err, nil)
+	}
+	return current, sealed, nil
+}
+
+// ReassignCurrentMonster は管理者の操作で育成中のモンスターをmonsterIdに置き換え、置き換えた後のcurrentMonsterを返します
+// 必要数はモンスターのマスターデータから取得し、進捗はprogress（必要数未満）にします。monsterIdがマスターデータにない場合はapperrors.ErrMonsterNotFoundを返します
+// GitHubのコントリビューションを反映した日時は引き継ぐため、まだ反映していないコントリビューションは次の同期で新しいモンスターに反映されます
+func (r *ContributionRepository) ReassignCurrentMonster(ctx context.Context, id, monsterId string, progress int) (models.CurrentMonster, error) {
+	db := r.Client
+	userRef := db.Collection("users").Doc(id)
+
+	var current models.CurrentMonster
+	op := startFirestoreOperation(ctx, "transaction", "currentMonster")
+	err := db.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, currentMonster, err := getCurrentMonsterForUpdate(tx, userRef)
+		if err != nil {
+			return err
+		}
+		monsterDoc, err := tx.Get(db.Collection("monsters").Doc(monsterId))
+		if err != nil {
+			return firestoreError(err, apperrors.ErrMonsterNotFound)
+		}
+		required := getInt(monsterDoc.Data(), "requiredContributions")
+		if required <= 0 {
+			return fmt.Errorf("モンスターID '%s' のrequiredContributionsが不正です: %w", monsterId, apperrors.ErrMonsterCatalogBroken)
+		}
+		if progress >= required {
+			return fmt.Errorf("必要数は%dです: %w", required, ErrProgressExceedsRequired)
+		}
+
+		now := time.Now()
+		current = models.CurrentMonster{
+			MonsterId:                   monsterId,
+			ProgressContributions:       progress,
+			RequiredContributions:       required,
+			LastContributionReflectedAt: currentMonster.LastContributionReflectedAt,
+			AssignedAt:                  now,
+		}
+		if err := setCurrentMonster(ctx, tx, userRef, doc.Ref.ID, current); err != nil {
+			return fmt.Errorf("currentMonster更新に失敗しました: %w", err)
+		}
+		assigned, err := newMonsterAssignedEvent(id, current, now)
+		if err != nil {
+			return err
+		}
+		return addOutboxEvents(db, tx, []outbox.Event{assigned})
+	})
+	op.end(err)
+	if err != nil {
+		return models.CurrentMonster{}, firestoreError(err, nil)
+	}
+	return current, nil
+}
+
+// ResetProgressで封印済みモンスターを削除する件数
+const (
+	// トランザクションの前に1回で取得して削除する件数
+	sealedMonstersDeleteBatchSize = 200
+	// トランザクションの中で削除できる件数（前の削除の後に封印した分）
+	sealedMonstersDeleteInTxLimit = 100
+	// 削除からやり直す回数の上限
+	resetProgressAttempts = 3
+)
+
+// トランザクションの前の削除の後にsealedMonstersDeleteInTxLimit件より多く封印した場合のエラー（削除からやり直す）
+var errSealedMonstersRemaining = errors.New("封印済みモンスターが残っています")
+
+// ResetProgress は管理者の操作で育成中のモンスターを最初のモンスター（進捗0）に戻し、連続封印記録を0にします
+// 戻す前のコントリビューションを再び数えないよう、コントリビューションを反映した日時は現在にします
+// deleteSealedがtrueの場合は封印済みモンスターと最高記録も削除します
+// 封印済みモンスターは件数に上限がないため、トランザクションの前に一定の件数ずつ削除し、その後に封印した分だけをトランザクションの中で削除します
+// トランザクションの前の削除の後に失敗した場合、封印済みモンスターだけが削除された状態になりますが、再実行すれば残りもリセットされます
+func (r *ContributionRepository) ResetProgress(ctx context.Context, id string, deleteSealed bool) (models.CurrentMonster, error) {
+	for attempt := 1; ; attempt++ {
+		if deleteSealed {
+			if err := deleteSealedMonsters(ctx, r.Client, r.Client.Collection("users").Doc(id).Collection("sealedMonsters")); err != nil {
+				return models.CurrentMonster{}, err
+			}
+		}
+		current, err := r.resetProgress(ctx, id, deleteSealed)
+		if errors.Is(err, errSealedMonstersRemaining) && attempt < resetProgressAttempts {
+			continue
+		}
+		return current, err
+	}
+}
+
+// 育成中のモンスターと記録を戻し、残っている封印済みモンスターと一緒に1つのトランザクションで削除する
+func (r *ContributionRepository) resetProgress(ctx context.Context, id string, deleteSealed bool) (models.CurrentMonster, error) {
+	db := r.Client
+	userRef := db.Collection("users").Doc(id)
+	sealedMonsters := userRef.Collection("sealedMonsters")
+
+	var current models.CurrentMonster
+	op := startFirestoreOperation(ctx, "transaction", "currentMonster")
+	err := db.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(userRef); err != nil {
+			return firestoreError(err, apperrors.ErrUserNotFound)
+		}
+		docs, err := tx.Documents(userRef.Collection("currentMonster")).GetAll()
+		if err != nil {
+			return fmt.Errorf("currentMonsterの取得に失敗しました: %w", err)
+		}
+		// トランザクションでは書き込みの前にすべて読み込む
+		var remaining []*firestore.DocumentSnapshot
+		if deleteSealed {
+			remaining, err = tx.Documents(sealedMonsters.Select().Limit(sealedMonstersDeleteInTxLimit + 1)).GetAll()
+			if err != nil {
+				return fmt.Errorf("封印済みモンスターの取得に失敗しました: %w", err)
+			}
+			if len(remaining) > sealedMonstersDeleteInTxLimit {
+				return errSealedMonstersRemaining
+			}
+		}
+
+		now := time.Now()
+		current = models.CurrentMonster{
+			MonsterId:                   initialMonsterID,
+			ProgressContributions:       0,
+			RequiredContributions:       initialMonsterRequiredContributions,
+			LastContributionReflectedAt: now,
+			AssignedAt:                  now,
+		}
+		// 複数残っている場合も含めて、最初のモンスター以外のドキュメントを削除する
+		for _, doc := range docs {
+			if doc.Ref.ID != initialMonsterID {
+				if err := tx.Delete(doc.Ref); err != nil {
+					return err
+				}
+			}
+		}
+		for _, doc := range remaining {
+			if err := tx.Delete(doc.Ref); err != nil {
+				return err
+			}
+		}
+		if err := setCurrentMonster(ctx, tx, userRef, initialMonsterID, current); err != nil {
+			return fmt.Errorf("currentMonster更新に失敗しました: %w", err)
+		}
+
+		updates := []firestore.Update{
+			{Path: "continuousSealRecord", Value: 0},
+			{Path: "lastContributionReflectedAt", Value: now},
+		}
+		if deleteSealed {
+			updates = append(updates, firestore.Update{Path: "maxSealRecord", Value: 0})
+		}
+		if err := tx.Update(userRef, updates); err != nil {
+			return err
+		}
+		assigned, err := newMonsterAssignedEvent(id, current, now)
+		if err != nil {
+			return err
+		}
+		return addOutboxEvents(db, tx, []outbox.Event{assigned})
+	})
+	op.end(err)
+	if err != nil {
+		return models.CurrentMonster{}, firestoreError(err, nil)
+	}
+	return current, nil
+}
+
+// deleteSealedMonsters は封印済みモンスターをsealedMonstersDeleteBatchSize件ずつ取得して削除します
+func deleteSealedMonsters(ctx context.Context, db *firestore.Client, sealedMonsters *firestore.CollectionRef) error {
+	for {
+		op := startFirestoreOperation(ctx, "query", "sealedMonsters")
+		docs, err := sealedMonsters.Select().Limit(sealedMonstersDeleteBatchSize).Documents(op.ctx).GetAll()
+		op.end(err)
+		if err != nil {
+			return fmt.Errorf("封印済みモンスターの取得に失敗しました: %w", firestoreError(err, nil))
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		op = startFirestoreOperation(ctx, "delete", "sealedMonsters")
+		bw := db.BulkWriter(op.ctx)
+		jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+		for _, doc := range docs {
+			job, err := bw.Delete(doc.Ref)
+			if err != nil {
+				bw.End()
+				op.end(err)
+				return fmt.Errorf("封印済みモンスターの削除に失敗しました: %w", err)
+			}
+			jobs = append(jobs, job)
+		}
+		bw.End()
+		for _, job := range jobs {
+			if _, err = job.Results(); err != nil {
+				break
+			}
+		}
+		op.end(err)
+		if err != nil {
+			return fmt.Errorf("封印済みモンスターの削除に失敗しました: %w", firestoreError(err, nil))
+		}
+		if len(docs) < sealedMonstersDeleteBatchSize {
+			return nil
+		}
+	}
+}
+
+// トランザクションの中でユーザーの存在を確認し、育成中のモンスターのドキュメントを取得する
+func getCurrentMonsterForUpdate(tx *firestore.Transaction, userRef *firestore.DocumentRef) (*firestore.DocumentSnapshot, models.CurrentMonster, error) {
+	if _, err := tx.Get(userRef); err != nil {
+		return nil, models.CurrentMonster{}, firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	docs, err := tx.Documents(userRef.Collection("currentMonster")).GetAll()
+	if err != nil {
+		return nil, models.CurrentMonster{}, fmt.Errorf("currentMonsterの取得に失敗しました: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, models.CurrentMonster{}, apperrors.ErrCurrentMonsterNotFound
+	}
+	return docs[0], currentMonsterFromDoc(docs[0]), nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// 日ごとのコントリビューションを保存するサブコレクション
+const contributionDaysCollection = "contributionDays"
+
+// ContributionHistoryRepository はユーザーの日ごとのコントリビューションの集計を扱います
+type ContributionHistoryRepository struct {
+	Client *firestore.Client
+}
+
+// NewContributionHistoryRepository はContributionHistoryRepositoryを作成します
+func NewContributionHistoryRepository(client *firestore.Client) *ContributionHistoryRepository {
+	return &ContributionHistoryRepository{
+		Client: client,
+	}
+}
+
+// SaveDays はfrom（YYYY-MM-DD）以降の日ごとの集計をdaysで置き換え、usersドキュメントのcontributionHistorySyncedAtをsyncedAtに、
+// contributionHistoryTimeZoneをtimeZoneに更新します
+// from以降でdaysに含まれない日のドキュメントは削除するため、コントリビューションがなくなった日や、別のタイムゾーンで集計した日は残りません。
+// 1つのトランザクションで書き込むため、途中で失敗しても一部の日だけが更新されることはありません（1回あたり最大で約1年分）
+func (r *ContributionHistoryRepository) SaveDays(ctx context.Context, id, from string, days []models.ContributionDay, syncedAt time.Time, timeZone string) error {
+	userRef := r.Client.Collection("users").Doc(id)
+	collection := userRef.Collection(contributionDaysCollection)
+
+	op := startFirestoreOperation(ctx, "transaction", contributionDaysCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// トランザクションでは書き込みの前にすべて読み込む
+		existing, err := tx.Documents(collection.Where("date", ">=", from).Select()).GetAll()
+		if err != nil {
+			return err
+		}
+
+		saving := make(map[string]bool, len(days))
+		for _, day := range days {
+			saving[day.Date] = true
+			if err := tx.Set(collection.Doc(day.Date), map[string]interface{}{
+				"date":         day.Date,
+				"total":        day.Total,
+				"byType":       day.ByType,
+				"byRepository": day.ByRepository,
+				"updatedAt":    syncedAt,
+			}); err != nil {
+				return err
+			}
+		}
+		for _, doc := range existing {
+			if !saving[doc.Ref.ID] {
+				if err := tx.Delete(doc.Ref); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Update(userRef, []firestore.Update{
+			{Path: "contributionHistorySyncedAt", Value: syncedAt},
+			{Path: "contributionHistoryTimeZone", Value: timeZone},
+		})
+	})
+	op.end(err)
+	return firestoreError(err, apperrors.ErrUserNotFound)
+}
+
+// ListDays はfromからtoまで（両端を含む、YYYY-MM-DD）の日ごとの集計を日付順に返します
+// コントリビューションがなかった日のドキュメントはありません
+func (r *ContributionHistoryRepository) ListDays(ctx context.Context, id, from, to string) ([]models.ContributionDay, error) {
+	op := startFirestoreOperation(ctx, "query", contributionDaysCollection)
+	docs, err := r.Client.Collection("users").Doc(id).Collection(contributionDaysCollection).
+		Where("date", ">=", from).
+		Where("date", "<=", to).
+		OrderBy("date", firestore.Asc).
+		Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	days := make([]models.ContributionDay, 0, len(docs))
+	for _, doc := range docs {
+		var day models.ContributionDay
+		if err := doc.DataTo(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
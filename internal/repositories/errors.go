@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"fmt"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestoreError はFirestoreのエラーをドメインエラーでラップします
+// NotFoundの場合はnotFoundを、接続系のエラーの場合はErrDatabaseUnavailableをラップします
+func firestoreError(err error, notFound error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		if notFound != nil {
+			return fmt.Errorf("%v: %w", err, notFound)
+		}
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Errorf("%v: %w", err, apperrors.ErrDatabaseUnavailable)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+
+	"google.golang.org/api/iterator"
+)
+
+// GetUserDocument はusers/{id}ドキュメントの生データを取得します
+func (r *UserRepository) GetUserDocument(ctx context.Context, id string) (map[string]interface{}, error) {
+	op := startFirestoreOperation(ctx, "get", "users")
+	doc, err := r.Client.Collection("users").Doc(id).Get(op.ctx)
+	op.end(err)
+	if err != nil {
+		slog.WarnContext(ctx, "ユーザードキュメントの取得に失敗しました", "user_id", id, "error", err)
+		return nil, firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	return doc.Data(), nil
+}
+
+// ListUserSubcollections はusers/{id}配下に存在するサブコレクション名を列挙します
+// currentMonster, sealedMonsters 以外に今後追加されるサブコレクションもエクスポート対象にするため、固定の一覧は持ちません
+func (r *UserRepository) ListUserSubcollections(ctx context.Context, id string) ([]string, error) {
+	var names []string
+	op := startFirestoreOperation(ctx, "list_collections", "users")
+	iter := r.Client.Collection("users").Doc(id).Collections(op.ctx)
+	for {
+		col, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			op.end(err)
+			return nil, fmt.Errorf("サブコレクションの列挙に失敗しました: %w", firestoreError(err, nil))
+		}
+		names = append(names, col.ID)
+	}
+	op.end(nil)
+	return names, nil
+}
+
+// IterateUserSubcollection はサブコレクションのドキュメントを1件ずつコールバックに渡します
+// GetAllを使わないため、履歴が多いユーザーでも全件をメモリに載せずに処理できます
+func (r *UserRepository) IterateUserSubcollection(ctx context.Context, id, name string, fn func(docID string, data map[string]interface{}) error) error {
+	op := startFirestoreOperation(ctx, "query", name)
+	iter := r.Client.Collection("users").Doc(id).Collection(name).Documents(op.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			op.end(nil)
+			return nil
+		}
+		if err != nil {
+			op.end(err)
+			return fmt.Errorf("サブコレクション '%s' の取得に失敗しました: %w", name, firestoreError(err, nil))
+		}
+		if err := fn(doc.Ref.ID, doc.Data()); err != nil {
+			// 書き出し側のエラーなのでFirestoreのエラーには数えない
+			op.end(nil)
+			return err
+		}
+	}
+}
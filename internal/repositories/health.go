@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+
+	"google.golang.org/api/iterator"
+)
+
+// Ping はmonstersコレクションを1件だけ読み、Firestoreに接続できるか確認します
+// monstersはコントリビューションの反映に必須のマスターデータのため、読めない場合は正常に動作できません
+func (r *ContributionRepository) Ping(ctx context.Context) error {
+	op := startFirestoreOperation(ctx, "query", "monsters")
+	iter := r.Client.Collection("monsters").Limit(1).Documents(op.ctx)
+	defer iter.Stop()
+	_, err := iter.Next()
+	if err == iterator.Done {
+		err = nil
+	}
+	op.end(err)
+	return firestoreError(err, nil)
+}
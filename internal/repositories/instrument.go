@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// firestoreOperation はFirestoreの1回の操作をスパンとメトリクスに記録します
+//
+//	op := startFirestoreOperation(ctx, "get", "users")
+//	doc, err := db.Collection("users").Doc(id).Get(op.ctx)
+//	op.end(err)
+type firestoreOperation struct {
+	ctx        context.Context
+	span       trace.Span
+	operation  string
+	collection string
+}
+
+// startFirestoreOperation はFirestore操作のスパンを開始します
+// 操作にはop.ctxを渡し、完了後にop.endを呼び出してください
+func startFirestoreOperation(ctx context.Context, operation, collection string) *firestoreOperation {
+	ctx, span := tracing.Tracer().Start(ctx, "firestore."+operation+" "+collection,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "firestore"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.collection.name", collection),
+		),
+	)
+	return &firestoreOperation{ctx: ctx, span: span, operation: operation, collection: collection}
+}
+
+// end はスパンを終了し、操作回数とエラーをメトリクスに記録します
+func (o *firestoreOperation) end(err error) {
+	metrics.ObserveFirestoreOperation(o.operation, o.collection, err)
+	tracing.RecordError(o.span, err)
+	o.span.End()
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// MonsterRepository はmonstersコレクション（モンスターのマスターデータ）を扱います
+type MonsterRepository struct {
+	Client *firestore.Client
+}
+
+// NewMonsterRepository はMonsterRepositoryを作成します
+func NewMonsterRepository(client *firestore.Client) *MonsterRepository {
+	return &MonsterRepository{
+		Client: client,
+	}
+}
+
+// GetMonsters は指定したIDのモンスターをまとめて取得し、monsterIdをキーにして返します
+// 存在しないIDは結果に含めません
+func (r *MonsterRepository) GetMonsters(ctx context.Context, ids []string) (map[string]models.Monster, error) {
+	monsters := make(map[string]models.Monster, len(ids))
+	if len(ids) == 0 {
+		return monsters, nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		refs = append(refs, r.Client.Collection("monsters").Doc(id))
+	}
+
+	op := startFirestoreOperation(ctx, "get_all", "monsters")
+	docs, err := r.Client.GetAll(op.ctx, refs)
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var monster models.Monster
+		if err := doc.DataTo(&monster); err != nil {
+			// マスターデータの一部が壊れていても、表示に使う他のモンスターは返す
+			slog.WarnContext(ctx, "モンスターの読み込みに失敗しました", "monster_id", doc.Ref.ID, "error", err)
+			continue
+		}
+		monster.MonsterId = doc.Ref.ID
+		monsters[doc.Ref.ID] = monster
+	}
+	return monsters, nil
+}
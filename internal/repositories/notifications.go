@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// プッシュ通知を送る端末を保存するサブコレクション
+const deviceTokensCollection = "deviceTokens"
+
+// トークンはスラッシュなどを含みうるうえ長いため、ハッシュをドキュメントIDにする
+// 同じトークンを何度登録しても1つのドキュメントになる
+func deviceTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveDeviceToken は端末のトークンを登録します
+// 登録済みのトークンの場合はplatformとlastSeenAtだけを更新し、createdをfalseで返します
+func (r *UserRepository) SaveDeviceToken(ctx context.Context, id, token, platform string, now time.Time) (_ *models.DeviceToken, created bool, _ error) {
+	userRef := r.Client.Collection("users").Doc(id)
+	ref := userRef.Collection(deviceTokensCollection).Doc(deviceTokenID(token))
+
+	var device models.DeviceToken
+	op := startFirestoreOperation(ctx, "transaction", deviceTokensCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		created = false
+		// 削除済みのユーザーの配下に端末だけが残らないよう、ユーザーの存在を確認する
+		if _, err := tx.Get(userRef); err != nil {
+			return err
+		}
+
+		device = models.DeviceToken{Token: token, Platform: platform, CreatedAt: now, LastSeenAt: now}
+		snap, err := tx.Get(ref)
+		if err == nil {
+			if err := snap.DataTo(&device); err != nil {
+				return err
+			}
+			device.Platform = platform
+			device.LastSeenAt = now
+			return tx.Update(ref, []firestore.Update{
+				{Path: "platform", Value: platform},
+				{Path: "lastSeenAt", Value: now},
+			})
+		}
+		if status.Code(err) != codes.NotFound {
+			return err
+		}
+		created = true
+		return tx.Create(ref, device)
+	})
+	op.end(err)
+	if err != nil {
+		return nil, false, firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	device.DeviceId = ref.ID
+	return &device, created, nil
+}
+
+// ListDeviceTokens はユーザーの端末を最近使われた順に返します
+func (r *UserRepository) ListDeviceTokens(ctx context.Context, id string) ([]models.DeviceToken, error) {
+	op := startFirestoreOperation(ctx, "query", deviceTokensCollection)
+	docs, err := r.Client.Collection("users").Doc(id).Collection(deviceTokensCollection).
+		OrderBy("lastSeenAt", firestore.Desc).
+		Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	devices := make([]models.DeviceToken, 0, len(docs))
+	for _, doc := range docs {
+		var device models.DeviceToken
+		if err := doc.DataTo(&device); err != nil {
+			return nil, err
+		}
+		device.DeviceId = doc.Ref.ID
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// DeleteDeviceToken は端末の登録を解除します
+// 登録されていない場合はapperrors.ErrDeviceNotFoundを返します
+func (r *UserRepository) DeleteDeviceToken(ctx context.Context, id, deviceId string) error {
+	op := startFirestoreOperation(ctx, "delete", deviceTokensCollection)
+	_, err := r.Client.Collection("users").Doc(id).Collection(deviceTokensCollection).Doc(deviceId).Delete(op.ctx, firestore.Exists)
+	op.end(err)
+	return firestoreError(err, apperrors.ErrDeviceNotFound)
+}
+
+// DeleteDeviceTokensByValue はトークンの値を指定して端末の登録を解除します
+// FCMから無効と返されたトークンの削除に使うため、登録されていないトークンは無視します
+func (r *UserRepository) DeleteDeviceTokensByValue(ctx context.Context, id string, tokens []string) error {
+	collection := r.Client.Collection("users").Doc(id).Collection(deviceTokensCollection)
+	for _, token := range tokens {
+		op := startFirestoreOperation(ctx, "delete", deviceTokensCollection)
+		_, err := collection.Doc(deviceTokenID(token)).Delete(op.ctx)
+		op.end(err)
+		if err != nil {
+			return fmt.Errorf("無効な端末の削除に失敗しました: %w", firestoreError(err, nil))
+		}
+	}
+	return nil
+}
+
+// IterateStreakReminderCandidates は最後にコントリビューションを反映した日時（lastContributionReflectedAt）が
+// fromより後でto以前のユーザーを1件ずつコールバックに渡します
+func (r *UserRepository) IterateStreakReminderCandidates(ctx context.Context, from, to time.Time, fn func(id string, user models.User) error) error {
+	op := startFirestoreOperation(ctx, "query", "users")
+	iter := r.Client.Collection("users").
+		Where("lastContributionReflectedAt", ">", from).
+		Where("lastContributionReflectedAt", "<=", to).
+		Documents(op.ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			op.end(nil)
+			return nil
+		}
+		if err != nil {
+			op.end(err)
+			return fmt.Errorf("ユーザーの取得に失敗しました: %w", firestoreError(err, nil))
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			// 形式の壊れたユーザーがいても他のユーザーには通知する
+			continue
+		}
+		if err := fn(doc.Ref.ID, user); err != nil {
+			// 通知側のエラーなのでFirestoreのエラーには数えない
+			op.end(nil)
+			return err
+		}
+	}
+}
+
+// ClaimStreakReminder は連続記録の期限deadlineについての通知をまだ送っていなければ、送信済みとして記録してtrueを返します
+// 複数のサーバーが同時に確認しても1回だけ通知するため、トランザクションで確認と記録を行います
+// 確認の後にコントリビューションが反映されて期限が変わっていた場合もfalseを返します
+func (r *UserRepository) ClaimStreakReminder(ctx context.Context, id string, deadline time.Time) (bool, error) {
+	ref := r.Client.Collection("users").Doc(id)
+
+	claimed := false
+	op := startFirestoreOperation(ctx, "transaction", "users")
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		snap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var user models.User
+		if err := snap.DataTo(&user); err != nil {
+			return err
+		}
+		if !user.LastContributionReflectedAt.Add(24*time.Hour).Equal(deadline) || user.StreakReminderSentFor.Equal(deadline) {
+			return nil
+		}
+		claimed = true
+		return tx.Update(ref, []firestore.Update{{Path: "streakReminderSentFor", Value: deadline}})
+	})
+	op.end(err)
+	if err != nil {
+		return false, firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"geekcamp-vol10-backend/internal/outbox"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ドメインイベントの配送待ちと、配送を諦めたイベントを保存するコレクション
+const (
+	outboxCollection           = "outbox"
+	outboxDeadLetterCollection = "outboxDeadLetters"
+)
+
+// OutboxRepository はoutboxコレクションを配送待ちのイベントの保存先（outbox.Store）として扱います
+type OutboxRepository struct {
+	Client *firestore.Client
+}
+
+// NewOutboxRepository はOutboxRepositoryを作成します
+func NewOutboxRepository(client *firestore.Client) *OutboxRepository {
+	return &OutboxRepository{
+		Client: client,
+	}
+}
+
+// outboxのドキュメント
+type outboxDoc struct {
+	Type    string `firestore:"type"`
+	UserID  string `firestore:"userId"`
+	Payload string `firestore:"payload"`
+	// 次に配送を試みる日時。取り出し中はleaseの終わり
+	NextAttemptAt time.Time `firestore:"nextAttemptAt"`
+	CreatedAt     time.Time `firestore:"createdAt"`
+	Attempts      int       `firestore:"attempts"`
+	Delivered     []string  `firestore:"delivered"`
+	LastError     string    `firestore:"lastError,omitempty"`
+}
+
+func (d outboxDoc) event(id string) outbox.Event {
+	return outbox.Event{
+		ID:        id,
+		Type:      d.Type,
+		UserID:    d.UserID,
+		Payload:   []byte(d.Payload),
+		CreatedAt: d.CreatedAt,
+		Attempts:  d.Attempts,
+		Delivered: d.Delivered,
+	}
+}
+
+// addOutboxEvents は状態を変更するトランザクションの中で、配送待ちのイベントを作成します
+// トランザクションがコミットされた場合だけイベントが残るため、状態の変更とイベントが食い違うことはありません
+func addOutboxEvents(client *firestore.Client, tx *firestore.Transaction, events []outbox.Event) error {
+	for _, ev := range events {
+		if err := tx.Create(client.Collection(outboxCollection).NewDoc(), outboxDoc{
+			Type:          ev.Type,
+			UserID:        ev.UserID,
+			Payload:       string(ev.Payload),
+			NextAttemptAt: ev.CreatedAt,
+			CreatedAt:     ev.CreatedAt,
+			Delivered:     []string{},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDue は配送予定の日時がnow以前のイベントのIDを古い順に最大limit件返します
+func (r *OutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	op := startFirestoreOperation(ctx, "query", outboxCollection)
+	docs, err := r.Client.Collection(outboxCollection).
+		Where("nextAttemptAt", "<=", now).
+		OrderBy("nextAttemptAt", firestore.Asc).
+		Limit(limit).
+		Select().
+		Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, fmt.Errorf("配送を待つイベントの取得に失敗しました: %w", firestoreError(err, nil))
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Ref.ID)
+	}
+	return ids, nil
+}
+
+// Claim はトランザクションでイベントidの配送予定の日時をleaseの終わりに進めて取り出します
+// 他のサーバーが同時に取り出した場合や、配送を終えて削除した場合はnilを返します
+func (r *OutboxRepository) Claim(ctx context.Context, id string, now time.Time, lease time.Duration) (*outbox.Event, error) {
+	ref := r.Client.Collection(outboxCollection).Doc(id)
+
+	var claimed *outbox.Event
+	op := startFirestoreOperation(ctx, "transaction", outboxCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+		snap, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			// 他のサーバーが配送を終えた
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var d outboxDoc
+		if err := snap.DataTo(&d); err != nil {
+			return err
+		}
+		if d.NextAttemptAt.After(now) {
+			// 他のサーバーが取り出した
+			return nil
+		}
+		ev := d.event(snap.Ref.ID)
+		claimed = &ev
+		return tx.Update(snap.Ref, []firestore.Update{{Path: "nextAttemptAt", Value: now.Add(lease)}})
+	})
+	op.end(err)
+	if err != nil {
+		return nil, fmt.Errorf("イベント %s の取り出しに失敗しました: %w", id, firestoreError(err, nil))
+	}
+	return claimed, nil
+}
+
+// Complete は配送を終えたイベントを削除します
+func (r *OutboxRepository) Complete(ctx context.Context, id string) error {
+	op := startFirestoreOperation(ctx, "delete", outboxCollection)
+	_, err := r.Client.Collection(outboxCollection).Doc(id).Delete(op.ctx)
+	op.end(err)
+	return firestoreError(err, nil)
+}
+
+// Retry は配送済みのコンシューマーと試行回数を記録し、nextに再び配送されるようにします
+func (r *OutboxRepository) Retry(ctx context.Context, ev outbox.Event, next time.Time, lastError string) error {
+	op := startFirestoreOperation(ctx, "update", outboxCollection)
+	_, err := r.Client.Collection(outboxCollection).Doc(ev.ID).Update(op.ctx, []firestore.Update{
+		{Path: "nextAttemptAt", Value: next},
+		{Path: "attempts", Value: ev.Attempts},
+		{Path: "delivered", Value: ev.Delivered},
+		{Path: "lastError", Value: lastError},
+	})
+	op.end(err)
+	return firestoreError(err, nil)
+}
+
+// DeadLetter はイベントをoutboxDeadLettersに移します
+// 移したイベントは配送されません。原因を取り除いた後はoutboxに戻すと再び配送されます
+func (r *OutboxRepository) DeadLetter(ctx context.Context, ev outbox.Event, lastError string) error {
+	ref := r.Client.Collection(outboxCollection).Doc(ev.ID)
+	deadRef := r.Client.Collection(outboxDeadLetterCollection).Doc(ev.ID)
+
+	op := startFirestoreOperation(ctx, "transaction", outboxDeadLetterCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(deadRef, map[string]interface{}{
+			"type":         ev.Type,
+			"userId":       ev.UserID,
+			"payload":      string(ev.Payload),
+			"createdAt":    ev.CreatedAt,
+			"attempts":     ev.Attempts,
+			"delivered":    ev.Delivered,
+			"lastError":    lastError,
+			"deadLetterAt": time.Now(),
+		}); err != nil {
+			return err
+		}
+		return tx.Delete(ref)
+	})
+	op.end(err)
+	return firestoreError(err, nil)
+}
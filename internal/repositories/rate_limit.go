@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"geekcamp-vol10-backend/internal/ratelimit"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// レート制限のバケットを保存するコレクション
+const rateLimitsCollection = "rateLimits"
+
+// RateLimitRepository はレート制限のトークンバケットをFirestoreに保存するratelimit.Storeです
+// 複数インスタンスで同じバケットを共有するため、インスタンス数に関係なく同じ上限で制限できます。
+// 満タンに戻ったバケットは不要なので、expireAtフィールドにFirestoreのTTLポリシーを設定して削除してください
+type RateLimitRepository struct {
+	Client *firestore.Client
+}
+
+// NewRateLimitRepository はRateLimitRepositoryを作成します
+func NewRateLimitRepository(client *firestore.Client) *RateLimitRepository {
+	return &RateLimitRepository{
+		Client: client,
+	}
+}
+
+// Take はkeyのバケットからトークンを1つ取り出します
+// 同じキーへの同時リクエストで二重にトークンを使わないよう、読み込みと更新を1つのトランザクションで行います
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	// キーにはUIDやIPアドレス（IPv6の ":" など）が入るため、ドキュメントIDにはハッシュを使う
+	sum := sha256.Sum256([]byte(key))
+	ref := r.Client.Collection(rateLimitsCollection).Doc(hex.EncodeToString(sum[:]))
+
+	var result ratelimit.Result
+	op := startFirestoreOperation(ctx, "transaction", rateLimitsCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var bucket ratelimit.Bucket
+		snap, err := tx.Get(ref)
+		switch {
+		case err == nil:
+			data := snap.Data()
+			if tokens, ok := data["tokens"].(float64); ok {
+				bucket.Tokens = tokens
+			}
+			if updatedAt, ok := data["updatedAt"].(time.Time); ok {
+				bucket.UpdatedAt = updatedAt
+			}
+		case status.Code(err) != codes.NotFound:
+			return err
+		}
+
+		now := time.Now()
+		bucket, result = limit.Take(bucket, now)
+		return tx.Set(ref, map[string]interface{}{
+			"key":       key,
+			"tokens":    bucket.Tokens,
+			"updatedAt": bucket.UpdatedAt,
+			"expireAt":  now.Add(result.ResetAfter),
+		})
+	}, firestore.MaxAttempts(3))
+	op.end(err)
+	if err != nil {
+		return ratelimit.Result{}, firestoreError(err, nil)
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// キャッシュしたレスポンスを保存するコレクション
+const responseCacheCollection = "responseCache"
+
+// ResponseCacheRepository はGitHubのレスポンスなどをFirestoreにキャッシュするcache.Storeです
+// 複数インスタンスで同じキャッシュを共有できます。
+// 期限切れのドキュメントは読み込み時に無視するだけなので、expireAtフィールドにFirestoreのTTLポリシーを設定して削除してください
+type ResponseCacheRepository struct {
+	Client *firestore.Client
+}
+
+// NewResponseCacheRepository はResponseCacheRepositoryを作成します
+func NewResponseCacheRepository(client *firestore.Client) *ResponseCacheRepository {
+	return &ResponseCacheRepository{
+		Client: client,
+	}
+}
+
+// Get はkeyの値を返します。存在しない・期限切れの場合はfalseを返します
+func (r *ResponseCacheRepository) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	op := startFirestoreOperation(ctx, "get", responseCacheCollection)
+	snap, err := r.doc(key).Get(op.ctx)
+	if status.Code(err) == codes.NotFound {
+		op.end(nil)
+		return nil, false, nil
+	}
+	op.end(err)
+	if err != nil {
+		return nil, false, firestoreError(err, nil)
+	}
+
+	data := snap.Data()
+	expireAt, _ := data["expireAt"].(time.Time)
+	value, ok := data["value"].([]byte)
+	if !ok || !time.Now().Before(expireAt) {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// Set はkeyに値をttlの間保存します
+func (r *ResponseCacheRepository) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	op := startFirestoreOperation(ctx, "set", responseCacheCollection)
+	_, err := r.doc(key).Set(op.ctx, map[string]interface{}{
+		"key":      key,
+		"value":    value,
+		"expireAt": time.Now().Add(ttl),
+	})
+	op.end(err)
+	return firestoreError(err, nil)
+}
+
+// キーにはGitHubのログイン名などが入るため、ドキュメントIDにはハッシュを使う
+func (r *ResponseCacheRepository) doc(key string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(key))
+	return r.Client.Collection(responseCacheCollection).Doc(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/docid"
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidCursor はページングのカーソルが正しくない場合のエラーです
+// 形式が不正な場合のほか、カーソルが指すドキュメントが削除された場合も含みます
+var ErrInvalidCursor = fmt.Errorf("カーソルが正しくありません: %w", apperrors.ErrValidation)
+
+// SealedMonstersQuery は封印済みモンスターの一覧の取得条件です
+type SealedMonstersQuery struct {
+	// 1ページの件数
+	Limit int
+	// trueの場合はsealedAtの新しい順、falseの場合は古い順
+	Descending bool
+	// sealedAtの範囲（From以上To未満）。ゼロ値の場合は指定なし
+	From time.Time
+	To   time.Time
+	// 指定した場合はそのモンスターのみ
+	MonsterId string
+	// 前のページのNextCursor
+	Cursor string
+}
+
+// SealedMonstersPage は封印済みモンスターの一覧の1ページ分です
+type SealedMonstersPage struct {
+	SealedMonsters []models.SealedMonster
+	// 次のページがない場合は空
+	NextCursor string
+}
+
+// ListSealedMonsters はユーザーの封印済みモンスターをsealedAt順に1ページ分取得します
+// 同じsealedAtのドキュメントはドキュメントID順に並べるため、ページの境界で重複・欠落しません
+func (r *UserRepository) ListSealedMonsters(ctx context.Context, id string, q SealedMonstersQuery) (*SealedMonstersPage, error) {
+	collection := r.Client.Collection("users").Doc(id).Collection("sealedMonsters")
+
+	direction := firestore.Asc
+	if q.Descending {
+		direction = firestore.Desc
+	}
+	query := collection.Query
+	if q.MonsterId != "" {
+		query = query.Where("monsterId", "==", q.MonsterId)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("sealedAt", ">=", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("sealedAt", "<", q.To)
+	}
+	query = query.OrderBy("sealedAt", direction).OrderBy(firestore.DocumentID, direction)
+
+	if q.Cursor != "" {
+		after, err := documentCursor(ctx, collection, q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.StartAfter(after)
+	}
+
+	// 次のページがあるかを判定するため1件多く取得する
+	op := startFirestoreOperation(ctx, "query", "sealedMonsters")
+	docs, err := query.Limit(q.Limit + 1).Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	page := &SealedMonstersPage{SealedMonsters: make([]models.SealedMonster, 0, min(len(docs), q.Limit))}
+	if len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(docs[len(docs)-1].Ref.ID))
+	}
+	for _, doc := range docs {
+		page.SealedMonsters = append(page.SealedMonsters, sealedMonsterFromDoc(ctx, doc))
+	}
+	return page, nil
+}
+
+// CountSealedMonsters はユーザーの封印済みモンスターの数を返します
+// ドキュメントを読み込まずに集計クエリで数えます
+func (r *UserRepository) CountSealedMonsters(ctx context.Context, id string) (int64, error) {
+	op := startFirestoreOperation(ctx, "aggregate", "sealedMonsters")
+	result, err := r.Client.Collection("users").Doc(id).Collection("sealedMonsters").
+		NewAggregationQuery().WithCount("count").Get(op.ctx)
+	op.end(err)
+	if err != nil {
+		return 0, firestoreError(err, nil)
+	}
+
+	count, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("sealedMonstersの集計結果の形式が不明です: %T", result["count"])
+	}
+	return count.GetIntegerValue(), nil
+}
+
+// documentCursor はカーソル（ドキュメントIDをbase64urlでエンコードしたもの）が指すcollectionのドキュメントを取得します
+// StartAfterにスナップショットを渡すと、並び順のフィールドの値をそのドキュメントから取り出して使います
+func documentCursor(ctx context.Context, collection *firestore.CollectionRef, cursor string) (*firestore.DocumentSnapshot, error) {
+	docID, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !docid.Valid(string(docID)) {
+		return nil, ErrInvalidCursor
+	}
+
+	op := startFirestoreOperation(ctx, "get", collection.ID)
+	snap, err := collection.Doc(string(docID)).Get(op.ctx)
+	if status.Code(err) == codes.NotFound {
+		op.end(nil)
+		return nil, ErrInvalidCursor
+	}
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+	return snap, nil
+}
+
+// sealedMonsterFromDoc はsealedMonstersのドキュメントをSealedMonsterに変換します
+// sealedAtは過去のデータで文字列(RFC3339)として保存されているものもあるため、手動で変換します
+func sealedMonsterFromDoc(ctx context.Context, doc *firestore.DocumentSnapshot) models.SealedMonster {
+	data := doc.Data()
+
+	// 手動でSealedMonsterを構築
+	sm := models.SealedMonster{
+		MonsterId:   getString(data, "monsterId"),
+		MonsterName: getString(data, "monsterName"),
+	}
+
+	// SealedAt - 複数の型に対応
+	if sealedAtValue, exists := data["sealedAt"]; exists {
+		switch v := sealedAtValue.(type) {
+		case time.Time:
+			sm.SealedAt = v
+		case string:
+			if parsedTime, err := time.Parse(time.RFC3339, v); err == nil {
+				sm.SealedAt = parsedTime
+			} else {
+				slog.WarnContext(ctx, "sealedAtの文字列パースに失敗しました", "doc_id", doc.Ref.ID, "error", err)
+			}
+		default:
+			slog.WarnContext(ctx, "sealedAtの型が不明です", "doc_id", doc.Ref.ID, "type", fmt.Sprintf("%T", v))
+		}
+	}
+	return sm
+}
@@ -2,12 +2,16 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
+	"geekcamp-vol10-backend/internal/apperrors"
 	"geekcamp-vol10-backend/internal/models"
 
 	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // UserRepository 構造体
@@ -15,7 +19,7 @@ type UserRepository struct {
 	Client *firestore.Client
 }
 
-// NewUserRepository creates a new UserRepository
+// NewUserRepository はusersコレクションとそのサブコレクションを扱うリポジトリを作成します
 func NewUserRepository(client *firestore.Client) *UserRepository {
 	return &UserRepository{
 		Client: client,
@@ -23,103 +27,131 @@ func NewUserRepository(client *firestore.Client) *UserRepository {
 }
 
 
-// userコレクション保存
-func SaveUser(ctx context.Context, db *firestore.Client, user models.User) error {
-	log.Printf("SaveUser: ユーザー '%s' を保存中...", user.FirebaseId)
-	log.Printf("SaveUser: Firestoreクライアント状態: %v", db != nil)
+// ErrUserAlreadyExists は同じIDのユーザーが既に登録されている場合のエラーです
+var ErrUserAlreadyExists = fmt.Errorf("ユーザーは既に登録されています: %w", apperrors.ErrConflict)
 
-	if db == nil {
-		log.Printf("SaveUser: Firestoreクライアントがnilです")
-		return fmt.Errorf("Firestoreクライアントが初期化されていません")
-	}
-
-	// ユーザー情報をFirestoreに保存
-	userData := map[string]interface{}{
-		"firebaseId":           user.FirebaseId,
-		"githubUserName":       user.GithubUserName,
-		"photoURL":             user.PhotoURL,
-		"createdAt":            user.CreatedAt,
-		"continuousSealRecord": user.ContinuousSealRecord,
-		"maxSealRecord":        user.MaxSealRecord,
-	}
-
-	log.Printf("SaveUser: Firestoreに保存するデータ: %+v", userData)
-	_, err := db.Collection("users").Doc(user.FirebaseId).Set(ctx, userData)
-	if err != nil {
-		log.Printf("SaveUser: Firestore保存エラー: %v", err)
-		return err
-	}
-
-	log.Printf("SaveUser: ユーザー '%s' の保存に成功しました", user.FirebaseId)
+// 初期モンスター（スライム）
+const (
+	initialMonsterID                    = "001"
+	initialMonsterRequiredContributions = 30
+)
 
-	// currentMonsterサブコレクションの初期値を保存
-	log.Printf("SaveUser: currentMonsterサブコレクションの初期値を作成中...")
-	currentMonsterData := map[string]interface{}{
-		"monsterId":                   "001", // 初期モンスターID（スライム）
-		"progressContributions":       0,
-		"requiredContributions":       30, // 初期モンスター（スライム）の必要コントリビューション数
-		"lastContributionReflectedAt": user.CreatedAt,
-		"assignedAt":                  user.CreatedAt,
+// CreateUserIfAbsent はユーザーが未登録の場合のみ、usersドキュメントとcurrentMonsterの初期値を作成します
+// 既に登録済みの場合は何も書き込まず、既存のユーザーとErrUserAlreadyExistsを返します
+// 両方の書き込みを1つのトランザクションで行うため、リトライされてもサブコレクションのドキュメントは重複しません
+func (r *UserRepository) CreateUserIfAbsent(ctx context.Context, user models.User) (*models.User, error) {
+	db := r.Client
+	userRef := db.Collection("users").Doc(user.FirebaseId)
+	// currentMonsterのドキュメントIDはmonsterIdと揃える（SaveContributionはドキュメントIDをmonsterIdとして扱う）
+	currentMonsterRef := userRef.Collection("currentMonster").Doc(initialMonsterID)
+
+	var existing *models.User
+	op := startFirestoreOperation(ctx, "transaction", "users")
+	err := db.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing = nil
+
+		snap, err := tx.Get(userRef)
+		if err == nil {
+			var u models.User
+			if err := snap.DataTo(&u); err != nil {
+				return err
+			}
+			existing = &u
+			return ErrUserAlreadyExists
+		}
+		if status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		userData := map[string]interface{}{
+			"firebaseId":           user.FirebaseId,
+			"githubUserName":       user.GithubUserName,
+			"photoURL":             user.PhotoURL,
+			"createdAt":            user.CreatedAt,
+			"continuousSealRecord": user.ContinuousSealRecord,
+			"maxSealRecord":        user.MaxSealRecord,
+			// 連続封印記録の判定の基準（最初のコントリビューションの反映までは登録日時）
+			"lastContributionReflectedAt": user.CreatedAt,
+			// 新規登録のデータは最新の形式で作成するため、マイグレーションは不要
+			"schemaVersion": models.UserSchemaVersion,
+		}
+		if err := tx.Create(userRef, userData); err != nil {
+			return err
+		}
+
+		// currentMonsterサブコレクションの初期値
+		currentMonsterData := map[string]interface{}{
+			"monsterId":                   initialMonsterID,
+			"progressContributions":       0,
+			"requiredContributions":       initialMonsterRequiredContributions,
+			"lastContributionReflectedAt": user.CreatedAt,
+			"assignedAt":                  user.CreatedAt,
+		}
+		// sealedMonstersサブコレクションは初期状態では空なので、プレースホルダーは作成しない
+		return tx.Create(currentMonsterRef, currentMonsterData)
+	})
+	if errors.Is(err, ErrUserAlreadyExists) {
+		// 既に登録済みの場合は正常系として扱い、エラーには数えない
+		op.end(nil)
+		slog.InfoContext(ctx, "CreateUserIfAbsent: ユーザーは既に登録済みです", "user_id", user.FirebaseId)
+		return existing, ErrUserAlreadyExists
 	}
-	
-	// ドキュメントIDは自動生成（README.mdの仕様通り）
-	_, _, err = db.Collection("users").Doc(user.FirebaseId).Collection("currentMonster").Add(ctx, currentMonsterData)
+	op.end(err)
 	if err != nil {
-		log.Printf("SaveUser: currentMonster初期値の保存に失敗: %v", err)
-		return fmt.Errorf("currentMonster初期値の保存に失敗: %v", err)
+		slog.ErrorContext(ctx, "CreateUserIfAbsent: Firestore保存エラー", "user_id", user.FirebaseId, "error", err)
+		return nil, firestoreError(err, nil)
 	}
-	log.Printf("SaveUser: currentMonster初期値の保存に成功（monsterId: 001, requiredContributions: 30）")
-
-	// sealedMonstersサブコレクションは初期状態では空なので、プレースホルダーは作成しない
-	log.Printf("SaveUser: sealedMonstersサブコレクションは初期状態では空のため、プレースホルダーは作成しません")
 
-	log.Printf("SaveUser: ユーザー '%s' とサブコレクションの初期化が完了しました", user.FirebaseId)
-	return nil
+	slog.InfoContext(ctx, "CreateUserIfAbsent: ユーザーとcurrentMonster初期値を作成しました", "user_id", user.FirebaseId, "monster_id", initialMonsterID)
+	return nil, nil
 }
 
 // サブコレクションでcurrentMonster
+// ドキュメントIDはmonsterIdと揃える（SaveContributionはドキュメントIDをmonsterIdとして扱う）
 func (r *UserRepository) SaveCurrentMonster(ctx context.Context, firebaseId string, monster models.CurrentMonster) error {
-	log.Printf("SaveCurrentMonster: ユーザー '%s' のcurrentMonsterを保存中...", firebaseId)
-	_, err := r.Client.Collection("users").Doc(firebaseId).Collection("currentMonster").Doc("monster").Set(ctx, map[string]interface{}{
+	op := startFirestoreOperation(ctx, "set", "currentMonster")
+	_, err := r.Client.Collection("users").Doc(firebaseId).Collection("currentMonster").Doc(monster.MonsterId).Set(op.ctx, map[string]interface{}{
 		"monsterId":                   monster.MonsterId,
 		"progressContributions":       monster.ProgressContributions,
 		"lastContributionReflectedAt": monster.LastContributionReflectedAt, // contributionからもらう
 		"assignedAt":                  monster.AssignedAt,
 		"requiredContributions":       monster.RequiredContributions, // contributionからもらう
 	})
+	op.end(err)
 	if err != nil {
-		log.Printf("SaveCurrentMonster: currentMonster保存エラー: %v", err)
-	} else {
-		log.Printf("SaveCurrentMonster: currentMonster保存成功")
+		slog.ErrorContext(ctx, "SaveCurrentMonster: currentMonster保存エラー", "user_id", firebaseId, "error", err)
 	}
 	return err
 }
 
 // SealedMonsters
 func (r *UserRepository) SaveSealedMonster(ctx context.Context, firebaseId string, sealed models.SealedMonster) error {
-	log.Printf("SaveSealedMonster: ユーザー '%s' のsealedMonster '%s' を保存中...", firebaseId, sealed.MonsterId)
-	_, err := r.Client.Collection("users").Doc(firebaseId).Collection("sealedMonsters").Doc(sealed.MonsterId).Set(ctx, map[string]interface{}{
+	op := startFirestoreOperation(ctx, "set", "sealedMonsters")
+	_, err := r.Client.Collection("users").Doc(firebaseId).Collection("sealedMonsters").Doc(sealed.MonsterId).Set(op.ctx, map[string]interface{}{
 		"monsterId":   sealed.MonsterId,
 		"monsterName": sealed.MonsterName,
 		"sealedAt":    sealed.SealedAt,
 	})
+	op.end(err)
 	if err != nil {
-		log.Printf("SaveSealedMonster: sealedMonster保存エラー: %v", err)
-	} else {
-		log.Printf("SaveSealedMonster: sealedMonster保存成功")
+		slog.ErrorContext(ctx, "SaveSealedMonster: sealedMonster保存エラー", "user_id", firebaseId, "monster_id", sealed.MonsterId, "error", err)
 	}
 	return err
 }
 
 // ユーザーとサブコレクションを取得
 func (r *UserRepository) GetUser(ctx context.Context, firebaseId string) (map[string]interface{}, error) {
-	mainDoc, err := r.Client.Collection("users").Doc(firebaseId).Get(ctx)
+	op := startFirestoreOperation(ctx, "get", "users")
+	mainDoc, err := r.Client.Collection("users").Doc(firebaseId).Get(op.ctx)
+	op.end(err)
 	if err != nil {
 		return nil, err
 	}
 	mainData := mainDoc.Data()
 
-	subDocs, err := mainDoc.Ref.Collection("currentMonster").Documents(ctx).GetAll()
+	op = startFirestoreOperation(ctx, "query", "currentMonster")
+	subDocs, err := mainDoc.Ref.Collection("currentMonster").Documents(op.ctx).GetAll()
+	op.end(err)
 	if err != nil {
 		return nil, err
 	}
@@ -129,10 +161,14 @@ func (r *UserRepository) GetUser(ctx context.Context, firebaseId string) (map[st
 	return mainData, nil
 }
 
-func GetUserByIDRepo(ctx context.Context, client *firestore.Client, id string) (*models.User, error) {
-	doc, err := client.Collection("users").Doc(id).Get(ctx)
+// GetUserByID はusers/{id}ドキュメントをユーザーとして取得します
+// サブコレクション（currentMonster, sealedMonsters）は含みません
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	op := startFirestoreOperation(ctx, "get", "users")
+	doc, err := r.Client.Collection("users").Doc(id).Get(op.ctx)
+	op.end(err)
 	if err != nil {
-		return nil, err
+		return nil, firestoreError(err, apperrors.ErrUserNotFound)
 	}
 
 	var user models.User
@@ -142,3 +178,40 @@ func GetUserByIDRepo(ctx context.Context, client *firestore.Client, id string) (
 
 	return &user, nil
 }
+
+// ユーザードキュメントの指定フィールドを更新します
+// ドキュメントが存在しない場合はFirestoreのNotFoundエラーを返します
+func (r *UserRepository) UpdateUserFields(ctx context.Context, id string, updates []firestore.Update) error {
+	op := startFirestoreOperation(ctx, "update", "users")
+	_, err := r.Client.Collection("users").Doc(id).Update(op.ctx, updates)
+	op.end(err)
+	if err != nil {
+		slog.WarnContext(ctx, "UpdateUserFields: ユーザーの更新に失敗", "user_id", id, "error", err)
+		return firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	return nil
+}
+
+// GetCurrentMonster はユーザーのcurrentMonsterを取得します
+// currentMonsterが存在しない場合はnilを返します
+func (r *UserRepository) GetCurrentMonster(ctx context.Context, id string) (*models.CurrentMonster, error) {
+	op := startFirestoreOperation(ctx, "query", "currentMonster")
+	docs, err := r.Client.Collection("users").Doc(id).Collection("currentMonster").Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var cm models.CurrentMonster
+	if err := docs[0].DataTo(&cm); err != nil {
+		return nil, fmt.Errorf("currentMonsterデータのマッピングに失敗しました: %w", err)
+	}
+	// モンスターが入れ替わった後のドキュメントにはmonsterIdフィールドがないため、SaveContributionと同様にドキュメントIDを使う
+	if cm.MonsterId == "" {
+		cm.MonsterId = docs[0].Ref.ID
+	}
+	return &cm, nil
+}
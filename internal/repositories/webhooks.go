@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// webhookと、webhookごとの配送の記録を保存するサブコレクション
+const (
+	webhooksCollection          = "webhooks"
+	webhookDeliveriesCollection = "deliveries"
+)
+
+func (r *UserRepository) webhooks(id string) *firestore.CollectionRef {
+	return r.Client.Collection("users").Doc(id).Collection(webhooksCollection)
+}
+
+// CreateWebhook はwebhookを登録し、採番したIDを設定して返します
+// 既にmax件登録されている場合はapperrors.ErrWebhookLimitExceededを返します
+func (r *UserRepository) CreateWebhook(ctx context.Context, id string, webhook models.Webhook, max int) (*models.Webhook, error) {
+	userRef := r.Client.Collection("users").Doc(id)
+	ref := r.webhooks(id).NewDoc()
+
+	op := startFirestoreOperation(ctx, "transaction", webhooksCollection)
+	err := r.Client.RunTransaction(op.ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// 削除済みのユーザーの配下にwebhookだけが残らないよう、ユーザーの存在を確認する
+		if _, err := tx.Get(userRef); err != nil {
+			return err
+		}
+		existing, err := tx.Documents(r.webhooks(id)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(existing) >= max {
+			return apperrors.ErrWebhookLimitExceeded
+		}
+		return tx.Create(ref, webhook)
+	})
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, apperrors.ErrUserNotFound)
+	}
+	webhook.WebhookId = ref.ID
+	return &webhook, nil
+}
+
+// ListWebhooks はユーザーのwebhookを登録した順に返します
+func (r *UserRepository) ListWebhooks(ctx context.Context, id string) ([]models.Webhook, error) {
+	op := startFirestoreOperation(ctx, "query", webhooksCollection)
+	docs, err := r.webhooks(id).OrderBy("createdAt", firestore.Asc).Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	webhooks := make([]models.Webhook, 0, len(docs))
+	for _, doc := range docs {
+		var webhook models.Webhook
+		if err := doc.DataTo(&webhook); err != nil {
+			return nil, err
+		}
+		webhook.WebhookId = doc.Ref.ID
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook はwebhookを取得します
+// 登録されていない場合はapperrors.ErrWebhookNotFoundを返します
+func (r *UserRepository) GetWebhook(ctx context.Context, id, webhookId string) (*models.Webhook, error) {
+	op := startFirestoreOperation(ctx, "get", webhooksCollection)
+	doc, err := r.webhooks(id).Doc(webhookId).Get(op.ctx)
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, apperrors.ErrWebhookNotFound)
+	}
+
+	var webhook models.Webhook
+	if err := doc.DataTo(&webhook); err != nil {
+		return nil, err
+	}
+	webhook.WebhookId = doc.Ref.ID
+	return &webhook, nil
+}
+
+// DeleteWebhook はwebhookと配送の記録を削除します
+// 登録されていない場合はapperrors.ErrWebhookNotFoundを返します
+func (r *UserRepository) DeleteWebhook(ctx context.Context, id, webhookId string) error {
+	ref := r.webhooks(id).Doc(webhookId)
+
+	op := startFirestoreOperation(ctx, "delete", webhooksCollection)
+	_, err := ref.Delete(op.ctx, firestore.Exists)
+	op.end(err)
+	if err != nil {
+		return firestoreError(err, apperrors.ErrWebhookNotFound)
+	}
+
+	// サブコレクションは親を削除しても残るため、配送の記録も削除する
+	op = startFirestoreOperation(ctx, "query", webhookDeliveriesCollection)
+	docs, err := ref.Collection(webhookDeliveriesCollection).Select().Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return fmt.Errorf("配送の記録の取得に失敗しました: %w", firestoreError(err, nil))
+	}
+	for _, doc := range docs {
+		op := startFirestoreOperation(ctx, "delete", webhookDeliveriesCollection)
+		_, err := doc.Ref.Delete(op.ctx)
+		op.end(err)
+		if err != nil {
+			return fmt.Errorf("配送の記録の削除に失敗しました: %w", firestoreError(err, nil))
+		}
+	}
+	return nil
+}
+
+// GetWebhookDelivery はイベントdeliveryIdのwebhookへの配送の記録を取得します
+// まだ配送を試みていない場合はnilを返します
+func (r *UserRepository) GetWebhookDelivery(ctx context.Context, id, webhookId, deliveryId string) (*models.WebhookDelivery, error) {
+	op := startFirestoreOperation(ctx, "get", webhookDeliveriesCollection)
+	doc, err := r.webhooks(id).Doc(webhookId).Collection(webhookDeliveriesCollection).Doc(deliveryId).Get(op.ctx)
+	if status.Code(err) == codes.NotFound {
+		op.end(nil)
+		return nil, nil
+	}
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := doc.DataTo(&delivery); err != nil {
+		return nil, err
+	}
+	delivery.DeliveryId = doc.Ref.ID
+	return &delivery, nil
+}
+
+// SaveWebhookDelivery は配送の記録を保存します（同じイベントの記録は上書きします）
+func (r *UserRepository) SaveWebhookDelivery(ctx context.Context, id, webhookId string, delivery models.WebhookDelivery) error {
+	op := startFirestoreOperation(ctx, "set", webhookDeliveriesCollection)
+	_, err := r.webhooks(id).Doc(webhookId).Collection(webhookDeliveriesCollection).Doc(delivery.DeliveryId).Set(op.ctx, delivery)
+	op.end(err)
+	return firestoreError(err, nil)
+}
+
+// ListWebhookDeliveries はwebhookへの配送の記録を最後に試みた日時の新しい順に最大limit件返します
+func (r *UserRepository) ListWebhookDeliveries(ctx context.Context, id, webhookId string, limit int) ([]models.WebhookDelivery, error) {
+	op := startFirestoreOperation(ctx, "query", webhookDeliveriesCollection)
+	docs, err := r.webhooks(id).Doc(webhookId).Collection(webhookDeliveriesCollection).
+		OrderBy("lastAttemptAt", firestore.Desc).
+		Limit(limit).
+		Documents(op.ctx).GetAll()
+	op.end(err)
+	if err != nil {
+		return nil, firestoreError(err, nil)
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(docs))
+	for _, doc := range docs {
+		var delivery models.WebhookDelivery
+		if err := doc.DataTo(&delivery); err != nil {
+			return nil, err
+		}
+		delivery.DeliveryId = doc.Ref.ID
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/logging"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/middleware"
+	"geekcamp-vol10-backend/internal/tracing"
+	"geekcamp-vol10-backend/internal/validation"
+	"geekcamp-vol10-backend/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routes はミドルウェアとすべてのエンドポイントを登録したルーターを作成します
+func (s *Server) routes(ctx context.Context, fb *database.Firebase) (*gin.Engine, error) {
+	// アクセスログはlogging.Middlewareで出力するため、gin標準のLoggerは使わない
+	r := gin.New()
+	// X-Forwarded-Forなどを信頼するプロキシ。空の場合は接続元のアドレスをクライアントのIPとして使う
+	// （レート制限のキーになるため、任意のクライアントがヘッダーでIPを偽装できないようにする）
+	if err := r.SetTrustedProxies(s.cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES の設定に失敗しました: %w", err)
+	}
+	r.Use(gin.Recovery())
+	// リクエストごとのスパンを作成（ログにtrace_idを出すため、loggingより先に適用する）
+	r.Use(tracing.Middleware())
+	// リクエストIDの付与とアクセスログ
+	r.Use(logging.Middleware())
+	// ルートごとのリクエスト数・処理時間をPrometheusメトリクスに記録
+	r.Use(metrics.Middleware())
+	// ハンドラーで登録されたエラーをproblem+json形式のレスポンスに変換する
+	r.Use(apperrors.Middleware())
+
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "OK",
+		})
+	})
+
+	// Kubernetesなどのプローブ用エンドポイント
+	r.GET("/livez", s.healthHandler.Livez)
+	r.GET("/readyz", s.healthHandler.Readyz)
+	r.GET("/version", s.healthHandler.Version)
+
+	// Prometheusのスクレイプ用エンドポイント
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// ルートごとにレート制限をかける（各ルートで認証の後に適用し、認証済みのリクエストはUIDで数える）
+	limits := s.cfg.RateLimits
+	defaultLimit := s.rateLimit("default", limits.Default)
+
+	// 共有用の画像（ヒートマップ・カード）はREADMEの<img>タグやSNSから読み込むため、認証なしで公開する
+	// IDトークンを送れないため、接続元のIPアドレスで数える
+	public := r.Group("/")
+	public.Use(validation.IDParam("id"))
+	public.GET("/users/:id/heatmap.svg", defaultLimit, s.heatmapHandler.GetHeatmap)
+	public.GET("/users/:id/card.png", defaultLimit, s.cardHandler.GetCard)
+
+	// authが必要なエンドポイントにmiddleware/auth.goを適用
+	authRequired := r.Group("/")
+	// 認証が無効な場合はnilのまま（管理者APIを登録しない判定にも使う）
+	var authMiddleware gin.HandlerFunc
+	if s.cfg.AuthEnabled {
+		// Firestoreと同じFirebaseアプリを使ってIDトークンを検証する
+		var err error
+		authMiddleware, err = middleware.AuthMiddleware(ctx, fb.App)
+		if err != nil {
+			return nil, err
+		}
+		authRequired.Use(authMiddleware)
+	} else {
+		slog.Warn("認証が無効です（AUTH_ENABLED=false）。すべてのユーザーのデータ・エクスポート・webhook・端末のエンドポイントに誰でもアクセスできます。ローカルでの確認以外では使わないでください。GitHubのトークンには GITHUB_TOKEN を使います")
+	}
+	authRequired.Use(middleware.GitHubTokenFallback(s.cfg.GitHubToken))
+	authRequired.Use(validation.IDParam("id"))
+
+	// :idのユーザーのデータは認証したユーザー本人（または管理者）だけが読み書きできる
+	// 認証が無効な環境ではUIDがないため確認しない
+	self := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
+	if authMiddleware != nil {
+		self = middleware.RequireSelf("id")
+	}
+
+	authRequired.POST("/users", s.rateLimit("create_user", limits.CreateUser), s.userHandler.CreateUser)
+	authRequired.GET("/users/:id", defaultLimit, self, s.userHandler.GetUser)
+	authRequired.PATCH("/users/:id", defaultLimit, self, s.userHandler.PatchUser)
+	authRequired.GET("/users/:id/sealed-monsters", defaultLimit, self, s.userHandler.ListSealedMonsters)
+	authRequired.POST("/users/:id/devices", defaultLimit, self, s.notificationHandler.RegisterDevice)
+	authRequired.DELETE("/users/:id/devices/:deviceId", defaultLimit, self, validation.IDParam("deviceId"), s.notificationHandler.UnregisterDevice)
+	authRequired.GET("/users/:id/notification-settings", defaultLimit, self, s.notificationHandler.GetSettings)
+	authRequired.PATCH("/users/:id/notification-settings", defaultLimit, self, s.notificationHandler.PatchSettings)
+	authRequired.POST("/users/:id/webhooks", defaultLimit, self, s.webhookHandler.CreateWebhook)
+	authRequired.GET("/users/:id/webhooks", defaultLimit, self, s.webhookHandler.ListWebhooks)
+	authRequired.DELETE("/users/:id/webhooks/:webhookId", defaultLimit, self, validation.IDParam("webhookId"), s.webhookHandler.DeleteWebhook)
+	authRequired.GET("/users/:id/webhooks/:webhookId/deliveries", defaultLimit, self, validation.IDParam("webhookId"), s.webhookHandler.ListDeliveries)
+	authRequired.POST("/users/:id/webhooks/:webhookId/test", defaultLimit, self, validation.IDParam("webhookId"), s.webhookHandler.SendTest)
+	authRequired.GET("/users/:id/export", s.rateLimit("export", limits.Export), self, s.exportHandler.ExportUser)
+	authRequired.GET("/contributions/:id", s.rateLimit("contributions", limits.Contributions), self, s.contributionHandler.GetContribution)
+	authRequired.GET("/users/:id/contributions/history", defaultLimit, self, s.contributionHandler.GetContributionHistory)
+
+	// 管理者API。認証に加えて管理者のカスタムクレーム（admin: true）を確認する
+	// 管理者を確認できない認証が無効な環境では、誰でも呼び出せてしまうため登録しない
+	if authMiddleware == nil {
+		slog.Warn("認証が無効なため、管理者API（/admin）は登録しません")
+		return r, nil
+	}
+	admin := r.Group("/admin")
+	admin.Use(authMiddleware, middleware.RequireAdmin())
+	admin.Use(validation.IDParam("id"))
+	admin.GET("/users", defaultLimit, s.adminHandler.ListUsers)
+	admin.GET("/users/:id", defaultLimit, s.adminHandler.GetUser)
+	admin.POST("/users/:id/reset-progress", defaultLimit, s.adminHandler.ResetProgress)
+	admin.POST("/users/:id/contributions", defaultLimit, s.adminHandler.AdjustContributions)
+	admin.PUT("/users/:id/current-monster", defaultLimit, s.adminHandler.ReassignMonster)
+	// 強制同期は対象のユーザー自身のGitHubトークンで行う（管理者のトークンは使わない）
+	targetToken, err := middleware.TargetUserGitHubToken(ctx, fb.App, "id")
+	if err != nil {
+		return nil, err
+	}
+	// 管理者自身の同期（GET /contributions/:id）とは別のバケットで数える
+	admin.POST("/users/:id/sync", s.rateLimit("admin_sync", limits.Contributions), targetToken, s.adminHandler.ForceSync)
+
+	return r, nil
+}
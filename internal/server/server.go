@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"geekcamp-vol10-backend/internal/cache"
+	"geekcamp-vol10-backend/internal/card"
+	"geekcamp-vol10-backend/internal/config"
+	"geekcamp-vol10-backend/internal/handlers"
+	"geekcamp-vol10-backend/internal/health"
+	"geekcamp-vol10-backend/internal/notify"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/ratelimit"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/services"
+	"geekcamp-vol10-backend/internal/validation"
+	"geekcamp-vol10-backend/internal/webhook"
+	"geekcamp-vol10-backend/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server はリポジトリ・サービス・ハンドラーを組み立てたアプリケーション本体です
+// 依存はすべてNewで明示的に渡すため、1つのプロセス（テストバイナリなど）で複数のServerを独立して動かせます
+type Server struct {
+	cfg    *config.Config
+	router *gin.Engine
+
+	// リポジトリ
+	users         *repositories.UserRepository
+	contributions *repositories.ContributionRepository
+	history       *repositories.ContributionHistoryRepository
+	monsters      *repositories.MonsterRepository
+
+	// レート制限のバケットの保存先
+	rateLimitStore ratelimit.Store
+	// GitHubのコントリビューションのキャッシュの保存先
+	contributionsCache cache.Store
+	// 描画したカード画像と、カードに使う画像のキャッシュ
+	cardCache cache.Store
+	// プッシュ通知の送信先
+	notifier notify.Notifier
+	// 同期で保存したドメインイベントの配送
+	events *outbox.Dispatcher
+
+	// サービス
+	github              *services.GitHubClient
+	contributionService *services.ContributionService
+	historyService      *services.ContributionHistoryService
+	heatmapService      *services.HeatmapService
+	cardService         *services.CardService
+	notificationService *services.NotificationService
+	webhookService      *services.WebhookService
+	userService         *services.UserService
+	exportService       *services.ExportService
+	adminService        *services.AdminService
+
+	// ハンドラー
+	userHandler         *handlers.UserHandler
+	exportHandler       *handlers.ExportHandler
+	contributionHandler *handlers.ContributionHandler
+	heatmapHandler      *handlers.HeatmapHandler
+	cardHandler         *handlers.CardHandler
+	notificationHandler *handlers.NotificationHandler
+	webhookHandler      *handlers.WebhookHandler
+	adminHandler        *handlers.AdminHandler
+	healthHandler       *handlers.HealthHandler
+}
+
+// New は依存を組み立ててルートを登録したServerを作成します
+// Firebaseの所有権は呼び出し側にあり、Closeも呼び出し側で行います
+func New(ctx context.Context, cfg *config.Config, fb *database.Firebase) (*Server, error) {
+	if fb == nil || fb.Firestore == nil {
+		return nil, errors.New("Firestore クライアントが初期化されていません")
+	}
+
+	// リクエストのバリデーションルールを登録（ginのバリデータに登録するため、何度呼び出しても同じ結果になる）
+	if err := validation.Register(); err != nil {
+		return nil, fmt.Errorf("バリデーションルールの登録に失敗しました: %w", err)
+	}
+
+	s := &Server{cfg: cfg}
+
+	s.users = repositories.NewUserRepository(fb.Firestore)
+	s.contributions = repositories.NewContributionRepository(fb.Firestore)
+	s.history = repositories.NewContributionHistoryRepository(fb.Firestore)
+	s.monsters = repositories.NewMonsterRepository(fb.Firestore)
+
+	switch cfg.RateLimitStore {
+	case "firestore":
+		s.rateLimitStore = repositories.NewRateLimitRepository(fb.Firestore)
+	default:
+		s.rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	switch cfg.ContributionsCacheStore {
+	case "firestore":
+		s.contributionsCache = repositories.NewResponseCacheRepository(fb.Firestore)
+	default:
+		s.contributionsCache = cache.NewMemoryStore(cfg.ContributionsCacheSize)
+	}
+
+	s.github = services.NewGitHubClient(services.GitHubGraphQLURL, &http.Client{}, services.GitHubClientConfig{
+		Timeout:         cfg.GitHubTimeout,
+		MaxRetries:      cfg.GitHubMaxRetries,
+		BreakerFailures: cfg.GitHubBreakerFailures,
+		BreakerCooldown: cfg.GitHubBreakerCooldown,
+	})
+	s.contributionService = services.NewContributionService(s.github, s.contributionsCache, cfg.ContributionsCacheTTL)
+	defaultLocation, err := time.LoadLocation(cfg.DefaultTimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("DEFAULT_TIME_ZONE を読み込めません: %w", err)
+	}
+	s.historyService = services.NewContributionHistoryService(s.users, s.history, defaultLocation)
+	s.heatmapService = services.NewHeatmapService(s.users, s.historyService, s.monsters)
+	renderer, err := card.NewRenderer(cfg.CardFontFile)
+	if err != nil {
+		return nil, fmt.Errorf("カードのフォントの読み込みに失敗しました: %w", err)
+	}
+	s.cardCache = cache.NewMemoryStore(cfg.CardCacheSize)
+	s.cardService = services.NewCardService(s.users, s.monsters, renderer, &http.Client{}, s.cardCache, cfg.CardAvatarHosts)
+	switch cfg.Notifier {
+	case "fcm":
+		s.notifier, err = notify.NewFCM(ctx, fb.App)
+		if err != nil {
+			return nil, err
+		}
+	case "fake":
+		s.notifier = notify.NewFake()
+	default:
+		s.notifier = notify.Nop{}
+	}
+	s.notificationService = services.NewNotificationService(s.users, s.monsters, s.notifier)
+	s.events = outbox.NewDispatcher(repositories.NewOutboxRepository(fb.Firestore), outbox.DispatcherConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+		RetryBase:    cfg.OutboxRetryBase,
+		RetryMax:     cfg.OutboxRetryMax,
+		Lease:        cfg.OutboxLease,
+	})
+	s.webhookService = services.NewWebhookService(s.users, s.monsters, webhook.NewSender(cfg.WebhookTimeout, cfg.WebhookAllowPrivate))
+	s.events.Register("notifications", s.notificationService.HandleEvent, outbox.TypeMonsterSealed, outbox.TypeMonsterAssigned)
+	s.events.Register("webhooks", s.webhookService.HandleEvent)
+	s.userService = services.NewUserService(s.users, s.github)
+	s.exportService = services.NewExportService(s.users)
+	s.adminService = services.NewAdminService(s.users, s.contributions, s.userService, s.events)
+
+	s.userHandler = handlers.NewUserHandler(s.userService)
+	s.exportHandler = handlers.NewExportHandler(s.users, s.exportService)
+	s.contributionHandler = handlers.NewContributionHandler(s.users, s.contributions, s.contributionService, s.historyService, s.events)
+	s.heatmapHandler = handlers.NewHeatmapHandler(s.heatmapService)
+	s.cardHandler = handlers.NewCardHandler(s.cardService)
+	s.notificationHandler = handlers.NewNotificationHandler(s.notificationService)
+	s.webhookHandler = handlers.NewWebhookHandler(s.webhookService)
+	s.adminHandler = handlers.NewAdminHandler(s.adminService, s.contributionHandler)
+	s.healthHandler = handlers.NewHealthHandler(s.readinessChecker())
+
+	router, err := s.routes(ctx, fb)
+	if err != nil {
+		return nil, err
+	}
+	s.router = router
+	return s, nil
+}
+
+// Handler はルートを登録済みのhttp.Handlerを返します
+// httptest.NewServerなどにそのまま渡せます
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Run はcfg.Addr()で待ち受け、ctxがキャンセルされるまでリクエストを処理します
+// ctxがキャンセルされると新しい接続の受け付けを止め、cfg.ShutdownTimeoutまで処理中のリクエストと同期の完了を待ちます
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.cfg.Addr(),
+		Handler:           s.router,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("サーバーを起動します", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	// 連続記録が途切れそうなユーザーへの通知を定期的に確認する
+	// 複数のサーバーで動いていても、同じ期限についての通知はリポジトリで1回に絞る
+	remindersCtx, stopReminders := context.WithCancel(ctx)
+	defer stopReminders()
+	remindersDone := make(chan struct{})
+	if s.cfg.Notifier != "none" {
+		go func() {
+			defer close(remindersDone)
+			s.notificationService.RunStreakReminders(remindersCtx, s.cfg.StreakReminderInterval, s.cfg.StreakReminderBefore)
+		}()
+	} else {
+		close(remindersDone)
+	}
+
+	// 同期で保存したドメインイベントを配送する
+	// 複数のサーバーで動いていても、同じイベントを同時に配送しないようリポジトリで取り出す
+	eventsCtx, stopEvents := context.WithCancel(ctx)
+	defer stopEvents()
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		s.events.Run(eventsCtx)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			return fmt.Errorf("サーバーの起動に失敗しました: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	slog.Info("シャットダウンを開始します", "timeout", s.cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// 新しい接続の受け付けを止め、処理中のリクエストの完了を待つ
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("処理中のリクエストの完了を待てませんでした", "error", err)
+	}
+	// クライアントが切断済みの同期もFirestoreへの書き込みを続けているため、完了を待ってからFirestoreを閉じる
+	if err := s.contributionHandler.WaitForSyncs(shutdownCtx); err != nil {
+		slog.Warn("処理中のコントリビューション同期の完了を待てませんでした", "error", err)
+	}
+	select {
+	case <-remindersDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("連続記録の通知の確認の完了を待てませんでした", "error", shutdownCtx.Err())
+	}
+	// 配送中に止めたイベントはleaseが過ぎた後に他のサーバーか次の起動時に配送される
+	select {
+	case <-eventsDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("イベントの配送の完了を待てませんでした", "error", shutdownCtx.Err())
+	}
+	slog.Info("サーバーを停止しました")
+	return nil
+}
+
+// readinessChecker は /readyz で確認する依存先を登録したCheckerを作成します
+// GitHubはユーザーごとのトークンで呼び出すため、GITHUB_TOKENが設定されている場合のみ確認します
+func (s *Server) readinessChecker() *health.Checker {
+	checker := health.NewChecker(s.cfg.ReadinessTimeout, s.cfg.ReadinessCacheTTL)
+	checker.Register("firestore", s.contributions.Ping)
+	if s.cfg.GitHubToken != "" {
+		checker.Register("github", func(ctx context.Context) error {
+			_, err := s.github.GetRateLimit(ctx, s.cfg.GitHubToken)
+			return err
+		})
+	} else {
+		slog.Info("GITHUB_TOKEN が未設定のため、/readyz ではGitHubを確認しません")
+	}
+	return checker
+}
+
+// rateLimit はpolicyのレート制限をかけるミドルウェアを返します
+func (s *Server) rateLimit(policy string, limit ratelimit.Limit) gin.HandlerFunc {
+	return ratelimit.Middleware(s.rateLimitStore, policy, limit)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"geekcamp-vol10-backend/internal/config"
+	"geekcamp-vol10-backend/pkg/database"
+)
+
+// エミュレータモードの設定を読み込む
+// FirestoreとAuthのクライアントは最初の呼び出しまで接続しないため、エミュレータが起動していなくても作成できる
+func loadTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("GCLOUD_PROJECT", "server-test")
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "127.0.0.1:1")
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", "127.0.0.1:1")
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("NOTIFIER", "none")
+	t.Setenv("RATE_LIMIT_STORE", "memory")
+	t.Setenv("RATE_LIMIT_CREATE_USER", "1/1h")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	return cfg
+}
+
+func newTestServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	t.Helper()
+	ctx := context.Background()
+	fb, err := database.NewFirebase(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewFirebase() error = %v", err)
+	}
+	t.Cleanup(func() { fb.Close() })
+	s, err := New(ctx, cfg, fb)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestServersHaveIndependentConfig(t *testing.T) {
+	cfgA := loadTestConfig(t)
+	cfgB := *cfgA
+	cfgB.AuthEnabled = true
+
+	a := newTestServer(t, cfgA)
+	b := newTestServer(t, &cfgB)
+
+	for _, ts := range []*httptest.Server{a, b} {
+		for _, path := range []string{"/health", "/livez", "/version"} {
+			if status := do(t, http.MethodGet, ts.URL+path, ""); status != http.StatusOK {
+				t.Errorf("GET %s = %d, want 200", path, status)
+			}
+		}
+	}
+
+	// 認証が無効なAでは管理者APIを登録せず、Bでは認証を求める
+	if status := do(t, http.MethodGet, a.URL+"/admin/users", ""); status != http.StatusNotFound {
+		t.Errorf("A: GET /admin/users = %d, want 404", status)
+	}
+	if status := do(t, http.MethodGet, b.URL+"/admin/users", ""); status != http.StatusUnauthorized {
+		t.Errorf("B: GET /admin/users = %d, want 401", status)
+	}
+	if status := do(t, http.MethodPost, a.URL+"/users", "{}"); status != http.StatusBadRequest {
+		t.Errorf("A: POST /users = %d, want 400", status)
+	}
+	if status := do(t, http.MethodPost, b.URL+"/users", "{}"); status != http.StatusUnauthorized {
+		t.Errorf("B: POST /users = %d, want 401", status)
+	}
+}
+
+func TestShareImagesArePublic(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.AuthEnabled = true
+	ts := newTestServer(t, cfg)
+
+	// IDトークンなしでも認証で弾かれず、IDの検証まで進む（Firestoreには接続しない）
+	for _, path := range []string{"/users/__bad__/heatmap.svg", "/users/__bad__/card.png"} {
+		if status := do(t, http.MethodGet, ts.URL+path, ""); status != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, status)
+		}
+	}
+	for _, path := range []string{"/users/__bad__", "/users/__bad__/export", "/users/__bad__/contributions/history"} {
+		if status := do(t, http.MethodGet, ts.URL+path, ""); status != http.StatusUnauthorized {
+			t.Errorf("GET %s = %d, want 401", path, status)
+		}
+	}
+}
+
+func TestServersHaveIndependentRateLimits(t *testing.T) {
+	cfg := loadTestConfig(t)
+	a := newTestServer(t, cfg)
+	b := newTestServer(t, cfg)
+
+	// ボディの検証で失敗するため、Firestoreには接続しない
+	if status := do(t, http.MethodPost, a.URL+"/users", "{}"); status != http.StatusBadRequest {
+		t.Errorf("A: POST /users = %d, want 400", status)
+	}
+	if status := do(t, http.MethodPost, a.URL+"/users", "{}"); status != http.StatusTooManyRequests {
+		t.Errorf("A: 2回目のPOST /users = %d, want 429", status)
+	}
+	// Aで上限に達しても、同じ設定のBのバケットには影響しない
+	if status := do(t, http.MethodPost, b.URL+"/users", "{}"); status != http.StatusBadRequest {
+		t.Errorf("B: POST /users = %d, want 400", status)
+	}
+}
+
+func TestNewRequiresFirestore(t *testing.T) {
+	cfg := loadTestConfig(t)
+	if _, err := New(context.Background(), cfg, &database.Firebase{}); err == nil {
+		t.Error("Firestoreクライアントなしで New() error = nil")
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/repositories"
+)
+
+// 管理者の操作の種類（ログとメトリクスのactionラベル）
+const (
+	AdminActionResetProgress       = "reset_progress"
+	AdminActionAdjustContributions = "adjust_contributions"
+	AdminActionReassignMonster     = "reassign_monster"
+	AdminActionForceSync           = "force_sync"
+)
+
+// AdminAudit は管理者の操作をログに残すための情報です
+type AdminAudit struct {
+	// 操作した管理者のFirebaseのUID（認証が無効な環境では空）
+	AdminUID string
+	// 操作の理由（問い合わせの番号など）
+	Reason string
+}
+
+// UserState は管理者が調査に使うユーザーの状態です
+type UserState struct {
+	// GET /users/:id と同じユーザー情報
+	User *models.User
+	// usersドキュメントのすべてのフィールド（Userに含めない内部のフィールドも含む）
+	Profile map[string]interface{}
+	Devices []models.DeviceToken
+	// シークレットは呼び出し側でレスポンスに含めないでください
+	Webhooks []models.Webhook
+}
+
+// AdminUsers はAdminServiceが調査に使うユーザーの読み込みです
+// 通常は*repositories.UserRepositoryを使います（テストではメモリ上の実装に置き換えます）
+type AdminUsers interface {
+	ListUsers(ctx context.Context, q repositories.UsersQuery) (*repositories.UsersPage, error)
+	GetUserDocument(ctx context.Context, id string) (map[string]interface{}, error)
+	ListDeviceTokens(ctx context.Context, id string) ([]models.DeviceToken, error)
+	ListWebhooks(ctx context.Context, id string) ([]models.Webhook, error)
+}
+
+// AdminContributions はAdminServiceが使う進捗の修正です
+// 通常は*repositories.ContributionRepositoryを使います
+type AdminContributions interface {
+	ResetProgress(ctx context.Context, id string, deleteSealed bool) (models.CurrentMonster, error)
+	AdjustContributions(ctx context.Context, id string, delta int) (models.CurrentMonster, *models.SealedMonster, error)
+	ReassignCurrentMonster(ctx context.Context, id, monsterId string, progress int) (models.CurrentMonster, error)
+}
+
+var (
+	_ AdminUsers         = (*repositories.UserRepository)(nil)
+	_ AdminContributions = (*repositories.ContributionRepository)(nil)
+)
+
+// AdminService は管理者APIでのユーザーの調査と、進捗の修正を扱います
+type AdminService struct {
+	users         AdminUsers
+	contributions AdminContributions
+	userService   *UserService
+	// 修正で保存したドメインイベントの配送
+	events *outbox.Dispatcher
+}
+
+// NewAdminService はAdminServiceを作成します
+func NewAdminService(users AdminUsers, contributions AdminContributions, userService *UserService, events *outbox.Dispatcher) *AdminService {
+	return &AdminService{
+		users:         users,
+		contributions: contributions,
+		userService:   userService,
+		events:        events,
+	}
+}
+
+// ListUsers はユーザーを1ページ分返します
+func (s *AdminService) ListUsers(ctx context.Context, query repositories.UsersQuery) (*repositories.UsersPage, error) {
+	return s.users.ListUsers(ctx, query)
+}
+
+// GetUserState はユーザーの状態をまとめて取得します
+func (s *AdminService) GetUserState(ctx context.Context, id string) (*UserState, error) {
+	user, err := s.userService.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.users.GetUserDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.users.ListDeviceTokens(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := s.users.ListWebhooks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &UserState{
+		User:     user,
+		Profile:  normalizeExportValue(profile).(map[string]interface{}),
+		Devices:  devices,
+		Webhooks: webhooks,
+	}, nil
+}
+
+// ResetProgress は育成中のモンスターを最初のモンスターに戻し、連続封印記録を0にします
+// deleteSealedがtrueの場合は封印済みモンスターと最高記録も削除します
+func (s *AdminService) ResetProgress(ctx context.Context, id string, deleteSealed bool, audit AdminAudit) (models.CurrentMonster, error) {
+	current, err := s.contributions.ResetProgress(ctx, id, deleteSealed)
+	if err != nil {
+		return models.CurrentMonster{}, err
+	}
+	// 最初のモンスターの割り当てを通知する
+	s.events.Kick()
+	s.logAction(ctx, AdminActionResetProgress, id, audit, "delete_sealed_monsters", deleteSealed)
+	return current, nil
+}
+
+// AdjustContributions は育成中のモンスターの進捗をdelta増減させます（負の値で取り消し）
+// 必要数に達した場合は封印したモンスターも返します
+func (s *AdminService) AdjustContributions(ctx context.Context, id string, delta int, audit AdminAudit) (models.CurrentMonster, *models.SealedMonster, error) {
+	current, sealed, err := s.contributions.AdjustContributions(ctx, id, delta)
+	if err != nil {
+		return models.CurrentMonster{}, nil, err
+	}
+	// 封印や付与の通知は同期と同じくイベントから行う
+	s.events.Kick()
+	s.logAction(ctx, AdminActionAdjustContributions, id, audit, "delta", delta, "sealed", sealed != nil, "monster_id", current.MonsterId)
+	return current, sealed, nil
+}
+
+// ReassignMonster は育成中のモンスターをmonsterIdに置き換えます
+func (s *AdminService) ReassignMonster(ctx context.Context, id, monsterId string, progress int, audit AdminAudit) (models.CurrentMonster, error) {
+	current, err := s.contributions.ReassignCurrentMonster(ctx, id, monsterId, progress)
+	if err != nil {
+		return models.CurrentMonster{}, err
+	}
+	s.events.Kick()
+	s.logAction(ctx, AdminActionReassignMonster, id, audit, "monster_id", monsterId, "progress_contributions", progress)
+	return current, nil
+}
+
+// LogForceSync は強制同期を記録します
+// 同期そのものはContributionHandlerでユーザーの同期と同じように行います
+func (s *AdminService) LogForceSync(ctx context.Context, id string, audit AdminAudit) {
+	s.logAction(ctx, AdminActionForceSync, id, audit)
+}
+
+// 誰がいつ何のためにユーザーのデータを変更したかを追えるよう、操作はすべてログに残す
+func (s *AdminService) logAction(ctx context.Context, action, id string, audit AdminAudit, args ...any) {
+	metrics.IncAdminAction(action)
+	args = append([]any{"action", action, "user_id", id, "admin_uid", audit.AdminUID, "reason", audit.Reason}, args...)
+	slog.InfoContext(ctx, "管理者がユーザーのデータを変更しました", args...)
+}
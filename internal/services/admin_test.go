@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/repositories"
+
+	"cloud.google.com/go/firestore"
+)
+
+// メモリ上のAdminUsers・AdminContributions・UserServiceUsers
+type memoryAdmin struct {
+	users    map[string]*models.User
+	devices  map[string][]models.DeviceToken
+	webhooks map[string][]models.Webhook
+	// 最後に呼び出された修正
+	calls []string
+	// 修正が返すエラー
+	err error
+
+	lastQuery repositories.UsersQuery
+}
+
+func newMemoryAdmin() *memoryAdmin {
+	return &memoryAdmin{
+		users:    map[string]*models.User{"user-1": {FirebaseId: "user-1", GithubUserName: "plmwa"}},
+		devices:  map[string][]models.DeviceToken{"user-1": {{DeviceId: "device-1", Token: "fcm-token", Platform: "ios"}}},
+		webhooks: map[string][]models.Webhook{"user-1": {{WebhookId: "webhook-1", URL: "https://example.com/hook", Secret: "whsec"}}},
+	}
+}
+
+func (m *memoryAdmin) ListUsers(_ context.Context, q repositories.UsersQuery) (*repositories.UsersPage, error) {
+	m.lastQuery = q
+	return &repositories.UsersPage{Users: []models.User{*m.users["user-1"]}}, nil
+}
+
+func (m *memoryAdmin) GetUserDocument(_ context.Context, id string) (map[string]interface{}, error) {
+	if _, ok := m.users[id]; !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+	return map[string]interface{}{"githubUserName": "plmwa", "lastContributionReflectedAt": time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)}, nil
+}
+
+func (m *memoryAdmin) ListDeviceTokens(_ context.Context, id string) ([]models.DeviceToken, error) {
+	return m.devices[id], nil
+}
+
+func (m *memoryAdmin) ListWebhooks(_ context.Context, id string) ([]models.Webhook, error) {
+	return m.webhooks[id], nil
+}
+
+func (m *memoryAdmin) ResetProgress(_ context.Context, id string, deleteSealed bool) (models.CurrentMonster, error) {
+	if deleteSealed {
+		m.calls = append(m.calls, "reset+sealed:"+id)
+	} else {
+		m.calls = append(m.calls, "reset:"+id)
+	}
+	if m.err != nil {
+		return models.CurrentMonster{}, m.err
+	}
+	return models.CurrentMonster{MonsterId: "001", RequiredContributions: 30}, nil
+}
+
+func (m *memoryAdmin) AdjustContributions(_ context.Context, id string, delta int) (models.CurrentMonster, *models.SealedMonster, error) {
+	m.calls = append(m.calls, "adjust:"+id)
+	if m.err != nil {
+		return models.CurrentMonster{}, nil, m.err
+	}
+	if delta >= 30 {
+		return models.CurrentMonster{MonsterId: "002", ProgressContributions: delta - 30, RequiredContributions: 50},
+			&models.SealedMonster{MonsterId: "001", MonsterName: "スライム"}, nil
+	}
+	return models.CurrentMonster{MonsterId: "001", ProgressContributions: delta, RequiredContributions: 30}, nil, nil
+}
+
+func (m *memoryAdmin) ReassignCurrentMonster(_ context.Context, id, monsterId string, progress int) (models.CurrentMonster, error) {
+	m.calls = append(m.calls, "reassign:"+id)
+	if m.err != nil {
+		return models.CurrentMonster{}, m.err
+	}
+	return models.CurrentMonster{MonsterId: monsterId, ProgressContributions: progress, RequiredContributions: 50}, nil
+}
+
+func (m *memoryAdmin) CreateUserIfAbsent(context.Context, models.User) (*models.User, error) {
+	return nil, nil
+}
+
+func (m *memoryAdmin) GetUserByID(_ context.Context, id string) (*models.User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (m *memoryAdmin) GetCurrentMonster(context.Context, string) (*models.CurrentMonster, error) {
+	return nil, nil
+}
+
+func (m *memoryAdmin) CountSealedMonsters(context.Context, string) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryAdmin) ListSealedMonsters(context.Context, string, repositories.SealedMonstersQuery) (*repositories.SealedMonstersPage, error) {
+	return &repositories.SealedMonstersPage{}, nil
+}
+
+func (m *memoryAdmin) UpdateUserFields(context.Context, string, []firestore.Update) error {
+	return nil
+}
+
+func newTestAdminService(m *memoryAdmin) *AdminService {
+	// Runしないため、Kickしても配送はしない
+	events := outbox.NewDispatcher(nil, outbox.DispatcherConfig{})
+	return NewAdminService(m, m, NewUserService(m, nil), events)
+}
+
+// captureLogs はテストの間だけslogの出力をJSONで記録する
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// auditLogs は管理者の操作のログを返す
+func auditLogs(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var logs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("ログを読み込めません: %v: %s", err, line)
+		}
+		if entry["action"] != nil {
+			logs = append(logs, entry)
+		}
+	}
+	return logs
+}
+
+func TestAdminServiceLogsActions(t *testing.T) {
+	buf := captureLogs(t)
+	m := newMemoryAdmin()
+	s := newTestAdminService(m)
+	audit := AdminAudit{AdminUID: "admin-1", Reason: "問い合わせ #42"}
+	ctx := context.Background()
+
+	current, err := s.ResetProgress(ctx, "user-1", true, audit)
+	if err != nil || current.MonsterId != "001" {
+		t.Fatalf("ResetProgress() = %+v, %v", current, err)
+	}
+	_, sealed, err := s.AdjustContributions(ctx, "user-1", 35, audit)
+	if err != nil || sealed == nil {
+		t.Fatalf("AdjustContributions() sealed = %+v, %v", sealed, err)
+	}
+	if current, err := s.ReassignMonster(ctx, "user-1", "003", 4, audit); err != nil || current.MonsterId != "003" {
+		t.Fatalf("ReassignMonster() = %+v, %v", current, err)
+	}
+	s.LogForceSync(ctx, "user-1", audit)
+
+	want := []string{"reset+sealed:user-1", "adjust:user-1", "reassign:user-1"}
+	if strings.Join(m.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("呼び出し = %v, want %v", m.calls, want)
+	}
+
+	logs := auditLogs(t, buf)
+	actions := []string{AdminActionResetProgress, AdminActionAdjustContributions, AdminActionReassignMonster, AdminActionForceSync}
+	if len(logs) != len(actions) {
+		t.Fatalf("ログ = %v", logs)
+	}
+	for i, entry := range logs {
+		if entry["action"] != actions[i] || entry["user_id"] != "user-1" || entry["admin_uid"] != "admin-1" || entry["reason"] != "問い合わせ #42" {
+			t.Errorf("%d件目のログ = %v", i, entry)
+		}
+	}
+	if logs[0]["delete_sealed_monsters"] != true {
+		t.Errorf("リセットのログ = %v", logs[0])
+	}
+	if logs[1]["delta"] != float64(35) || logs[1]["sealed"] != true || logs[1]["monster_id"] != "002" {
+		t.Errorf("付与のログ = %v", logs[1])
+	}
+	if logs[2]["monster_id"] != "003" || logs[2]["progress_contributions"] != float64(4) {
+		t.Errorf("置き換えのログ = %v", logs[2])
+	}
+}
+
+func TestAdminServiceDoesNotLogFailedActions(t *testing.T) {
+	buf := captureLogs(t)
+	m := newMemoryAdmin()
+	m.err = apperrors.ErrUserNotFound
+	s := newTestAdminService(m)
+	ctx := context.Background()
+
+	if _, err := s.ResetProgress(ctx, "user-2", false, AdminAudit{}); !errors.Is(err, apperrors.ErrUserNotFound) {
+		t.Errorf("ResetProgress() error = %v", err)
+	}
+	if _, _, err := s.AdjustContributions(ctx, "user-2", 1, AdminAudit{}); !errors.Is(err, apperrors.ErrUserNotFound) {
+		t.Errorf("AdjustContributions() error = %v", err)
+	}
+	if _, err := s.ReassignMonster(ctx, "user-2", "003", 0, AdminAudit{}); !errors.Is(err, apperrors.ErrUserNotFound) {
+		t.Errorf("ReassignMonster() error = %v", err)
+	}
+	// 変更していない操作は記録しない
+	if logs := auditLogs(t, buf); len(logs) != 0 {
+		t.Errorf("失敗した操作のログ = %v", logs)
+	}
+}
+
+func TestAdminServiceGetUserState(t *testing.T) {
+	s := newTestAdminService(newMemoryAdmin())
+
+	state, err := s.GetUserState(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("GetUserState() error = %v", err)
+	}
+	if state.User.GithubUserName != "plmwa" || len(state.Devices) != 1 || len(state.Webhooks) != 1 {
+		t.Errorf("state = %+v", state)
+	}
+	// プロフィールの日時はエクスポートと同じRFC3339の文字列にする
+	if got := state.Profile["lastContributionReflectedAt"]; got != "2025-08-01T00:00:00Z" {
+		t.Errorf("lastContributionReflectedAt = %v (%T)", got, got)
+	}
+
+	if _, err := s.GetUserState(context.Background(), "user-2"); !errors.Is(err, apperrors.ErrUserNotFound) {
+		t.Errorf("存在しないユーザー: GetUserState() error = %v", err)
+	}
+}
+
+func TestAdminServiceListUsers(t *testing.T) {
+	m := newMemoryAdmin()
+	s := newTestAdminService(m)
+	query := repositories.UsersQuery{Limit: 20, GithubUserNamePrefix: "plm", Cursor: "abc"}
+
+	page, err := s.ListUsers(context.Background(), query)
+	if err != nil || len(page.Users) != 1 {
+		t.Fatalf("ListUsers() = %+v, %v", page, err)
+	}
+	if m.lastQuery != query {
+		t.Errorf("条件 = %+v, want %+v", m.lastQuery, query)
+	}
+}
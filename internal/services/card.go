@@ -0,0 +1,356 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"geekcamp-vol10-backend/internal/cache"
+	"geekcamp-vol10-backend/internal/card"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/validation"
+
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/errgroup"
+)
+
+// カードの描画方法を変えた場合は上げて、古いキャッシュとETagを使わないようにします
+const cardVersion = "1"
+
+// メトリクスのcacheラベル
+const (
+	cardCacheName      = "user_card"
+	cardImageCacheName = "card_image"
+)
+
+const (
+	// 描画したカードのキャッシュの期間
+	// キーにユーザーの状態を含めるため、状態が変わると別のキーになり古いカードは使われません
+	cardCacheTTL = 24 * time.Hour
+	// 取得したアバター・モンスターのアイコンのキャッシュの期間
+	cardImageCacheTTL = time.Hour
+	// 画像1枚の取得の制限時間とサイズの上限
+	cardImageTimeout  = 5 * time.Second
+	cardImageMaxBytes = 5 << 20
+	// 同時に取得する画像の数
+	cardImageConcurrency = 8
+)
+
+// CardService はユーザーの共有用カード画像を描画します
+type CardService struct {
+	users       *repositories.UserRepository
+	monsters    *repositories.MonsterRepository
+	renderer    *card.Renderer
+	client      *http.Client
+	cache       cache.Store
+	avatarHosts []string
+}
+
+// NewCardService はCardServiceを作成します
+// storeには描画したカードと取得した画像をキャッシュします。avatarHostsはアバターを取得してよいホストです
+func NewCardService(users *repositories.UserRepository, monsters *repositories.MonsterRepository, renderer *card.Renderer, client *http.Client, store cache.Store, avatarHosts []string) *CardService {
+	return &CardService{
+		users:       users,
+		monsters:    monsters,
+		renderer:    renderer,
+		client:      client,
+		cache:       store,
+		avatarHosts: avatarHosts,
+	}
+}
+
+// CardOptions はカードのレイアウト・サイズ・テーマです
+type CardOptions struct {
+	Layout string
+	Size   string
+	// 空の場合はユーザーの表示設定のテーマ（未設定の場合はlight）
+	Theme string
+}
+
+// CardState はカードの内容を決めるユーザーの状態です
+// 封印済みモンスターは件数と最後に封印したものだけを読み、コレクションはカードを描画するときに読みます
+type CardState struct {
+	id          string
+	user        *models.User
+	current     *models.CurrentMonster
+	sealedCount int64
+	latestSeal  *models.SealedMonster
+}
+
+// State はユーザーの現在の状態を取得します
+func (s *CardService) State(ctx context.Context, id string) (*CardState, error) {
+	state := &CardState{id: id}
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		state.user, err = s.users.GetUserByID(gctx, id)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		state.current, err = s.users.GetCurrentMonster(gctx, id)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		state.sealedCount, err = s.users.CountSealedMonsters(gctx, id)
+		return err
+	})
+	g.Go(func() error {
+		page, err := s.users.ListSealedMonsters(gctx, id, repositories.SealedMonstersQuery{Limit: 1, Descending: true})
+		if err != nil {
+			return err
+		}
+		if len(page.SealedMonsters) > 0 {
+			state.latestSeal = &page.SealedMonsters[0]
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ETag はカードの内容を表すETagを返します
+// ユーザーの状態（プロフィール・育成中のモンスターの進捗・封印の記録）かoptsが変わると変わります
+func (st *CardState) ETag(opts CardOptions) string {
+	return `"` + st.fingerprint(opts) + `"`
+}
+
+func (st *CardState) fingerprint(opts CardOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n", cardVersion, st.id, opts.Layout, opts.Size, st.theme(opts))
+	fmt.Fprintf(h, "%s\n%s\n%s\n%d\n%d\n%d\n", st.user.GithubUserName, st.user.PhotoURL, st.displayName(), st.user.ContinuousSealRecord, st.user.MaxSealRecord, st.sealedCount)
+	if st.current != nil {
+		fmt.Fprintf(h, "%s\n%d\n%d\n", st.current.MonsterId, st.current.ProgressContributions, st.current.RequiredContributions)
+	}
+	if st.latestSeal != nil {
+		fmt.Fprintf(h, "%s\n%d\n", st.latestSeal.MonsterId, st.latestSeal.SealedAt.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (st *CardState) theme(opts CardOptions) string {
+	if opts.Theme != "" {
+		return opts.Theme
+	}
+	if st.user.DisplaySettings != nil && st.user.DisplaySettings.Theme != "" {
+		return st.user.DisplaySettings.Theme
+	}
+	return card.ThemeLight
+}
+
+func (st *CardState) displayName() string {
+	if st.user.DisplaySettings != nil && st.user.DisplaySettings.DisplayName != "" {
+		return st.user.DisplaySettings.DisplayName
+	}
+	return st.user.GithubUserName
+}
+
+// Render はカードをPNGで返します
+// 同じ状態・設定のカードはキャッシュから返し、描画し直しません
+func (s *CardService) Render(ctx context.Context, st *CardState, opts CardOptions) ([]byte, error) {
+	key := "card:" + st.fingerprint(opts)
+	if cached, ok, err := s.cache.Get(ctx, key); err != nil {
+		metrics.IncCacheRequest(cardCacheName, metrics.CacheError)
+		slog.WarnContext(ctx, "カードのキャッシュの取得に失敗しました", "error", err)
+	} else if ok {
+		metrics.IncCacheRequest(cardCacheName, metrics.CacheHit)
+		return cached, nil
+	} else {
+		metrics.IncCacheRequest(cardCacheName, metrics.CacheMiss)
+	}
+
+	data, err := s.cardData(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+	theme, ok := card.Themes[st.theme(opts)]
+	if !ok {
+		theme = card.Themes[card.ThemeLight]
+	}
+
+	var buf bytes.Buffer
+	if err := s.renderer.Render(&buf, *data, card.Options{Layout: opts.Layout, Size: opts.Size, Theme: theme}); err != nil {
+		return nil, fmt.Errorf("カードの描画に失敗しました: %w", err)
+	}
+	if err := s.cache.Set(ctx, key, buf.Bytes(), cardCacheTTL); err != nil {
+		slog.WarnContext(ctx, "カードのキャッシュの保存に失敗しました", "error", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cardData はコレクションとモンスターのマスターデータを読み、アバターとアイコンを取得してカードの内容を組み立てます
+// 画像を取得できない場合はその画像なしで描画します
+func (s *CardService) cardData(ctx context.Context, st *CardState) (*card.Data, error) {
+	sealed, err := listAllSealedMonsters(ctx, s.users, st.id, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	var collection []string
+	for _, seal := range sealed {
+		if counts[seal.MonsterId] == 0 {
+			collection = append(collection, seal.MonsterId)
+		}
+		counts[seal.MonsterId]++
+	}
+	sort.Strings(collection)
+	ids := collection[:len(collection):len(collection)]
+	if st.current != nil {
+		ids = append(ids, st.current.MonsterId)
+	}
+	monsters, err := s.monsters.GetMonsters(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同じモンスターのアイコンは1回だけ取得する
+	icons := make(map[string]image.Image)
+	for _, id := range ids {
+		icons[monsters[id].ImageURL] = nil
+	}
+	var avatar image.Image
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(cardImageConcurrency)
+	if s.isAllowedAvatar(st.user.PhotoURL) {
+		g.Go(func() error {
+			avatar = s.fetchImage(gctx, st.user.PhotoURL)
+			return nil
+		})
+	}
+	urls := make([]string, 0, len(icons))
+	for u := range icons {
+		if validation.IsHTTPSURL(u) {
+			urls = append(urls, u)
+		}
+	}
+	results := make([]image.Image, len(urls))
+	for i, u := range urls {
+		g.Go(func() error {
+			results[i] = s.fetchImage(gctx, u)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	for i, u := range urls {
+		icons[u] = results[i]
+	}
+
+	monster := func(id string) card.Monster {
+		name := ""
+		if m, ok := monsters[id]; ok {
+			name = m.Name
+		}
+		return card.Monster{MonsterId: id, Name: name, Icon: icons[monsters[id].ImageURL]}
+	}
+	data := &card.Data{
+		DisplayName:          st.displayName(),
+		GithubUserName:       st.user.GithubUserName,
+		Avatar:               avatar,
+		SealedMonsterCount:   st.sealedCount,
+		ContinuousSealRecord: st.user.ContinuousSealRecord,
+		MaxSealRecord:        st.user.MaxSealRecord,
+	}
+	if st.current != nil {
+		data.Current = &card.CurrentMonster{
+			Monster:               monster(st.current.MonsterId),
+			ProgressContributions: st.current.ProgressContributions,
+			RequiredContributions: st.current.RequiredContributions,
+		}
+	}
+	for _, id := range collection {
+		data.Collection = append(data.Collection, card.CollectionItem{Monster: monster(id), Count: counts[id]})
+	}
+	return data, nil
+}
+
+// photoURLはユーザーが変更できるため、許可したホストのhttpsのURLのみ取得する
+func (s *CardService) isAllowedAvatar(photoURL string) bool {
+	if !validation.IsHTTPSURL(photoURL) {
+		return false
+	}
+	u, err := url.Parse(photoURL)
+	if err != nil {
+		return false
+	}
+	for _, host := range s.avatarHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchImage は画像を取得してデコードします。取得できない場合はnilを返します
+func (s *CardService) fetchImage(ctx context.Context, imageURL string) image.Image {
+	key := "card:image:" + imageURL
+	raw, ok, err := s.cache.Get(ctx, key)
+	switch {
+	case err != nil:
+		metrics.IncCacheRequest(cardImageCacheName, metrics.CacheError)
+	case ok:
+		metrics.IncCacheRequest(cardImageCacheName, metrics.CacheHit)
+	default:
+		metrics.IncCacheRequest(cardImageCacheName, metrics.CacheMiss)
+	}
+	if !ok {
+		if raw, err = s.download(ctx, imageURL); err != nil {
+			slog.WarnContext(ctx, "カードの画像の取得に失敗しました", "url", imageURL, "error", err)
+			return nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		slog.WarnContext(ctx, "カードの画像をデコードできません", "url", imageURL, "error", err)
+		return nil
+	}
+	if !ok {
+		if err := s.cache.Set(ctx, key, raw, cardImageCacheTTL); err != nil {
+			slog.WarnContext(ctx, "カードの画像のキャッシュの保存に失敗しました", "error", err)
+		}
+	}
+	return img
+}
+
+func (s *CardService) download(ctx context.Context, imageURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, cardImageTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ステータスコード %d", res.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, cardImageMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > cardImageMaxBytes {
+		return nil, fmt.Errorf("画像が大きすぎます（%dバイトまで）", cardImageMaxBytes)
+	}
+	return raw, nil
+}
@@ -1,17 +1,29 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"log"
-	"net/http"
+	"log/slog"
 	"fmt"
+	"time"
+	"geekcamp-vol10-backend/internal/metrics"
 	"geekcamp-vol10-backend/internal/models"
-)
+	"geekcamp-vol10-backend/internal/tracing"
 
-const githubAPIURL = "https://api.github.com/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
-func GetContributions(githubUserName, githubToken string) (models.GithubResponse, error) {
+// GetContributions はユーザーのリポジトリごとのコミット数と、Issue・PR・レビューのコントリビューションを取得します
+// モンスターの進捗にはコミット数のみを使い、それ以外はコントリビューションの履歴に使います
+func (g *GitHubClient) GetContributions(ctx context.Context, githubUserName, githubToken string) (_ models.GithubResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "services.GetContributions", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGitHubRequest("contributions", time.Since(start), err)
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
 	// 本番環境では、headerからアクセストークンを取得,bodyからユーザー名を取得
 	/*
@@ -22,6 +34,12 @@ func GetContributions(githubUserName, githubToken string) (models.GithubResponse
 	// GitHub APIに送信するGraphQLクエリを定義 (詳細な日時情報のみ)
 	query := `
         query($githubUserName: String!) {
+            rateLimit {
+                cost
+                limit
+                remaining
+                resetAt
+            }
             user(login: $githubUserName) {
                 contributionsCollection {
                     commitContributionsByRepository {
@@ -41,6 +59,45 @@ func GetContributions(githubUserName, githubToken string) (models.GithubResponse
                             }
                         }
                     }
+                    issueContributionsByRepository {
+                        repository {
+                            name
+                            owner {
+                                login
+                            }
+                        }
+                        contributions(first: 100) {
+                            nodes {
+                                occurredAt
+                            }
+                        }
+                    }
+                    pullRequestContributionsByRepository {
+                        repository {
+                            name
+                            owner {
+                                login
+                            }
+                        }
+                        contributions(first: 100) {
+                            nodes {
+                                occurredAt
+                            }
+                        }
+                    }
+                    pullRequestReviewContributionsByRepository {
+                        repository {
+                            name
+                            owner {
+                                login
+                            }
+                        }
+                        contributions(first: 100) {
+                            nodes {
+                                occurredAt
+                            }
+                        }
+                    }
                 }
             }
         }`
@@ -52,49 +109,36 @@ func GetContributions(githubUserName, githubToken string) (models.GithubResponse
 			"githubUserName": githubUserName,
 		},
 	}
-	requestBody, err := json.Marshal(graphQLReq)
-	if err != nil {
-		log.Printf("ERROR: Failed to marshal GraphQL request: %v", err)
-		return models.GithubResponse{}, fmt.Errorf("Failed to build request body: %w", err)
-	}
-
-	// GitHub APIへのHTTPリクエストを作成
-	request, err := http.NewRequest("POST", githubAPIURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		log.Printf("ERROR: Failed to create HTTP request: %v", err)
-		return models.GithubResponse{}, fmt.Errorf("Failed to create HTTP request: %w", err)
-	}
-	request.Header.Set("Authorization", "bearer "+githubToken)
-	request.Header.Set("Content-Type", "application/json") // Content-Typeの指定は必須
-
-	// 4. リクエストを実行
-	client := &http.Client{}
-	response, err := client.Do(request)
+	body, err := g.postGraphQL(ctx, graphQLReq, githubToken)
 	if err != nil {
-		log.Printf("ERROR: Failed to send request to GitHub: %v", err)
-		return models.GithubResponse{}, fmt.Errorf("Failed to send request to GitHub: %w", err)
-	}
-	defer response.Body.Close()
-
-	// GitHubからのレスポンスステータスコードをチェック
-	if response.StatusCode != http.StatusOK {
-		log.Printf("ERROR: GitHub API returned status code: %d", response.StatusCode)
-		return models.GithubResponse{}, fmt.Errorf("GitHub API returned status code: %d", response.StatusCode)
+		return models.GithubResponse{}, err
 	}
 
 	// 5. レスポンスをデコード
 	var githubResponse models.GithubResponse
-	if err := json.NewDecoder(response.Body).Decode(&githubResponse); err != nil {
-		log.Printf("ERROR: Failed to decode GitHub response: %v", err)
+	if err := json.Unmarshal(body, &githubResponse); err != nil {
+		slog.ErrorContext(ctx, "Failed to decode GitHub response", "error", err)
 		return models.GithubResponse{}, fmt.Errorf("Failed to decode GitHub response: %w", err)
 	}
 
+	// クエリのコストと残りのポイントをスパンに記録
+	rateLimit := githubResponse.Data.RateLimit
+	span.SetAttributes(
+		attribute.Int("github.graphql.cost", rateLimit.Cost),
+		attribute.Int("github.graphql.rate_limit.limit", rateLimit.Limit),
+		attribute.Int("github.graphql.rate_limit.remaining", rateLimit.Remaining),
+		attribute.String("github.graphql.rate_limit.reset_at", rateLimit.ResetAt),
+		attribute.Int("github.repositories", len(githubResponse.Data.User.ContributionsCollection.CommitContributionsByRepository)),
+	)
+
 	// GraphQLレベルのエラーもチェック
 	if len(githubResponse.Errors) > 0 {
-		log.Printf("ERROR: GraphQL error: %s", githubResponse.Errors[0].Message)
-		return models.GithubResponse{}, fmt.Errorf("GraphQL error: %s", githubResponse.Errors[0].Message)
+		slog.WarnContext(ctx, "GraphQL error", "type", githubResponse.Errors[0].Type, "message", githubResponse.Errors[0].Message)
+		return models.GithubResponse{}, graphQLError(githubResponse.Errors[0])
 	}
 
+	githubResponse.FetchedAt = start
+
 	// Responseを出力 json
 	return githubResponse, nil
 }
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
+)
+
+// コントリビューションの種類
+const (
+	ContributionTypeCommit            = "commit"
+	ContributionTypeIssue             = "issue"
+	ContributionTypePullRequest       = "pull_request"
+	ContributionTypePullRequestReview = "pull_request_review"
+)
+
+// ContributionTypes はレスポンスに含める種類の一覧です
+var ContributionTypes = []string{
+	ContributionTypeCommit,
+	ContributionTypeIssue,
+	ContributionTypePullRequest,
+	ContributionTypePullRequestReview,
+}
+
+// 履歴の集計の単位
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// MaxContributionHistoryDays は1回のリクエストで取得できる期間の上限（日数）です
+const MaxContributionHistoryDays = 731
+
+// ErrInvalidHistoryRange は取得する期間が正しくない（fromがtoより後、または長すぎる）場合のエラーです
+var ErrInvalidHistoryRange = fmt.Errorf("期間が正しくありません: %w", apperrors.ErrValidation)
+
+const dateLayout = "2006-01-02"
+
+// ContributionHistoryService はユーザーの日ごとのコントリビューションを保存し、期間ごとに集計します
+type ContributionHistoryService struct {
+	users           *repositories.UserRepository
+	history         *repositories.ContributionHistoryRepository
+	defaultLocation *time.Location
+}
+
+// NewContributionHistoryService はContributionHistoryServiceを作成します
+// defaultLocationはタイムゾーンを設定していないユーザーに使います
+func NewContributionHistoryService(users *repositories.UserRepository, history *repositories.ContributionHistoryRepository, defaultLocation *time.Location) *ContributionHistoryService {
+	return &ContributionHistoryService{
+		users:           users,
+		history:         history,
+		defaultLocation: defaultLocation,
+	}
+}
+
+// Record はGitHubから取得したコントリビューションを日ごとに集計して保存します
+// 初回は取得できた期間（直近1年）をすべて保存し、それ以降は前回の保存日の前日から今日までを置き換えます。
+// 前日から置き換えるのは、同期の直後に前日分のコントリビューションがGitHubに反映される場合があるためです。
+// 前回の保存からタイムゾーンが変わった場合は、取得できた期間をすべて新しいタイムゾーンの日付で集計し直します（それより前の日は前のタイムゾーンのまま残ります）
+func (s *ContributionHistoryService) Record(ctx context.Context, id string, githubData models.GithubResponse) error {
+	user, err := s.users.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	loc := s.Location(user)
+	now := time.Now()
+
+	// GitHubから取得できるのは直近1年のため、それより前の日は置き換えない
+	since := now.In(loc).AddDate(-1, 0, 0).Format(dateLayout)
+	if !user.ContributionHistorySyncedAt.IsZero() && user.ContributionHistoryTimeZone == loc.String() {
+		since = user.ContributionHistorySyncedAt.In(loc).AddDate(0, 0, -1).Format(dateLayout)
+	}
+
+	var days []models.ContributionDay
+	for _, day := range DailyContributions(ctx, githubData, loc) {
+		if day.Date >= since {
+			days = append(days, day)
+		}
+	}
+	if err := s.history.SaveDays(ctx, id, since, days, now, loc.String()); err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "日ごとのコントリビューションを保存しました", "user_id", id, "days", len(days), "since", since, "time_zone", loc.String())
+	return nil
+}
+
+// DailyContributions はGitHubのコントリビューションをlocでの日付ごとに集計し、日付順に返します
+func DailyContributions(ctx context.Context, githubData models.GithubResponse, loc *time.Location) []models.ContributionDay {
+	byDate := make(map[string]*models.ContributionDay)
+	add := func(repository, contributionType, occurredAt string, count int) {
+		t, err := time.Parse(time.RFC3339, occurredAt)
+		if err != nil {
+			slog.WarnContext(ctx, "コントリビューション日時のパースに失敗しました", "occurred_at", occurredAt, "error", err)
+			return
+		}
+		date := t.In(loc).Format(dateLayout)
+		day, ok := byDate[date]
+		if !ok {
+			day = &models.ContributionDay{Date: date, ByType: map[string]int{}, ByRepository: map[string]int{}}
+			byDate[date] = day
+		}
+		day.Total += count
+		day.ByType[contributionType] += count
+		day.ByRepository[repository] += count
+	}
+
+	collection := githubData.Data.User.ContributionsCollection
+	for _, repo := range collection.CommitContributionsByRepository {
+		name := repo.Repository.Owner.Login + "/" + repo.Repository.Name
+		for _, node := range repo.Contributions.Nodes {
+			add(name, ContributionTypeCommit, node.OccurredAt, node.CommitCount)
+		}
+	}
+	for contributionType, repos := range map[string][]models.RepositoryContributions{
+		ContributionTypeIssue:             collection.IssueContributionsByRepository,
+		ContributionTypePullRequest:       collection.PullRequestContributionsByRepository,
+		ContributionTypePullRequestReview: collection.PullRequestReviewContributionsByRepository,
+	} {
+		for _, repo := range repos {
+			name := repo.Repository.Owner.Login + "/" + repo.Repository.Name
+			for _, node := range repo.Contributions.Nodes {
+				add(name, contributionType, node.OccurredAt, 1)
+			}
+		}
+	}
+
+	days := make([]models.ContributionDay, 0, len(byDate))
+	for _, day := range byDate {
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// ContributionHistoryQuery は履歴の取得条件です
+type ContributionHistoryQuery struct {
+	// YYYY-MM-DD（両端を含む）。空の場合はToから集計の単位に応じた期間
+	From string
+	// YYYY-MM-DD。空の場合はユーザーのタイムゾーンでの今日
+	To          string
+	Granularity string
+}
+
+// ContributionHistory は期間ごとに集計したコントリビューションです
+type ContributionHistory struct {
+	TimeZone    string
+	Granularity string
+	From        string
+	To          string
+	Total       int
+	Buckets     []ContributionBucket
+}
+
+// ContributionBucket は集計の単位1つ分（日・週・月）のコントリビューションです
+// 週は月曜日から始まり、Start・EndはFrom・Toの範囲に切り詰めません（範囲外の日は数えません）
+type ContributionBucket struct {
+	Start        string
+	End          string
+	Total        int
+	ByType       map[string]int
+	ByRepository map[string]int
+}
+
+// History はユーザーのコントリビューションの履歴を、ユーザーのタイムゾーンで期間ごとに集計します
+// コントリビューションがない期間も0として含めるため、そのままグラフに使えます
+func (s *ContributionHistoryService) History(ctx context.Context, id string, q ContributionHistoryQuery) (*ContributionHistory, error) {
+	user, err := s.users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.HistoryForUser(ctx, id, user, q)
+}
+
+// HistoryForUser は取得済みのユーザーについてHistoryと同じ集計をします
+func (s *ContributionHistoryService) HistoryForUser(ctx context.Context, id string, user *models.User, q ContributionHistoryQuery) (*ContributionHistory, error) {
+	loc := s.Location(user)
+	var err error
+
+	to := time.Now().In(loc)
+	if q.To != "" {
+		if to, err = time.ParseInLocation(dateLayout, q.To, loc); err != nil {
+			return nil, err
+		}
+	}
+	to = truncateDay(to)
+	from := defaultHistoryFrom(to, q.Granularity)
+	if q.From != "" {
+		if from, err = time.ParseInLocation(dateLayout, q.From, loc); err != nil {
+			return nil, err
+		}
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("fromがtoより後の日付です: %w", ErrInvalidHistoryRange)
+	}
+	if days := daysBetween(from, to) + 1; days > MaxContributionHistoryDays {
+		return nil, fmt.Errorf("%d日間は長すぎます: %w", days, ErrInvalidHistoryRange)
+	}
+
+	days, err := s.history.ListDays(ctx, id, from.Format(dateLayout), to.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	history := &ContributionHistory{
+		TimeZone:    loc.String(),
+		Granularity: q.Granularity,
+		From:        from.Format(dateLayout),
+		To:          to.Format(dateLayout),
+	}
+	index := make(map[string]int)
+	for start := bucketStart(from, q.Granularity); !start.After(to); start = nextBucket(start, q.Granularity) {
+		index[start.Format(dateLayout)] = len(history.Buckets)
+		history.Buckets = append(history.Buckets, ContributionBucket{
+			Start:        start.Format(dateLayout),
+			End:          nextBucket(start, q.Granularity).AddDate(0, 0, -1).Format(dateLayout),
+			ByType:       emptyTypeCounts(),
+			ByRepository: map[string]int{},
+		})
+	}
+	for _, day := range days {
+		date, err := time.ParseInLocation(dateLayout, day.Date, loc)
+		if err != nil {
+			continue
+		}
+		i, ok := index[bucketStart(date, q.Granularity).Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		bucket := &history.Buckets[i]
+		bucket.Total += day.Total
+		for t, n := range day.ByType {
+			bucket.ByType[t] += n
+		}
+		for repo, n := range day.ByRepository {
+			bucket.ByRepository[repo] += n
+		}
+		history.Total += day.Total
+	}
+	return history, nil
+}
+
+// Location はユーザーのコントリビューションの日付を数えるタイムゾーンを返します
+// タイムゾーンを設定していない、または読み込めない場合はデフォルトを使います
+func (s *ContributionHistoryService) Location(user *models.User) *time.Location {
+	if user.TimeZone != "" {
+		if loc, err := time.LoadLocation(user.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return s.defaultLocation
+}
+
+// 期間を指定しない場合は、日ごとは30日、週ごとは12週、月ごとは12か月
+func defaultHistoryFrom(to time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return bucketStart(to, GranularityWeek).AddDate(0, 0, -7*11)
+	case GranularityMonth:
+		return bucketStart(to, GranularityMonth).AddDate(0, -11, 0)
+	default:
+		return to.AddDate(0, 0, -29)
+	}
+}
+
+func bucketStart(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		// 月曜日始まり
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t
+	}
+}
+
+func nextBucket(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// 夏時間の切り替えがあっても日数を数えられるよう、日付だけをUTCで比べる
+func daysBetween(from, to time.Time) int {
+	f := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	t := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(t.Sub(f).Hours() / 24)
+}
+
+func emptyTypeCounts() map[string]int {
+	counts := make(map[string]int, len(ContributionTypes))
+	for _, t := range ContributionTypes {
+		counts[t] = 0
+	}
+	return counts
+}
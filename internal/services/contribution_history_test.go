@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/models"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("タイムゾーン %s を読み込めません: %v", name, err)
+	}
+	return loc
+}
+
+// 日本時間では日付が変わる時刻（UTCの15時以降）を含むコントリビューション
+const testContributionsJSON = `{"data":{"user":{"contributionsCollection":{
+	"commitContributionsByRepository":[
+		{"repository":{"name":"api","owner":{"login":"plmwa"}},"contributions":{"nodes":[
+			{"commitCount":3,"occurredAt":"2025-08-01T07:00:00Z"},
+			{"commitCount":2,"occurredAt":"2025-08-01T15:30:00Z"},
+			{"commitCount":1,"occurredAt":"not-a-date"}
+		]}}
+	],
+	"issueContributionsByRepository":[
+		{"repository":{"name":"web","owner":{"login":"geekcamp"}},"contributions":{"nodes":[
+			{"occurredAt":"2025-08-01T23:00:00Z"}
+		]}}
+	],
+	"pullRequestContributionsByRepository":[
+		{"repository":{"name":"api","owner":{"login":"plmwa"}},"contributions":{"nodes":[
+			{"occurredAt":"2025-08-02T01:00:00Z"}
+		]}}
+	],
+	"pullRequestReviewContributionsByRepository":[]
+}}}}`
+
+func TestDailyContributions(t *testing.T) {
+	var githubData models.GithubResponse
+	if err := json.Unmarshal([]byte(testContributionsJSON), &githubData); err != nil {
+		t.Fatal(err)
+	}
+
+	type day struct {
+		date   string
+		total  int
+		byType map[string]int
+	}
+	tests := []struct {
+		zone string
+		want []day
+	}{
+		{
+			zone: "UTC",
+			want: []day{
+				{"2025-08-01", 6, map[string]int{ContributionTypeCommit: 5, ContributionTypeIssue: 1}},
+				{"2025-08-02", 1, map[string]int{ContributionTypePullRequest: 1}},
+			},
+		},
+		{
+			zone: "Asia/Tokyo",
+			want: []day{
+				{"2025-08-01", 3, map[string]int{ContributionTypeCommit: 3}},
+				{"2025-08-02", 4, map[string]int{ContributionTypeCommit: 2, ContributionTypeIssue: 1, ContributionTypePullRequest: 1}},
+			},
+		},
+		{
+			// 夏時間（UTC-7）
+			zone: "America/Los_Angeles",
+			want: []day{
+				{"2025-08-01", 7, map[string]int{ContributionTypeCommit: 5, ContributionTypeIssue: 1, ContributionTypePullRequest: 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			got := DailyContributions(context.Background(), githubData, mustLoadLocation(t, tt.zone))
+			if len(got) != len(tt.want) {
+				t.Fatalf("DailyContributions() = %+v", got)
+			}
+			for i, want := range tt.want {
+				if got[i].Date != want.date || got[i].Total != want.total {
+					t.Errorf("[%d] = %s %d, want %s %d", i, got[i].Date, got[i].Total, want.date, want.total)
+				}
+				for typ, n := range want.byType {
+					if got[i].ByType[typ] != n {
+						t.Errorf("[%d] ByType[%s] = %d, want %d", i, typ, got[i].ByType[typ], n)
+					}
+				}
+				repos := 0
+				for _, n := range got[i].ByRepository {
+					repos += n
+				}
+				if repos != want.total {
+					t.Errorf("[%d] ByRepositoryの合計 = %d, want %d", i, repos, want.total)
+				}
+			}
+		})
+	}
+}
+
+func TestDailyContributionsByRepository(t *testing.T) {
+	var githubData models.GithubResponse
+	if err := json.Unmarshal([]byte(testContributionsJSON), &githubData); err != nil {
+		t.Fatal(err)
+	}
+	got := DailyContributions(context.Background(), githubData, time.UTC)
+	want := map[string]int{"plmwa/api": 5, "geekcamp/web": 1}
+	for repo, n := range want {
+		if got[0].ByRepository[repo] != n {
+			t.Errorf("ByRepository[%s] = %d, want %d", repo, got[0].ByRepository[repo], n)
+		}
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name        string
+		date        time.Time
+		granularity string
+		want        time.Time
+	}{
+		{"日", time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC), GranularityDay, time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC)},
+		{"週 月曜日", time.Date(2025, 7, 28, 0, 0, 0, 0, time.UTC), GranularityWeek, time.Date(2025, 7, 28, 0, 0, 0, 0, time.UTC)},
+		{"週 日曜日は前の月曜日から", time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC), GranularityWeek, time.Date(2025, 7, 28, 0, 0, 0, 0, time.UTC)},
+		{"週 月をまたぐ", time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), GranularityWeek, time.Date(2025, 7, 28, 0, 0, 0, 0, time.UTC)},
+		{"週 夏時間の開始をまたぐ", time.Date(2025, 3, 12, 0, 0, 0, 0, newYork), GranularityWeek, time.Date(2025, 3, 10, 0, 0, 0, 0, newYork)},
+		{"週 夏時間の終了をまたぐ", time.Date(2025, 11, 5, 0, 0, 0, 0, newYork), GranularityWeek, time.Date(2025, 11, 3, 0, 0, 0, 0, newYork)},
+		{"月", time.Date(2025, 3, 31, 0, 0, 0, 0, newYork), GranularityMonth, time.Date(2025, 3, 1, 0, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		if got := bucketStart(tt.date, tt.granularity); !got.Equal(tt.want) {
+			t.Errorf("%s: bucketStart(%s) = %s, want %s", tt.name, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestNextBucketAcrossDST(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	// 夏時間の開始日は23時間、終了日は25時間だが、次の日の0時になる
+	for _, start := range []time.Time{
+		time.Date(2025, 3, 9, 0, 0, 0, 0, newYork),
+		time.Date(2025, 11, 2, 0, 0, 0, 0, newYork),
+	} {
+		next := nextBucket(start, GranularityDay)
+		if next.Hour() != 0 || next.Day() != start.Day()+1 {
+			t.Errorf("nextBucket(%s) = %s", start, next)
+		}
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"同じ日", time.Date(2025, 8, 1, 0, 0, 0, 0, tokyo), time.Date(2025, 8, 1, 0, 0, 0, 0, tokyo), 0},
+		{"うるう年", time.Date(2024, 2, 28, 0, 0, 0, 0, tokyo), time.Date(2024, 3, 1, 0, 0, 0, 0, tokyo), 2},
+		// 実際の経過時間は47時間
+		{"夏時間の開始をまたぐ", time.Date(2025, 3, 8, 0, 0, 0, 0, newYork), time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), 2},
+		// 実際の経過時間は49時間
+		{"夏時間の終了をまたぐ", time.Date(2025, 11, 1, 0, 0, 0, 0, newYork), time.Date(2025, 11, 3, 0, 0, 0, 0, newYork), 2},
+		{"1年", time.Date(2025, 1, 1, 0, 0, 0, 0, newYork), time.Date(2026, 1, 1, 0, 0, 0, 0, newYork), 365},
+	}
+	for _, tt := range tests {
+		if got := daysBetween(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: daysBetween() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultHistoryFrom(t *testing.T) {
+	// 2025-08-06は水曜日
+	to := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		granularity string
+		want        string
+	}{
+		{GranularityDay, "2025-07-08"},
+		{GranularityWeek, "2025-05-19"},
+		{GranularityMonth, "2024-09-01"},
+	}
+	for _, tt := range tests {
+		if got := defaultHistoryFrom(to, tt.granularity).Format(dateLayout); got != tt.want {
+			t.Errorf("defaultHistoryFrom(%s) = %s, want %s", tt.granularity, got, tt.want)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	s := &ContributionHistoryService{defaultLocation: tokyo}
+	tests := []struct {
+		timeZone string
+		want     string
+	}{
+		{"", "Asia/Tokyo"},
+		{"America/New_York", "America/New_York"},
+		{"Invalid/Zone", "Asia/Tokyo"},
+	}
+	for _, tt := range tests {
+		if got := s.Location(&models.User{TimeZone: tt.timeZone}).String(); got != tt.want {
+			t.Errorf("Location(%q) = %s, want %s", tt.timeZone, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"geekcamp-vol10-backend/internal/cache"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// メトリクスのcacheラベル
+const contributionsCacheName = "github_contributions"
+
+// キャッシュのキーのバージョン
+// GetContributionsのクエリで取得する項目を変えた場合は上げて、古い形式のエントリを使わないようにします
+const contributionsCacheVersion = "v3"
+
+// GetContributionsで取得する期間（contributionsCollectionのfrom/toを指定しないため、GitHubの既定の直近1年）
+// 期間を指定するクエリを追加する場合はキャッシュのキーを分けるため、別の値にしてください
+const contributionsWindow = "last-year"
+
+// ContributionService はGitHubのコントリビューションの取得結果をキャッシュします
+// 直前に同期したユーザーが再度同期しても、TTLの間はGitHubに問い合わせません。
+// キャッシュから返した結果のFetchedAtは取得した時点の日時のため、同期ではその日時までを反映済みにします。
+// キャッシュした後のコントリビューションは次にGitHubから取得したときに反映されます。
+// トークンによって見えるコントリビューション（プライベートリポジトリなど）が異なるため、キャッシュはトークンごとに分けます
+type ContributionService struct {
+	github *GitHubClient
+	cache  cache.Store
+	ttl    time.Duration
+}
+
+// NewContributionService はContributionServiceを作成します
+// storeがnilまたはttlが0以下の場合はキャッシュしません
+func NewContributionService(github *GitHubClient, store cache.Store, ttl time.Duration) *ContributionService {
+	return &ContributionService{
+		github: github,
+		cache:  store,
+		ttl:    ttl,
+	}
+}
+
+// WithFreshContributions はGetContributionsでキャッシュを使わずにGitHubから取得するctxを返します
+// 管理者の強制同期など、直前の取得結果を使いたくない場合に使います
+func WithFreshContributions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshContributionsKey{}, true)
+}
+
+type freshContributionsKey struct{}
+
+func freshContributions(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshContributionsKey{}).(bool)
+	return fresh
+}
+
+// GetContributions はユーザーのリポジトリごとのコミット数を、キャッシュがあればキャッシュから取得します
+// キャッシュに接続できない場合はGitHubから取得します
+func (s *ContributionService) GetContributions(ctx context.Context, githubUserName, githubToken string) (models.GithubResponse, error) {
+	if s.cache == nil || s.ttl <= 0 {
+		return s.github.GetContributions(ctx, githubUserName, githubToken)
+	}
+	span := trace.SpanFromContext(ctx)
+
+	key := contributionsCacheKey(githubUserName, githubToken)
+
+	var (
+		cached []byte
+		ok     bool
+		err    error
+	)
+	// 強制同期ではキャッシュを読まずにGitHubから取得し、取得した結果でキャッシュを上書きする
+	if !freshContributions(ctx) {
+		cached, ok, err = s.cache.Get(ctx, key)
+	}
+	switch {
+	case err != nil:
+		metrics.IncCacheRequest(contributionsCacheName, metrics.CacheError)
+		slog.WarnContext(ctx, "コントリビューションのキャッシュの取得に失敗しました", "error", err)
+	case ok:
+		var githubResponse models.GithubResponse
+		if err := json.Unmarshal(cached, &githubResponse); err == nil && !githubResponse.FetchedAt.IsZero() {
+			metrics.IncCacheRequest(contributionsCacheName, metrics.CacheHit)
+			span.SetAttributes(attribute.Bool("github.cache_hit", true))
+			return githubResponse, nil
+		}
+		// 形式が変わった古いエントリは取得し直して上書きする
+		metrics.IncCacheRequest(contributionsCacheName, metrics.CacheMiss)
+	default:
+		metrics.IncCacheRequest(contributionsCacheName, metrics.CacheMiss)
+	}
+	span.SetAttributes(attribute.Bool("github.cache_hit", false))
+
+	githubResponse, err := s.github.GetContributions(ctx, githubUserName, githubToken)
+	if err != nil {
+		return models.GithubResponse{}, err
+	}
+
+	if encoded, err := json.Marshal(githubResponse); err == nil {
+		if err := s.cache.Set(ctx, key, encoded, s.ttl); err != nil {
+			slog.WarnContext(ctx, "コントリビューションのキャッシュの保存に失敗しました", "error", err)
+		}
+	}
+	return githubResponse, nil
+}
+
+// キャッシュのキー。GitHubのログイン名は大文字小文字を区別しない
+// トークンはキャッシュの保存先に残さないため、ハッシュの先頭（tokenKey）だけをキーに使う
+func contributionsCacheKey(githubUserName, githubToken string) string {
+	return "github:contributions:" + contributionsCacheVersion + ":" + contributionsWindow + ":" + strings.ToLower(githubUserName) + ":" + tokenKey(githubToken)
+}
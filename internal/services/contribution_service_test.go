@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/cache"
+)
+
+func TestContributionsCacheKey(t *testing.T) {
+	key := contributionsCacheKey("Plmwa", "ghp_secret-token")
+
+	// ログイン名の大文字小文字は区別しない
+	if got := contributionsCacheKey("plmwa", "ghp_secret-token"); got != key {
+		t.Errorf("大文字小文字でキーが変わりました: %s, %s", key, got)
+	}
+	// トークンごとに分ける（見えるプライベートリポジトリが異なるため）
+	if got := contributionsCacheKey("plmwa", "ghp_other-token"); got == key {
+		t.Errorf("トークンが違うのにキーが同じです: %s", key)
+	}
+	if got := contributionsCacheKey("other", "ghp_secret-token"); got == key {
+		t.Errorf("ユーザーが違うのにキーが同じです: %s", key)
+	}
+	// トークンそのものはキーに含めない
+	if strings.Contains(key, "secret-token") {
+		t.Errorf("キーにトークンが含まれています: %s", key)
+	}
+	if want := "github:contributions:" + contributionsCacheVersion + ":" + contributionsWindow + ":plmwa:"; !strings.HasPrefix(key, want) || len(key) != len(want)+16 {
+		t.Errorf("key = %s", key)
+	}
+}
+
+func TestContributionServiceCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(testContributionsJSON))
+	}))
+	t.Cleanup(server.Close)
+	store := cache.NewMemoryStore(10)
+	s := NewContributionService(newTestGitHubClient(server.URL, GitHubClientConfig{}), store, time.Minute)
+	ctx := context.Background()
+
+	first, err := s.GetContributions(ctx, "plmwa", "token-a")
+	if err != nil {
+		t.Fatalf("GetContributions() error = %v", err)
+	}
+	// 同じユーザー・トークンはキャッシュから返し、取得した時点の日時を引き継ぐ
+	second, err := s.GetContributions(ctx, "PLMWA", "token-a")
+	if err != nil || calls.Load() != 1 {
+		t.Fatalf("2回目: error = %v, 呼び出し回数 = %d", err, calls.Load())
+	}
+	if !second.FetchedAt.Equal(first.FetchedAt) {
+		t.Errorf("FetchedAt = %s, want %s", second.FetchedAt, first.FetchedAt)
+	}
+
+	// 別のトークンは別のエントリ
+	if _, err := s.GetContributions(ctx, "plmwa", "token-b"); err != nil || calls.Load() != 2 {
+		t.Fatalf("別のトークン: error = %v, 呼び出し回数 = %d", err, calls.Load())
+	}
+
+	// 強制同期はキャッシュを読まずに取得し、結果で上書きする
+	fresh, err := s.GetContributions(WithFreshContributions(ctx), "plmwa", "token-a")
+	if err != nil || calls.Load() != 3 {
+		t.Fatalf("強制同期: error = %v, 呼び出し回数 = %d", err, calls.Load())
+	}
+	cached, err := s.GetContributions(ctx, "plmwa", "token-a")
+	if err != nil || calls.Load() != 3 || !cached.FetchedAt.Equal(fresh.FetchedAt) {
+		t.Errorf("強制同期の後: error = %v, 呼び出し回数 = %d, FetchedAt = %s, want %s", err, calls.Load(), cached.FetchedAt, fresh.FetchedAt)
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", store.Len())
+	}
+}
+
+func TestContributionServiceWithoutCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(testContributionsJSON))
+	}))
+	t.Cleanup(server.Close)
+
+	// TTLが0の場合は毎回GitHubから取得する
+	s := NewContributionService(newTestGitHubClient(server.URL, GitHubClientConfig{}), cache.NewMemoryStore(10), 0)
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetContributions(context.Background(), "plmwa", "token-a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("呼び出し回数 = %d, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"geekcamp-vol10-backend/internal/repositories"
+)
+
+// エクスポート形式
+const (
+	ExportFormatJSON = "json"
+	ExportFormatZip  = "zip"
+)
+
+// 既知のサブコレクションはCSVの列を固定して出力する
+// ここにないサブコレクションは id,field,value の縦持ち形式で出力する
+var exportCSVColumns = map[string][]string{
+	"currentMonster": {"monsterId", "progressContributions", "requiredContributions", "lastContributionReflectedAt", "assignedAt"},
+	"sealedMonsters": {"monsterId", "monsterName", "sealedAt"},
+}
+
+// 本人にも返さない秘密の値のフィールド。エクスポートではサブコレクションごとにこのフィールドを除きます
+// webhookのシークレットは作成時にしか返さず、端末のトークンはFCMへの送信にしか使わないため
+var exportOmittedFields = map[string][]string{
+	"webhooks":     {"secret"},
+	"deviceTokens": {"token"},
+}
+
+// ExportUsers はExportServiceが使うユーザーのドキュメントとサブコレクションの読み込みです
+// 通常は*repositories.UserRepositoryを使います（テストではメモリ上の実装に置き換えます）
+type ExportUsers interface {
+	GetUserDocument(ctx context.Context, id string) (map[string]interface{}, error)
+	ListUserSubcollections(ctx context.Context, id string) ([]string, error)
+	IterateUserSubcollection(ctx context.Context, id, name string, fn func(docID string, data map[string]interface{}) error) error
+}
+
+var _ ExportUsers = (*repositories.UserRepository)(nil)
+
+// ExportService はユーザーの保持データのエクスポートを扱います
+type ExportService struct {
+	users ExportUsers
+}
+
+// NewExportService はExportServiceを作成します
+func NewExportService(users ExportUsers) *ExportService {
+	return &ExportService{
+		users: users,
+	}
+}
+
+// ExportUserJSON はユーザーの保持データをすべてJSONとしてwに書き出します
+// サブコレクションはドキュメント単位でエンコードするため、全件をメモリに載せません
+func (s *ExportService) ExportUserJSON(ctx context.Context, w io.Writer, id string) error {
+	profile, err := s.users.GetUserDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+	collections, err := s.users.ListUserSubcollections(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	if _, err := fmt.Fprintf(w, `{"exportedAt":%q,"userId":%q,"profile":`, time.Now().UTC().Format(time.RFC3339), id); err != nil {
+		return err
+	}
+	if err := enc.Encode(normalizeExportValue(profile)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"collections":{`); err != nil {
+		return err
+	}
+
+	for i, name := range collections {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%q:[", name); err != nil {
+			return err
+		}
+		first := true
+		err := s.users.IterateUserSubcollection(ctx, id, name, func(docID string, data map[string]interface{}) error {
+			omitExportFields(name, data)
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			doc := normalizeExportValue(data).(map[string]interface{})
+			doc["id"] = docID
+			return enc.Encode(doc)
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(w, "}}\n"); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "ExportUserJSON: エクスポートが完了しました", "user_id", id, "collections", len(collections))
+	return nil
+}
+
+// ExportUserZip はユーザーの保持データをCSVファイル群にしてzipとしてwに書き出します
+func (s *ExportService) ExportUserZip(ctx context.Context, w io.Writer, id string) error {
+	profile, err := s.users.GetUserDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+	collections, err := s.users.ListUserSubcollections(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	// profile.csv: ユーザードキュメントのフィールドを field,value で出力
+	f, err := zw.Create("profile.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write([]string{"field", "value"}); err != nil {
+		return err
+	}
+	if err := cw.Write([]string{"id", id}); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(profile))
+	for k := range profile {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := cw.Write([]string{k, formatExportCSVValue(profile[k])}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	// サブコレクションごとに1ファイル
+	for _, name := range collections {
+		f, err := zw.Create(name + ".csv")
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(f)
+
+		columns, known := exportCSVColumns[name]
+		if known {
+			err = cw.Write(append([]string{"id"}, columns...))
+		} else {
+			err = cw.Write([]string{"id", "field", "value"})
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.users.IterateUserSubcollection(ctx, id, name, func(docID string, data map[string]interface{}) error {
+			omitExportFields(name, data)
+			if known {
+				row := []string{docID}
+				for _, col := range columns {
+					row = append(row, formatExportCSVValue(data[col]))
+				}
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			} else {
+				fields := make([]string, 0, len(data))
+				for k := range data {
+					fields = append(fields, k)
+				}
+				sort.Strings(fields)
+				for _, k := range fields {
+					if err := cw.Write([]string{docID, k, formatExportCSVValue(data[k])}); err != nil {
+						return err
+					}
+				}
+			}
+			// ドキュメントごとにflushして、zipストリームへ順次書き出す
+			cw.Flush()
+			return cw.Error()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "ExportUserZip: エクスポートが完了しました", "user_id", id, "collections", len(collections))
+	return nil
+}
+
+// サブコレクションcollectionのドキュメントから秘密の値のフィールドを除く
+func omitExportFields(collection string, data map[string]interface{}) {
+	for _, field := range exportOmittedFields[collection] {
+		delete(data, field)
+	}
+}
+
+// Firestoreの値をJSONにそのまま出せる形に変換する
+func normalizeExportValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, inner := range val {
+			out[k] = normalizeExportValue(inner)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, inner := range val {
+			out[i] = normalizeExportValue(inner)
+		}
+		return out
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case *firestore.DocumentRef:
+		if val == nil {
+			return nil
+		}
+		return val.Path
+	default:
+		return val
+	}
+}
+
+// CSVのセルに入れる文字列に変換する
+func formatExportCSVValue(v interface{}) string {
+	switch val := normalizeExportValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// メモリ上のExportUsers
+type memoryExportUsers struct {
+	profile     map[string]interface{}
+	collections map[string]map[string]map[string]interface{}
+}
+
+func (m *memoryExportUsers) GetUserDocument(context.Context, string) (map[string]interface{}, error) {
+	return m.profile, nil
+}
+
+func (m *memoryExportUsers) ListUserSubcollections(context.Context, string) ([]string, error) {
+	names := make([]string, 0, len(m.collections))
+	for name := range m.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memoryExportUsers) IterateUserSubcollection(_ context.Context, _, name string, fn func(docID string, data map[string]interface{}) error) error {
+	for id, doc := range m.collections[name] {
+		// リポジトリと同じく、呼び出しごとに新しいmapを渡す
+		data := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			data[k] = v
+		}
+		if err := fn(id, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	testWebhookSecret = "whsec_0123456789abcdef"
+	testDeviceToken   = "fcm-token-0123456789"
+)
+
+func newTestExportService() *ExportService {
+	createdAt := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	return NewExportService(&memoryExportUsers{
+		profile: map[string]interface{}{"githubUserName": "plmwa", "createdAt": createdAt},
+		collections: map[string]map[string]map[string]interface{}{
+			"webhooks": {
+				"webhook-1": {"url": "https://discord.com/api/webhooks/1/abc", "format": "discord", "secret": testWebhookSecret, "createdAt": createdAt},
+			},
+			"deviceTokens": {
+				"device-1": {"token": testDeviceToken, "platform": "ios", "updatedAt": createdAt},
+			},
+			"sealedMonsters": {
+				"sealed-1": {"monsterId": "monster-1", "monsterName": "スライム", "sealedAt": createdAt},
+			},
+		},
+	})
+}
+
+func assertNoSecrets(t *testing.T, name string, b []byte) {
+	t.Helper()
+	for _, secret := range []string{testWebhookSecret, testDeviceToken} {
+		if bytes.Contains(b, []byte(secret)) {
+			t.Errorf("%s に %q が含まれています:\n%s", name, secret, b)
+		}
+	}
+}
+
+func TestExportUserJSONOmitsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestExportService().ExportUserJSON(context.Background(), &buf, "user-1"); err != nil {
+		t.Fatalf("ExportUserJSON() error = %v", err)
+	}
+	assertNoSecrets(t, "JSON", buf.Bytes())
+
+	var got struct {
+		UserID      string                              `json:"userId"`
+		Profile     map[string]interface{}              `json:"profile"`
+		Collections map[string][]map[string]interface{} `json:"collections"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("JSONとして読み込めません: %v\n%s", err, buf.Bytes())
+	}
+	if got.UserID != "user-1" || got.Profile["githubUserName"] != "plmwa" || got.Profile["createdAt"] != "2025-08-01T00:00:00Z" {
+		t.Errorf("profile = %+v", got)
+	}
+	// 秘密の値以外のフィールドとドキュメントは残す
+	if hooks := got.Collections["webhooks"]; len(hooks) != 1 || hooks[0]["id"] != "webhook-1" || hooks[0]["format"] != "discord" {
+		t.Errorf("webhooks = %+v", hooks)
+	}
+	if devices := got.Collections["deviceTokens"]; len(devices) != 1 || devices[0]["platform"] != "ios" {
+		t.Errorf("deviceTokens = %+v", devices)
+	}
+	if sealed := got.Collections["sealedMonsters"]; len(sealed) != 1 || sealed[0]["monsterName"] != "スライム" {
+		t.Errorf("sealedMonsters = %+v", sealed)
+	}
+}
+
+func TestExportUserZipOmitsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestExportService().ExportUserZip(context.Background(), &buf, "user-1"); err != nil {
+		t.Fatalf("ExportUserZip() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zipとして読み込めません: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNoSecrets(t, f.Name, b)
+		files[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"profile.csv", "webhooks.csv", "deviceTokens.csv", "sealedMonsters.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s がありません: %v", name, files)
+		}
+	}
+	if !strings.Contains(files["webhooks.csv"], "webhook-1,format,discord") || strings.Contains(files["webhooks.csv"], ",secret,") {
+		t.Errorf("webhooks.csv = %q", files["webhooks.csv"])
+	}
+	if !strings.Contains(files["deviceTokens.csv"], "device-1,platform,ios") || strings.Contains(files["deviceTokens.csv"], ",token,") {
+		t.Errorf("deviceTokens.csv = %q", files["deviceTokens.csv"])
+	}
+	if want := "sealed-1,monster-1,スライム,2025-08-01T00:00:00Z"; !strings.Contains(files["sealedMonsters.csv"], want) {
+		t.Errorf("sealedMonsters.csv = %q, want %q", files["sealedMonsters.csv"], want)
+	}
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+
+	"github.com/sony/gobreaker"
+)
+
+// GitHubのGraphQL APIのエンドポイント
+const GitHubGraphQLURL = "https://api.github.com/graphql"
+
+// ErrGitHubCircuitOpen はGitHub APIの障害が続いているため、呼び出しを止めている場合のエラーです
+var ErrGitHubCircuitOpen = fmt.Errorf("GitHub APIの障害が続いているため呼び出しを停止しています: %w", apperrors.ErrGitHubUnavailable)
+
+// GitHubClientConfig はGitHubClientのタイムアウト・再試行・サーキットブレーカーの設定です
+// 0以下の項目はデフォルト値を使います（MaxRetriesのみ0で再試行しません）
+type GitHubClientConfig struct {
+	// 1回のリクエスト（レスポンスボディの読み込みまで）の制限時間
+	Timeout time.Duration
+	// 5xx・タイムアウト・接続エラーの場合に再試行する回数
+	MaxRetries int
+	// 再試行までの待ち時間の基準と上限（試行ごとに2倍にし、0〜その値の間でランダムに待つ）
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// 連続してこの回数失敗（再試行後）したらサーキットブレーカーを開く
+	BreakerFailures int
+	// ブレーカーを開いてから、再び呼び出しを試すまでの時間
+	BreakerCooldown time.Duration
+}
+
+func (c GitHubClientConfig) withDefaults() GitHubClientConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.RetryBaseDelay <= 0 {
+		c.RetryBaseDelay = 200 * time.Millisecond
+	}
+	if c.RetryMaxDelay <= 0 {
+		c.RetryMaxDelay = 2 * time.Second
+	}
+	if c.BreakerFailures <= 0 {
+		c.BreakerFailures = 5
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = 30 * time.Second
+	}
+	return c
+}
+
+// GitHubClient はGitHubのGraphQL APIを呼び出すクライアントです
+// トークンごとのレート制限を記録し、使い切っている間はGitHubに送信せずにErrGitHubRateLimitedを返します。
+// 5xxやタイムアウトは再試行し、それでも失敗が続く場合はサーキットブレーカーを開いて呼び出しを止めます
+type GitHubClient struct {
+	endpoint   string
+	httpClient *http.Client
+	cfg        GitHubClientConfig
+	breaker    *gobreaker.CircuitBreaker
+
+	mu sync.Mutex
+	// トークン（のハッシュ）ごとのレート制限の状態
+	rateLimits map[string]*tokenRateLimit
+}
+
+// トークンごとのレート制限の状態
+type tokenRateLimit struct {
+	// X-RateLimit-Remainingが0になった場合の、X-RateLimit-Resetの時刻
+	exhaustedUntil time.Time
+	// セカンダリレート制限のRetry-Afterで指定された時刻
+	retryAfterUntil time.Time
+}
+
+func (t *tokenRateLimit) blockedUntil() time.Time {
+	if t.retryAfterUntil.After(t.exhaustedUntil) {
+		return t.retryAfterUntil
+	}
+	return t.exhaustedUntil
+}
+
+// 記録するトークンがこの数を超えたら、制限が解除済みのものを削除する
+const maxTrackedTokens = 1000
+
+// NewGitHubClient はGitHubClientを作成します
+// endpointには通常GitHubGraphQLURLを渡します（テストではモックサーバーのURLを渡せます）
+func NewGitHubClient(endpoint string, httpClient *http.Client, cfg GitHubClientConfig) *GitHubClient {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	cfg = cfg.withDefaults()
+	return &GitHubClient{
+		endpoint:   endpoint,
+		httpClient: httpClient,
+		cfg:        cfg,
+		breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "github",
+			Timeout: cfg.BreakerCooldown,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= uint32(cfg.BreakerFailures)
+			},
+			// 4xxやレート制限はGitHub自体は動いているため、障害として数えない
+			IsSuccessful: func(err error) bool {
+				return err == nil || !isRetryable(err)
+			},
+			OnStateChange: func(_ string, from, to gobreaker.State) {
+				slog.Warn("GitHub APIのサーキットブレーカーの状態が変わりました", "from", from.String(), "to", to.String())
+				metrics.SetGitHubCircuitState(int(to))
+			},
+		}),
+		rateLimits: make(map[string]*tokenRateLimit),
+	}
+}
+
+// GitHubのGraphQL APIにリクエストを送信し、ステータスコードが200の場合にレスポンスボディを返します
+func (g *GitHubClient) postGraphQL(ctx context.Context, graphQLReq models.GraphQLRequest, githubToken string) ([]byte, error) {
+	requestBody, err := json.Marshal(graphQLReq)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal GraphQL request", "error", err)
+		return nil, fmt.Errorf("Failed to build request body: %w", err)
+	}
+
+	// レート制限を使い切っている間は送信しない（続けて送るとGitHubにトークンを制限されるため）
+	key := tokenKey(githubToken)
+	if wait := g.rateLimitWait(key, time.Now()); wait > 0 {
+		slog.InfoContext(ctx, "GitHub APIのレート制限が解除されるまで送信しません", "retry_after", wait)
+		return nil, rateLimitedError(wait)
+	}
+
+	var body []byte
+	_, err = g.breaker.Execute(func() (interface{}, error) {
+		var err error
+		body, err = g.doWithRetry(ctx, requestBody, githubToken, key)
+		return nil, err
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		slog.WarnContext(ctx, "サーキットブレーカーが開いているためGitHub APIを呼び出しません")
+		return nil, apperrors.WithExtensions(ErrGitHubCircuitOpen, map[string]interface{}{
+			"retryAfter": int64(math.Ceil(g.cfg.BreakerCooldown.Seconds())),
+		})
+	}
+	return body, err
+}
+
+// 5xx・タイムアウト・接続エラーの場合、指数バックオフ（ジッターあり）で再試行する
+func (g *GitHubClient) doWithRetry(ctx context.Context, requestBody []byte, githubToken, key string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := g.do(ctx, requestBody, githubToken, key)
+		if err == nil || !isRetryable(err) || attempt >= g.cfg.MaxRetries {
+			return body, err
+		}
+
+		delay := g.backoff(attempt)
+		metrics.IncGitHubRetry()
+		slog.WarnContext(ctx, "GitHub APIの呼び出しを再試行します", "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// 1回分のリクエストを送信し、レスポンスボディを読み込んで返す
+func (g *GitHubClient) do(ctx context.Context, requestBody []byte, githubToken, key string) ([]byte, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, g.cfg.Timeout)
+	defer cancel()
+
+	// GitHub APIへのHTTPリクエストを作成
+	request, err := http.NewRequestWithContext(attemptCtx, "POST", g.endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create HTTP request", "error", err)
+		return nil, fmt.Errorf("Failed to create HTTP request: %w", err)
+	}
+	request.Header.Set("Authorization", "bearer "+githubToken)
+	request.Header.Set("Content-Type", "application/json") // Content-Typeの指定は必須
+
+	// リクエストを実行
+	response, err := g.httpClient.Do(request)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to send request to GitHub", "error", err)
+		return nil, g.transportError(ctx, fmt.Errorf("Failed to send request to GitHub: %v: %w", err, apperrors.ErrGitHubUnavailable))
+	}
+	defer response.Body.Close()
+
+	g.recordRateLimit(key, response, time.Now())
+
+	// GitHubからのレスポンスステータスコードをチェック
+	if response.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "GitHub API returned non-200 status", "status", response.StatusCode)
+		err := fmt.Errorf("GitHub API returned status code %d: %w", response.StatusCode, g.statusError(key, response))
+		if response.StatusCode >= 500 {
+			return nil, &retryableError{err: err}
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read GitHub response", "error", err)
+		return nil, g.transportError(ctx, fmt.Errorf("Failed to read GitHub response: %v: %w", err, apperrors.ErrGitHubUnavailable))
+	}
+	return body, nil
+}
+
+// 通信エラーは再試行する。ただし呼び出し元がキャンセルした場合は再試行しない
+func (g *GitHubClient) transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return &retryableError{err: err}
+}
+
+// GitHubのステータスコードをドメインエラーに変換する
+func (g *GitHubClient) statusError(key string, response *http.Response) error {
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		return apperrors.ErrGitHubUnauthorized
+	case response.StatusCode == http.StatusTooManyRequests,
+		// プライマリ・セカンダリのレート制限は403で返ることがある
+		response.StatusCode == http.StatusForbidden && (response.Header.Get("X-RateLimit-Remaining") == "0" || response.Header.Get("Retry-After") != ""):
+		return rateLimitedError(g.rateLimitWait(key, time.Now()))
+	case response.StatusCode == http.StatusForbidden:
+		return apperrors.ErrGitHubUnauthorized
+	default:
+		return apperrors.ErrGitHubUnavailable
+	}
+}
+
+// 再試行までの待ち時間（Full Jitter）
+func (g *GitHubClient) backoff(attempt int) time.Duration {
+	ceiling := g.cfg.RetryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > g.cfg.RetryMaxDelay {
+		ceiling = g.cfg.RetryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// レスポンスヘッダーからトークンのレート制限の状態を記録する
+func (g *GitHubClient) recordRateLimit(key string, response *http.Response, now time.Time) {
+	remainingHeader := response.Header.Get("X-RateLimit-Remaining")
+	remaining, remainingErr := strconv.Atoi(remainingHeader)
+	if remainingErr == nil {
+		metrics.SetGitHubRateLimitRemaining(remaining)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.rateLimits[key]
+	if state == nil {
+		state = &tokenRateLimit{}
+	}
+	if remainingErr == nil {
+		state.exhaustedUntil = time.Time{}
+		if remaining == 0 {
+			if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				state.exhaustedUntil = time.Unix(reset, 0)
+			}
+		}
+	}
+	// セカンダリレート制限ではRetry-After（秒）が返る
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		state.retryAfterUntil = now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if state.blockedUntil().After(now) {
+		g.rateLimits[key] = state
+	} else {
+		delete(g.rateLimits, key)
+	}
+
+	if len(g.rateLimits) > maxTrackedTokens {
+		for k, s := range g.rateLimits {
+			if !s.blockedUntil().After(now) {
+				delete(g.rateLimits, k)
+			}
+		}
+	}
+}
+
+// トークンのレート制限が解除されるまでの時間（制限されていない場合は0）
+func (g *GitHubClient) rateLimitWait(key string, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.rateLimits[key]
+	if !ok {
+		return 0
+	}
+	wait := state.blockedUntil().Sub(now)
+	if wait <= 0 {
+		delete(g.rateLimits, key)
+		return 0
+	}
+	return wait
+}
+
+// トークンそのものをメモリに残さないよう、ハッシュをキーにする
+func tokenKey(githubToken string) string {
+	sum := sha256.Sum256([]byte(githubToken))
+	return hex.EncodeToString(sum[:8])
+}
+
+// レート制限のエラー。解除までの時間が分かる場合はretryAfter（秒）をレスポンスに含める
+func rateLimitedError(wait time.Duration) error {
+	if wait <= 0 {
+		return apperrors.ErrGitHubRateLimited
+	}
+	return apperrors.WithExtensions(apperrors.ErrGitHubRateLimited, map[string]interface{}{
+		"retryAfter": int64(math.Ceil(wait.Seconds())),
+	})
+}
+
+// retryableError は再試行すれば成功する可能性があるエラー（5xx・タイムアウト・接続エラー）です
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+func isRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+// IsGitHubTemporaryError はGitHubの障害・レート制限など、時間をおけば解消するエラーかどうかを返します
+func IsGitHubTemporaryError(err error) bool {
+	return errors.Is(err, apperrors.ErrGitHubUnavailable) || errors.Is(err, apperrors.ErrGitHubRateLimited)
+}
+
+// GraphQLレベルのエラーをドメインエラーに変換する
+// GraphQLのエラーはHTTPとしては成功したレスポンスで返るため、再試行せず、サーキットブレーカーの失敗としても数えない
+func graphQLError(gqlErr models.GraphQLError) error {
+	switch gqlErr.Type {
+	case "NOT_FOUND":
+		return fmt.Errorf("GraphQL error: %s: %w", gqlErr.Message, apperrors.ErrGitHubUserNotFound)
+	case "RATE_LIMITED":
+		return fmt.Errorf("GraphQL error: %s: %w", gqlErr.Message, apperrors.ErrGitHubRateLimited)
+	case "FORBIDDEN":
+		// トークンのスコープ不足やSSOの未承認など。HTTPの403と同じく、時間をおいても解消しない
+		return fmt.Errorf("GraphQL error: %s: %w", gqlErr.Message, apperrors.ErrGitHubUnauthorized)
+	default:
+		// クエリの誤りなど。GitHubの障害ではないため、一時的なエラー（ErrGitHubUnavailable）にはせず内部エラーとして扱う
+		return fmt.Errorf("GraphQL error (%s): %s", gqlErr.Type, gqlErr.Message)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+)
+
+const testViewerResponse = `{"data":{"viewer":{"login":"plmwa","avatarUrl":"https://avatars.githubusercontent.com/u/1"}}}`
+
+// 何回目の呼び出しか（1から）を渡してhandlerで応答するモックのGitHub API
+func newMockGitHub(t *testing.T, handler func(w http.ResponseWriter, attempt int)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "bearer test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		handler(w, int(calls.Add(1)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestGitHubClient(endpoint string, cfg GitHubClientConfig) *GitHubClient {
+	cfg.RetryBaseDelay = time.Millisecond
+	cfg.RetryMaxDelay = time.Millisecond
+	return NewGitHubClient(endpoint, nil, cfg)
+}
+
+func TestGitHubClientRetry(t *testing.T) {
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, attempt int) {
+		if attempt <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(testViewerResponse))
+	})
+	client := newTestGitHubClient(server.URL, GitHubClientConfig{MaxRetries: 2})
+
+	viewer, err := client.GetViewer(context.Background(), "test-token")
+	if err != nil {
+		t.Fatalf("GetViewer() error = %v", err)
+	}
+	if viewer.Login != "plmwa" {
+		t.Errorf("Login = %q", viewer.Login)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("呼び出し回数 = %d, want 3", got)
+	}
+}
+
+func TestGitHubClientRetryExhausted(t *testing.T) {
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client := newTestGitHubClient(server.URL, GitHubClientConfig{MaxRetries: 2})
+
+	_, err := client.GetViewer(context.Background(), "test-token")
+	if !errors.Is(err, apperrors.ErrGitHubUnavailable) || !IsGitHubTemporaryError(err) {
+		t.Fatalf("GetViewer() error = %v, want ErrGitHubUnavailable", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("呼び出し回数 = %d, want 3（初回と再試行2回）", got)
+	}
+}
+
+func TestGitHubClientRetryCanceled(t *testing.T) {
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	client := NewGitHubClient(server.URL, nil, GitHubClientConfig{MaxRetries: 5, RetryBaseDelay: time.Hour, RetryMaxDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetViewer(ctx, "test-token"); err == nil {
+		t.Fatal("GetViewer() error = nil")
+	}
+	// 待っている間にキャンセルされたら再試行しない
+	if got := calls.Load(); got != 1 {
+		t.Errorf("呼び出し回数 = %d, want 1", got)
+	}
+}
+
+func TestGitHubClientStatusError(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		header        map[string]string
+		want          error
+		wantRetryable bool
+	}{
+		{name: "401", status: http.StatusUnauthorized, want: apperrors.ErrGitHubUnauthorized},
+		{name: "403", status: http.StatusForbidden, want: apperrors.ErrGitHubUnauthorized},
+		{name: "403 プライマリレート制限", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0"}, want: apperrors.ErrGitHubRateLimited},
+		{name: "403 セカンダリレート制限", status: http.StatusForbidden, header: map[string]string{"Retry-After": "60"}, want: apperrors.ErrGitHubRateLimited},
+		{name: "429", status: http.StatusTooManyRequests, want: apperrors.ErrGitHubRateLimited},
+		{name: "404", status: http.StatusNotFound, want: apperrors.ErrGitHubUnavailable},
+		{name: "500", status: http.StatusInternalServerError, want: apperrors.ErrGitHubUnavailable, wantRetryable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			})
+			client := newTestGitHubClient(server.URL, GitHubClientConfig{MaxRetries: 1})
+
+			_, err := client.GetViewer(context.Background(), "test-token")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("GetViewer() error = %v, want %v", err, tt.want)
+			}
+			wantCalls := int32(1)
+			if tt.wantRetryable {
+				wantCalls = 2
+			}
+			if got := calls.Load(); got != wantCalls {
+				t.Errorf("呼び出し回数 = %d, want %d", got, wantCalls)
+			}
+		})
+	}
+}
+
+func TestGitHubClientRateLimitBlocksToken(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusForbidden)
+	})
+	client := newTestGitHubClient(server.URL, GitHubClientConfig{})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetViewer(context.Background(), "test-token")
+		if !errors.Is(err, apperrors.ErrGitHubRateLimited) {
+			t.Fatalf("%d回目: GetViewer() error = %v, want ErrGitHubRateLimited", i+1, err)
+		}
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) || appErr.Extensions["retryAfter"] == nil {
+			t.Errorf("%d回目: retryAfterがありません: %v", i+1, err)
+		}
+	}
+	// 使い切った後は解除されるまで送信しない
+	if got := calls.Load(); got != 1 {
+		t.Errorf("呼び出し回数 = %d, want 1", got)
+	}
+}
+
+func TestGitHubClientBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testViewerResponse))
+	})
+	client := newTestGitHubClient(server.URL, GitHubClientConfig{BreakerFailures: 2, BreakerCooldown: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetViewer(ctx, "test-token"); errors.Is(err, ErrGitHubCircuitOpen) {
+			t.Fatalf("%d回目でブレーカーが開きました", i+1)
+		}
+	}
+
+	// 連続して失敗したらGitHubに送信せずに拒否する
+	_, err := client.GetViewer(ctx, "test-token")
+	if !errors.Is(err, ErrGitHubCircuitOpen) || !errors.Is(err, apperrors.ErrGitHubUnavailable) {
+		t.Fatalf("GetViewer() error = %v, want ErrGitHubCircuitOpen", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("呼び出し回数 = %d, want 2", got)
+	}
+
+	// クールダウンの後は再び呼び出し、成功したら閉じる
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetViewer(ctx, "test-token"); err != nil {
+			t.Fatalf("クールダウン後の%d回目: GetViewer() error = %v", i+1, err)
+		}
+	}
+}
+
+func TestGitHubClientBreakerIgnoresClientErrors(t *testing.T) {
+	server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	client := newTestGitHubClient(server.URL, GitHubClientConfig{BreakerFailures: 1})
+
+	// 4xxはGitHubの障害ではないため、何度失敗してもブレーカーは開かない
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetViewer(context.Background(), "test-token"); !errors.Is(err, apperrors.ErrGitHubUnauthorized) {
+			t.Fatalf("%d回目: GetViewer() error = %v, want ErrGitHubUnauthorized", i+1, err)
+		}
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("呼び出し回数 = %d, want 3", got)
+	}
+}
+
+func TestGitHubClientGraphQLError(t *testing.T) {
+	tests := []struct {
+		name      string
+		errors    string
+		want      error
+		wantCode  string
+		temporary bool
+	}{
+		{"NOT_FOUND", `[{"type":"NOT_FOUND","message":"Could not resolve to a User"}]`, apperrors.ErrGitHubUserNotFound, "github_user_not_found", false},
+		{"RATE_LIMITED", `[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]`, apperrors.ErrGitHubRateLimited, "github_rate_limited", true},
+		{"FORBIDDEN", `[{"type":"FORBIDDEN","message":"Resource not accessible by integration"}]`, apperrors.ErrGitHubUnauthorized, "github_unauthorized", false},
+		// クエリの誤りなどはGitHubの障害ではない
+		{"不明な種類", `[{"type":"INVALID_CURSOR_ARGUMENTS","message":"bad cursor"}]`, nil, "internal", false},
+		{"種類なし", `[{"message":"Field 'foo' doesn't exist on type 'User'"}]`, nil, "internal", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newMockGitHub(t, func(w http.ResponseWriter, _ int) {
+				w.Write([]byte(`{"data":null,"errors":` + tt.errors + `}`))
+			})
+			client := newTestGitHubClient(server.URL, GitHubClientConfig{BreakerFailures: 1, MaxRetries: 2})
+
+			// 何度失敗しても再試行せず、ブレーカーも開かない
+			for i := 0; i < 3; i++ {
+				_, err := client.GetViewer(context.Background(), "test-token")
+				if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+					t.Fatalf("GetViewer() error = %v, want %v", err, tt.want)
+				}
+				if errors.Is(err, apperrors.ErrGitHubUnavailable) || IsGitHubTemporaryError(err) != tt.temporary {
+					t.Errorf("GetViewer() error = %v, IsGitHubTemporaryError = %v", err, IsGitHubTemporaryError(err))
+				}
+				if got := apperrors.Code(err); got != tt.wantCode {
+					t.Errorf("Code() = %s, want %s", got, tt.wantCode)
+				}
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("呼び出し回数 = %d, want 3", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+)
+
+// GetRateLimit はトークンのGraphQL APIのレート制限の状態を取得します
+// rateLimitクエリ自体はポイントを消費しないため、トークンの有効性の確認（readiness）にも使えます
+func (g *GitHubClient) GetRateLimit(ctx context.Context, githubToken string) (_ models.GraphQLRateLimit, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGitHubRequest("rate_limit", time.Since(start), err)
+	}()
+
+	query := `
+        query {
+            rateLimit {
+                cost
+                limit
+                remaining
+                resetAt
+            }
+        }`
+
+	body, err := g.postGraphQL(ctx, models.GraphQLRequest{Query: query}, githubToken)
+	if err != nil {
+		return models.GraphQLRateLimit{}, err
+	}
+
+	var rateLimitResponse models.GithubRateLimitResponse
+	if err := json.Unmarshal(body, &rateLimitResponse); err != nil {
+		slog.ErrorContext(ctx, "Failed to decode GitHub rateLimit response", "error", err)
+		return models.GraphQLRateLimit{}, fmt.Errorf("Failed to decode GitHub response: %w", err)
+	}
+
+	if len(rateLimitResponse.Errors) > 0 {
+		slog.WarnContext(ctx, "GraphQL error", "type", rateLimitResponse.Errors[0].Type, "message", rateLimitResponse.Errors[0].Message)
+		return models.GraphQLRateLimit{}, graphQLError(rateLimitResponse.Errors[0])
+	}
+
+	metrics.SetGitHubRateLimitRemaining(rateLimitResponse.Data.RateLimit.Remaining)
+	return rateLimitResponse.Data.RateLimit, nil
+}
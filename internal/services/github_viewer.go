@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+)
+
+// GetViewer はトークンの持ち主（viewer）のGitHubログイン名とアバターURLを取得します
+func (g *GitHubClient) GetViewer(ctx context.Context, githubToken string) (_ models.GithubViewer, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGitHubRequest("viewer", time.Since(start), err)
+	}()
+
+	query := `
+        query {
+            viewer {
+                login
+                avatarUrl
+            }
+        }`
+
+	body, err := g.postGraphQL(ctx, models.GraphQLRequest{Query: query}, githubToken)
+	if err != nil {
+		return models.GithubViewer{}, err
+	}
+
+	var viewerResponse models.GithubViewerResponse
+	if err := json.Unmarshal(body, &viewerResponse); err != nil {
+		slog.ErrorContext(ctx, "Failed to decode GitHub viewer response", "error", err)
+		return models.GithubViewer{}, fmt.Errorf("Failed to decode GitHub response: %w", err)
+	}
+
+	if len(viewerResponse.Errors) > 0 {
+		slog.WarnContext(ctx, "GraphQL error", "type", viewerResponse.Errors[0].Type, "message", viewerResponse.Errors[0].Message)
+		return models.GithubViewer{}, graphQLError(viewerResponse.Errors[0])
+	}
+
+	return viewerResponse.Data.Viewer, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"geekcamp-vol10-backend/internal/heatmap"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/validation"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// HeatmapService はコントリビューションのヒートマップに描画するデータを組み立てます
+type HeatmapService struct {
+	users    *repositories.UserRepository
+	history  *ContributionHistoryService
+	monsters *repositories.MonsterRepository
+}
+
+// NewHeatmapService はHeatmapServiceを作成します
+func NewHeatmapService(users *repositories.UserRepository, history *ContributionHistoryService, monsters *repositories.MonsterRepository) *HeatmapService {
+	return &HeatmapService{
+		users:    users,
+		history:  history,
+		monsters: monsters,
+	}
+}
+
+// Heatmap はヒートマップの描画に使うデータです
+type Heatmap struct {
+	Calendar heatmap.Calendar
+	// ユーザーの表示設定のテーマ（未設定の場合は空）
+	Theme string
+}
+
+// Heatmap はユーザーの直近weeks週分のコントリビューションと封印の記録を、ユーザーのタイムゾーンの日付で返します
+// 日ごとのコントリビューションには、その日にダメージを与えたモンスター（その日以降で最初に封印したモンスター、
+// まだ封印していなければ育成中のモンスター）の名前を付けます
+func (s *HeatmapService) Heatmap(ctx context.Context, id string, weeks int) (*Heatmap, error) {
+	user, err := s.users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	loc := s.history.Location(user)
+	end := truncateDay(time.Now().In(loc))
+	start := bucketStart(end, GranularityWeek).AddDate(0, 0, -7*(weeks-1))
+
+	var (
+		history        *ContributionHistory
+		sealed         []models.SealedMonster
+		currentMonster *models.CurrentMonster
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		history, err = s.history.HistoryForUser(gctx, id, user, ContributionHistoryQuery{
+			From:        start.Format(dateLayout),
+			To:          end.Format(dateLayout),
+			Granularity: GranularityDay,
+		})
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		sealed, err = listAllSealedMonsters(gctx, s.users, id, start)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		currentMonster, err = s.users.GetCurrentMonster(gctx, id)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(sealed)+1)
+	for _, seal := range sealed {
+		ids = append(ids, seal.MonsterId)
+	}
+	if currentMonster != nil {
+		ids = append(ids, currentMonster.MonsterId)
+	}
+	monsters, err := s.monsters.GetMonsters(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := heatmap.Calendar{Start: start, End: end, Weeks: weeks}
+	for _, seal := range sealed {
+		calendar.Seals = append(calendar.Seals, heatmap.Seal{
+			Date:        seal.SealedAt.In(loc),
+			MonsterName: seal.MonsterName,
+			IconURL:     monsterIconURL(monsters[seal.MonsterId]),
+		})
+	}
+
+	currentName := ""
+	if currentMonster != nil {
+		currentName = monsterName(monsters, currentMonster.MonsterId)
+	}
+	next := 0
+	for _, bucket := range history.Buckets {
+		date, err := time.ParseInLocation(dateLayout, bucket.Start, loc)
+		if err != nil {
+			continue
+		}
+		// 封印した日のコントリビューションは、その日に封印したモンスターへのダメージとして数える
+		for next < len(calendar.Seals) && calendar.Seals[next].Date.Format(dateLayout) < bucket.Start {
+			next++
+		}
+		monster := currentName
+		if next < len(calendar.Seals) {
+			monster = calendar.Seals[next].MonsterName
+		}
+		calendar.Days = append(calendar.Days, heatmap.Day{Date: date, Count: bucket.Total, Monster: monster})
+	}
+
+	res := &Heatmap{Calendar: calendar}
+	if user.DisplaySettings != nil {
+		res.Theme = user.DisplaySettings.Theme
+	}
+	return res, nil
+}
+
+// SVGから読み込ませるため、httpsのURLのみ使う
+func monsterIconURL(monster models.Monster) string {
+	if validation.IsHTTPSURL(monster.ImageURL) {
+		return monster.ImageURL
+	}
+	return ""
+}
+
+func monsterName(monsters map[string]models.Monster, id string) string {
+	if monster, ok := monsters[id]; ok && monster.Name != "" {
+		return monster.Name
+	}
+	return "モンスター" + id
+}
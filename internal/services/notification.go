@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/notify"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/repositories"
+
+	"cloud.google.com/go/firestore"
+)
+
+// 1人のユーザーが登録できる端末の数
+// 超えた場合は最も長く使われていない端末の登録を解除する
+const maxDeviceTokens = 10
+
+// NotificationUsers はNotificationServiceが使うユーザーと端末の読み書きです
+// 通常は*repositories.UserRepositoryを使います（テストではメモリ上の実装に置き換えます）
+type NotificationUsers interface {
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	UpdateUserFields(ctx context.Context, id string, updates []firestore.Update) error
+	SaveDeviceToken(ctx context.Context, id, token, platform string, now time.Time) (_ *models.DeviceToken, created bool, _ error)
+	ListDeviceTokens(ctx context.Context, id string) ([]models.DeviceToken, error)
+	DeleteDeviceToken(ctx context.Context, id, deviceId string) error
+	DeleteDeviceTokensByValue(ctx context.Context, id string, tokens []string) error
+	IterateStreakReminderCandidates(ctx context.Context, from, to time.Time, fn func(id string, user models.User) error) error
+	ClaimStreakReminder(ctx context.Context, id string, deadline time.Time) (bool, error)
+}
+
+// NotificationMonsters はNotificationServiceが通知の文面に使うモンスターの読み込みです
+// 通常は*repositories.MonsterRepositoryを使います
+type NotificationMonsters interface {
+	GetMonsters(ctx context.Context, ids []string) (map[string]models.Monster, error)
+}
+
+var (
+	_ NotificationUsers    = (*repositories.UserRepository)(nil)
+	_ NotificationMonsters = (*repositories.MonsterRepository)(nil)
+)
+
+// NotificationService は端末の登録と、ユーザーへのプッシュ通知の送信を行います
+type NotificationService struct {
+	users    NotificationUsers
+	monsters NotificationMonsters
+	notifier notify.Notifier
+}
+
+// NewNotificationService はNotificationServiceを作成します
+func NewNotificationService(users NotificationUsers, monsters NotificationMonsters, notifier notify.Notifier) *NotificationService {
+	return &NotificationService{
+		users:    users,
+		monsters: monsters,
+		notifier: notifier,
+	}
+}
+
+// RegisterDevice は通知を送る端末を登録します
+// 既に登録済みのトークンの場合は最終利用日時を更新し、createdをfalseで返します
+func (s *NotificationService) RegisterDevice(ctx context.Context, id, token, platform string) (_ *models.DeviceToken, created bool, _ error) {
+	device, created, err := s.users.SaveDeviceToken(ctx, id, token, platform, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 機種変更などで使われなくなった端末が溜まり続けないよう、古いものから解除する
+	if created {
+		devices, err := s.users.ListDeviceTokens(ctx, id)
+		if err != nil {
+			slog.WarnContext(ctx, "端末の一覧の取得に失敗しました", "user_id", id, "error", err)
+		} else if len(devices) > maxDeviceTokens {
+			for _, old := range devices[maxDeviceTokens:] {
+				if err := s.users.DeleteDeviceToken(ctx, id, old.DeviceId); err != nil {
+					slog.WarnContext(ctx, "古い端末の登録解除に失敗しました", "user_id", id, "device_id", old.DeviceId, "error", err)
+				}
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "端末を登録しました", "user_id", id, "device_id", device.DeviceId, "platform", platform, "created", created)
+	return device, created, nil
+}
+
+// UnregisterDevice は端末の登録を解除します
+func (s *NotificationService) UnregisterDevice(ctx context.Context, id, deviceId string) error {
+	if err := s.users.DeleteDeviceToken(ctx, id, deviceId); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "端末の登録を解除しました", "user_id", id, "device_id", deviceId)
+	return nil
+}
+
+// NotificationSettings はカテゴリごとに通知を受け取るかどうかです
+type NotificationSettings map[string]bool
+
+func newNotificationSettings(user *models.User) NotificationSettings {
+	settings := make(NotificationSettings, len(notify.Categories))
+	for _, category := range notify.Categories {
+		settings[category] = !user.NotificationOptOuts[category]
+	}
+	return settings
+}
+
+// Settings はユーザーの通知の設定を返します
+func (s *NotificationService) Settings(ctx context.Context, id string) (NotificationSettings, error) {
+	user, err := s.users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newNotificationSettings(user), nil
+}
+
+// UpdateSettings はenabledに含まれるカテゴリの通知の受け取りを変更し、変更後の設定を返します
+// カテゴリはnotify.IsCategoryで検証済みであることを前提とします
+func (s *NotificationService) UpdateSettings(ctx context.Context, id string, enabled map[string]bool) (NotificationSettings, error) {
+	user, err := s.users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.NotificationOptOuts == nil {
+		user.NotificationOptOuts = make(map[string]bool)
+	}
+	updates := make([]firestore.Update, 0, len(enabled))
+	for category, on := range enabled {
+		user.NotificationOptOuts[category] = !on
+		updates = append(updates, firestore.Update{Path: "notificationOptOuts." + category, Value: !on})
+	}
+	if err := s.users.UpdateUserFields(ctx, id, updates); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "通知の設定を更新しました", "user_id", id, "categories", len(enabled))
+	return newNotificationSettings(user), nil
+}
+
+// HandleEvent はoutbox.Dispatcherに登録するコンシューマーで、封印と新しいモンスターの割り当てを通知します
+// 送信に失敗した場合はエラーを返して再試行させます（一部の端末には同じ通知が2回届くことがあります）
+func (s *NotificationService) HandleEvent(ctx context.Context, ev outbox.Event) error {
+	user, err := s.users.GetUserByID(ctx, ev.UserID)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		// イベントの後に削除されたユーザーには通知しない
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch ev.Type {
+	case outbox.TypeMonsterSealed:
+		var sealed outbox.MonsterSealed
+		if err := ev.Decode(&sealed); err != nil {
+			return err
+		}
+		return s.send(ctx, ev.UserID, user, notify.Message{
+			Category: notify.CategorySeal,
+			Title:    "モンスターを封印しました",
+			Body:     fmt.Sprintf("%sを封印しました！", sealed.MonsterName),
+			Data:     map[string]string{"monsterId": sealed.MonsterId},
+		})
+	case outbox.TypeMonsterAssigned:
+		var assigned outbox.MonsterAssigned
+		if err := ev.Decode(&assigned); err != nil {
+			return err
+		}
+		// 名前は通知の文面にしか使わないため、取得できなくても通知する
+		monsters, err := s.monsters.GetMonsters(ctx, []string{assigned.MonsterId})
+		if err != nil {
+			slog.WarnContext(ctx, "モンスターの取得に失敗しました", "monster_id", assigned.MonsterId, "error", err)
+		}
+		return s.send(ctx, ev.UserID, user, notify.Message{
+			Category: notify.CategoryMonsterAssigned,
+			Title:    "新しいモンスターが現れました",
+			Body: fmt.Sprintf("%sが現れました。あと%dコントリビューションで封印できます",
+				monsterName(monsters, assigned.MonsterId), max(assigned.RequiredContributions-assigned.ProgressContributions, 0)),
+			Data: map[string]string{"monsterId": assigned.MonsterId},
+		})
+	}
+	return nil
+}
+
+// RemindStreaksAtRisk は連続記録の期限（最後にコントリビューションを反映した日の翌日の終わり）まで
+// before以内のユーザーに、記録が途切れそうなことを通知し、通知した人数を返します
+// 同じ期限については1回だけ通知します
+func (s *NotificationService) RemindStreaksAtRisk(ctx context.Context, now time.Time, before time.Duration) (int, error) {
+	sent := 0
+	err := s.users.IterateStreakReminderCandidates(ctx, now.Add(-24*time.Hour), now.Add(before-24*time.Hour), func(id string, user models.User) error {
+		if user.ContinuousSealRecord <= 0 {
+			return nil
+		}
+		deadline := user.LastContributionReflectedAt.Add(24 * time.Hour)
+		if user.StreakReminderSentFor.Equal(deadline) {
+			return nil
+		}
+		if user.NotificationOptOuts[notify.CategoryStreakAtRisk] {
+			metrics.IncNotification(notify.CategoryStreakAtRisk, metrics.NotificationOptedOut)
+			return nil
+		}
+
+		claimed, err := s.users.ClaimStreakReminder(ctx, id, deadline)
+		if err != nil {
+			slog.WarnContext(ctx, "連続記録の通知の記録に失敗しました", "user_id", id, "error", err)
+			return nil
+		}
+		if !claimed {
+			return nil
+		}
+		// 期限は記録済みのため、送信に失敗しても同じ期限については再送しない
+		if err := s.send(ctx, id, &user, notify.Message{
+			Category: notify.CategoryStreakAtRisk,
+			Title:    "連続記録が途切れそうです",
+			Body: fmt.Sprintf("%d日連続の記録が途切れるまであと約%d時間です。コントリビューションしてアプリで反映しましょう",
+				user.ContinuousSealRecord, max(int(deadline.Sub(now).Hours()), 1)),
+			Data: map[string]string{"deadline": deadline.UTC().Format(time.RFC3339)},
+		}); err != nil {
+			slog.WarnContext(ctx, "連続記録の通知の送信に失敗しました", "user_id", id, "error", err)
+			return nil
+		}
+		sent++
+		return nil
+	})
+	return sent, err
+}
+
+// RunStreakReminders はctxがキャンセルされるまで、interval ごとにRemindStreaksAtRiskを実行します
+func (s *NotificationService) RunStreakReminders(ctx context.Context, interval, before time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sent, err := s.RemindStreaksAtRisk(ctx, time.Now(), before)
+		if err != nil {
+			slog.WarnContext(ctx, "連続記録が途切れそうなユーザーへの通知に失敗しました", "error", err)
+		} else if sent > 0 {
+			slog.InfoContext(ctx, "連続記録が途切れそうなユーザーに通知しました", "users", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// send はユーザーが通知を止めていなければ、登録されたすべての端末にmsgを送信します
+// 通知を止めている場合と端末が登録されていない場合は何もせずにnilを返します
+func (s *NotificationService) send(ctx context.Context, id string, user *models.User, msg notify.Message) error {
+	if user.NotificationOptOuts[msg.Category] {
+		metrics.IncNotification(msg.Category, metrics.NotificationOptedOut)
+		return nil
+	}
+
+	devices, err := s.users.ListDeviceTokens(ctx, id)
+	if err != nil {
+		metrics.IncNotification(msg.Category, metrics.NotificationError)
+		return fmt.Errorf("通知先の端末の取得に失敗しました: %w", err)
+	}
+	if len(devices) == 0 {
+		metrics.IncNotification(msg.Category, metrics.NotificationNoDevices)
+		return nil
+	}
+	tokens := make([]string, 0, len(devices))
+	for _, device := range devices {
+		tokens = append(tokens, device.Token)
+	}
+
+	invalid, err := s.notifier.Send(ctx, tokens, msg)
+	if len(invalid) > 0 {
+		if err := s.users.DeleteDeviceTokensByValue(ctx, id, invalid); err != nil {
+			slog.WarnContext(ctx, "無効な端末の登録解除に失敗しました", "user_id", id, "error", err)
+		} else {
+			slog.InfoContext(ctx, "無効になった端末の登録を解除しました", "user_id", id, "devices", len(invalid))
+		}
+	}
+	if err != nil {
+		metrics.IncNotification(msg.Category, metrics.NotificationError)
+		return err
+	}
+	metrics.IncNotification(msg.Category, metrics.NotificationSent)
+	slog.DebugContext(ctx, "通知を送信しました", "user_id", id, "category", msg.Category, "devices", len(tokens)-len(invalid))
+	return nil
+}
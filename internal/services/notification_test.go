@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/notify"
+	"geekcamp-vol10-backend/internal/outbox"
+
+	"cloud.google.com/go/firestore"
+)
+
+// メモリ上のNotificationUsers
+type memoryNotificationUsers struct {
+	users   map[string]*models.User
+	devices map[string][]models.DeviceToken
+	// ClaimStreakReminderを常にfalseにするユーザー（他のサーバーが先に通知した場合）
+	claimedElsewhere map[string]bool
+}
+
+func newMemoryNotificationUsers() *memoryNotificationUsers {
+	return &memoryNotificationUsers{
+		users:            make(map[string]*models.User),
+		devices:          make(map[string][]models.DeviceToken),
+		claimedElsewhere: make(map[string]bool),
+	}
+}
+
+func (m *memoryNotificationUsers) addDevices(id string, tokens ...string) {
+	for _, token := range tokens {
+		m.devices[id] = append(m.devices[id], models.DeviceToken{DeviceId: "device-" + token, Token: token, Platform: "ios"})
+	}
+}
+
+func (m *memoryNotificationUsers) tokens(id string) []string {
+	var tokens []string
+	for _, d := range m.devices[id] {
+		tokens = append(tokens, d.Token)
+	}
+	return tokens
+}
+
+func (m *memoryNotificationUsers) GetUserByID(_ context.Context, id string) (*models.User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (m *memoryNotificationUsers) UpdateUserFields(context.Context, string, []firestore.Update) error {
+	return nil
+}
+
+func (m *memoryNotificationUsers) SaveDeviceToken(context.Context, string, string, string, time.Time) (*models.DeviceToken, bool, error) {
+	return nil, false, errors.New("使いません")
+}
+
+func (m *memoryNotificationUsers) ListDeviceTokens(_ context.Context, id string) ([]models.DeviceToken, error) {
+	return slices.Clone(m.devices[id]), nil
+}
+
+func (m *memoryNotificationUsers) DeleteDeviceToken(context.Context, string, string) error {
+	return errors.New("使いません")
+}
+
+func (m *memoryNotificationUsers) DeleteDeviceTokensByValue(_ context.Context, id string, tokens []string) error {
+	m.devices[id] = slices.DeleteFunc(m.devices[id], func(d models.DeviceToken) bool {
+		return slices.Contains(tokens, d.Token)
+	})
+	return nil
+}
+
+func (m *memoryNotificationUsers) IterateStreakReminderCandidates(_ context.Context, from, to time.Time, fn func(id string, user models.User) error) error {
+	ids := make([]string, 0, len(m.users))
+	for id := range m.users {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		user := *m.users[id]
+		if user.LastContributionReflectedAt.After(from) && !user.LastContributionReflectedAt.After(to) {
+			if err := fn(id, user); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryNotificationUsers) ClaimStreakReminder(_ context.Context, id string, deadline time.Time) (bool, error) {
+	user := m.users[id]
+	if m.claimedElsewhere[id] || !user.LastContributionReflectedAt.Add(24*time.Hour).Equal(deadline) || user.StreakReminderSentFor.Equal(deadline) {
+		return false, nil
+	}
+	user.StreakReminderSentFor = deadline
+	return true, nil
+}
+
+type memoryMonsters map[string]models.Monster
+
+func (m memoryMonsters) GetMonsters(_ context.Context, ids []string) (map[string]models.Monster, error) {
+	found := make(map[string]models.Monster)
+	for _, id := range ids {
+		if monster, ok := m[id]; ok {
+			found[id] = monster
+		}
+	}
+	return found, nil
+}
+
+func newTestNotificationService() (*NotificationService, *memoryNotificationUsers, *notify.Fake) {
+	users := newMemoryNotificationUsers()
+	monsters := memoryMonsters{"monster-2": {MonsterId: "monster-2", Name: "スライム"}}
+	notifier := notify.NewFake()
+	return NewNotificationService(users, monsters, notifier), users, notifier
+}
+
+func mustNewEvent(t *testing.T, eventType, userID string, payload any) outbox.Event {
+	t.Helper()
+	ev, err := outbox.NewEvent(eventType, userID, payload, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestNotificationHandleEvent(t *testing.T) {
+	s, users, notifier := newTestNotificationService()
+	users.users["user-1"] = &models.User{}
+	users.addDevices("user-1", "token-a", "token-b")
+
+	sealed := mustNewEvent(t, outbox.TypeMonsterSealed, "user-1", outbox.MonsterSealed{MonsterId: "monster-1", MonsterName: "ドラゴン"})
+	if err := s.HandleEvent(context.Background(), sealed); err != nil {
+		t.Fatalf("HandleEvent(MonsterSealed) error = %v", err)
+	}
+	assigned := mustNewEvent(t, outbox.TypeMonsterAssigned, "user-1", outbox.MonsterAssigned{MonsterId: "monster-2", ProgressContributions: 3, RequiredContributions: 10})
+	if err := s.HandleEvent(context.Background(), assigned); err != nil {
+		t.Fatalf("HandleEvent(MonsterAssigned) error = %v", err)
+	}
+
+	sent := notifier.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("送信した通知 = %+v", sent)
+	}
+	if msg := sent[0].Message; msg.Category != notify.CategorySeal || msg.Body != "ドラゴンを封印しました！" || msg.Data["monsterId"] != "monster-1" || msg.Data["category"] != notify.CategorySeal {
+		t.Errorf("封印の通知 = %+v", msg)
+	}
+	if !slices.Equal(sent[0].Tokens, []string{"token-a", "token-b"}) {
+		t.Errorf("送信先 = %v", sent[0].Tokens)
+	}
+	if msg := sent[1].Message; msg.Category != notify.CategoryMonsterAssigned || msg.Body != "スライムが現れました。あと7コントリビューションで封印できます" {
+		t.Errorf("割り当ての通知 = %+v", msg)
+	}
+}
+
+func TestNotificationHandleEventOptOut(t *testing.T) {
+	s, users, notifier := newTestNotificationService()
+	users.users["user-1"] = &models.User{NotificationOptOuts: map[string]bool{notify.CategorySeal: true}}
+	users.addDevices("user-1", "token-a")
+
+	sealed := mustNewEvent(t, outbox.TypeMonsterSealed, "user-1", outbox.MonsterSealed{MonsterId: "monster-1"})
+	if err := s.HandleEvent(context.Background(), sealed); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if sent := notifier.Sent(); len(sent) != 0 {
+		t.Fatalf("通知を止めたカテゴリを送信しました: %+v", sent)
+	}
+
+	// 止めていないカテゴリは送信する
+	assigned := mustNewEvent(t, outbox.TypeMonsterAssigned, "user-1", outbox.MonsterAssigned{MonsterId: "monster-2"})
+	if err := s.HandleEvent(context.Background(), assigned); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if sent := notifier.Sent(); len(sent) != 1 || sent[0].Message.Category != notify.CategoryMonsterAssigned {
+		t.Errorf("送信した通知 = %+v", sent)
+	}
+}
+
+func TestNotificationHandleEventPrunesInvalidTokens(t *testing.T) {
+	s, users, notifier := newTestNotificationService()
+	users.users["user-1"] = &models.User{}
+	users.addDevices("user-1", "token-a", "token-b", "token-c")
+	notifier.SetInvalid("token-b")
+
+	sealed := mustNewEvent(t, outbox.TypeMonsterSealed, "user-1", outbox.MonsterSealed{MonsterId: "monster-1"})
+	if err := s.HandleEvent(context.Background(), sealed); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if got := users.tokens("user-1"); !slices.Equal(got, []string{"token-a", "token-c"}) {
+		t.Errorf("無効なトークンを削除した後の端末 = %v", got)
+	}
+
+	// 次の通知は残った端末にだけ送る
+	notifier.Reset()
+	if err := s.HandleEvent(context.Background(), sealed); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if sent := notifier.Sent(); len(sent) != 1 || !slices.Equal(sent[0].Tokens, []string{"token-a", "token-c"}) {
+		t.Errorf("送信した通知 = %+v", sent)
+	}
+}
+
+func TestNotificationHandleEventSkipsMissingUserAndDevices(t *testing.T) {
+	s, users, notifier := newTestNotificationService()
+	users.users["no-devices"] = &models.User{}
+
+	for _, id := range []string{"deleted", "no-devices"} {
+		ev := mustNewEvent(t, outbox.TypeMonsterSealed, id, outbox.MonsterSealed{MonsterId: "monster-1"})
+		if err := s.HandleEvent(context.Background(), ev); err != nil {
+			t.Errorf("%s: HandleEvent() error = %v", id, err)
+		}
+	}
+	if sent := notifier.Sent(); len(sent) != 0 {
+		t.Errorf("送信した通知 = %+v", sent)
+	}
+}
+
+func TestRemindStreaksAtRisk(t *testing.T) {
+	s, users, notifier := newTestNotificationService()
+	now := time.Date(2025, 8, 10, 20, 0, 0, 0, time.UTC)
+	// 期限（反映日時の24時間後）まで4時間
+	atRisk := now.Add(4 * time.Hour).Add(-24 * time.Hour)
+
+	users.users["at-risk"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: atRisk}
+	users.users["no-streak"] = &models.User{ContinuousSealRecord: 0, LastContributionReflectedAt: atRisk}
+	users.users["opted-out"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: atRisk, NotificationOptOuts: map[string]bool{notify.CategoryStreakAtRisk: true}}
+	users.users["already-reminded"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: atRisk, StreakReminderSentFor: atRisk.Add(24 * time.Hour)}
+	users.users["claimed-elsewhere"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: atRisk}
+	users.claimedElsewhere["claimed-elsewhere"] = true
+	// 期限まで20時間あるため、まだ通知しない
+	users.users["not-yet"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: now.Add(-4 * time.Hour)}
+	// 期限を過ぎて記録が途切れている
+	users.users["expired"] = &models.User{ContinuousSealRecord: 5, LastContributionReflectedAt: now.Add(-25 * time.Hour)}
+	for id := range users.users {
+		users.addDevices(id, "token-"+id)
+	}
+
+	sent, err := s.RemindStreaksAtRisk(context.Background(), now, 6*time.Hour)
+	if err != nil {
+		t.Fatalf("RemindStreaksAtRisk() error = %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("通知した人数 = %d, want 1", sent)
+	}
+	notifications := notifier.Sent()
+	if len(notifications) != 1 || !slices.Equal(notifications[0].Tokens, []string{"token-at-risk"}) {
+		t.Fatalf("送信した通知 = %+v", notifications)
+	}
+	msg := notifications[0].Message
+	if msg.Category != notify.CategoryStreakAtRisk || msg.Data["deadline"] != "2025-08-11T00:00:00Z" {
+		t.Errorf("通知 = %+v", msg)
+	}
+	if want := "5日連続の記録が途切れるまであと約4時間です。コントリビューションしてアプリで反映しましょう"; msg.Body != want {
+		t.Errorf("本文 = %q, want %q", msg.Body, want)
+	}
+
+	// 同じ期限については再び通知しない
+	notifier.Reset()
+	sent, err = s.RemindStreaksAtRisk(context.Background(), now.Add(time.Hour), 6*time.Hour)
+	if err != nil || sent != 0 || len(notifier.Sent()) != 0 {
+		t.Errorf("2回目: RemindStreaksAtRisk() = %d, %v; 送信した通知 = %+v", sent, err, notifier.Sent())
+	}
+}
@@ -2,20 +2,60 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 	
 	"cloud.google.com/go/firestore"
+	"geekcamp-vol10-backend/internal/apperrors"
 	"geekcamp-vol10-backend/internal/models"
 	"geekcamp-vol10-backend/internal/repositories"
-	"geekcamp-vol10-backend/pkg/database"
+	"golang.org/x/sync/errgroup"
 )
 
+// UserServiceUsers はUserServiceが使うユーザーの読み書きです
+// 通常は*repositories.UserRepositoryを使います（テストではメモリ上の実装に置き換えます）
+type UserServiceUsers interface {
+	CreateUserIfAbsent(ctx context.Context, user models.User) (*models.User, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetCurrentMonster(ctx context.Context, id string) (*models.CurrentMonster, error)
+	CountSealedMonsters(ctx context.Context, id string) (int64, error)
+	ListSealedMonsters(ctx context.Context, id string, q repositories.SealedMonstersQuery) (*repositories.SealedMonstersPage, error)
+	UpdateUserFields(ctx context.Context, id string, updates []firestore.Update) error
+}
 
-func CreateUser(ctx context.Context, db *firestore.Client, firebaseId, githubUserName, photoURL string) (map[string]interface{}, error) {
-	log.Printf("CreateUser: 新しいユーザーを作成中 - FirebaseId: %s", firebaseId)
-	
+// GitHubViewers はトークンの持ち主（viewer）の取得です
+// 通常は*GitHubClientを使います
+type GitHubViewers interface {
+	GetViewer(ctx context.Context, githubToken string) (models.GithubViewer, error)
+}
+
+var (
+	_ UserServiceUsers = (*repositories.UserRepository)(nil)
+	_ GitHubViewers    = (*GitHubClient)(nil)
+)
+
+// UserService はユーザーの登録・取得・プロフィール更新を扱います
+type UserService struct {
+	users  UserServiceUsers
+	github GitHubViewers
+}
+
+// NewUserService はUserServiceを作成します
+// githubはプロフィール更新時のGitHubユーザー名の確認に使います
+func NewUserService(users UserServiceUsers, github GitHubViewers) *UserService {
+	return &UserService{
+		users:  users,
+		github: github,
+	}
+}
+
+// CreateUser はユーザーを新規登録します
+// 既に登録済みの場合は上書きせず、既存のユーザー情報を返します。
+// 登録内容が同一（リトライ）であればcreated=falseでエラーなし、異なる場合は既存のユーザーとapperrors.ErrConflictを返します
+func (s *UserService) CreateUser(ctx context.Context, firebaseId, githubUserName, photoURL string) (_ *models.User, created bool, err error) {
 	user := models.User{
 		FirebaseId:           firebaseId,
 		GithubUserName:       githubUserName,
@@ -25,146 +65,217 @@ func CreateUser(ctx context.Context, db *firestore.Client, firebaseId, githubUse
 		MaxSealRecord:        0,
 	}
 
-	if err := repositories.SaveUser(ctx, db, user); err != nil {
-		log.Printf("CreateUser: ユーザー保存に失敗: %v", err)
-		return nil, err
+	existing, err := s.users.CreateUserIfAbsent(ctx, user)
+	if errors.Is(err, repositories.ErrUserAlreadyExists) {
+		if existing.GithubUserName == githubUserName && existing.PhotoURL == photoURL {
+			slog.InfoContext(ctx, "CreateUser: 同一内容の再登録のため既存ユーザーを返します", "user_id", firebaseId)
+			return existing, false, nil
+		}
+		slog.InfoContext(ctx, "CreateUser: 既存ユーザーと登録内容が異なります", "user_id", firebaseId)
+		return existing, false, fmt.Errorf("同じIDのユーザーが異なる内容で既に登録されています: %w", apperrors.ErrConflict)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateUser: ユーザー保存に失敗", "user_id", firebaseId, "error", err)
+		return nil, false, err
 	}
 
-	log.Printf("CreateUser: ユーザー作成完了")
-	
 	// 作成したユーザー情報を返す
-	userData := map[string]interface{}{
-		"firebaseId":           user.FirebaseId,
-		"githubUserName":       user.GithubUserName,
-		"photoURL":             user.PhotoURL,
-		"createdAt":            user.CreatedAt,
-		"continuousSealRecord": user.ContinuousSealRecord,
-		"maxSealRecord":        user.MaxSealRecord,
-	}
-	
-	return userData, nil
+	return &user, true, nil
 }
 
 
-func GetUserByIDService(ctx context.Context, id string) (*models.User, error) {
-	log.Printf("GetUserByIDService: ユーザーID '%s' の取得を開始", id)
+// GET /users/:id に含める封印済みモンスターの件数（新しい順）
+// それより前のものはListSealedMonstersでページングして取得する
+const RecentSealedMonstersLimit = 3
 
-	client := database.GetFirestoreClient()
-	if client == nil {
-		log.Printf("GetUserByIDService: Firestoreクライアントの初期化に失敗")
-		return nil, fmt.Errorf("Firestoreクライアントが初期化されていません")
-	}
+// GetUserByID はユーザーと現在のモンスター、封印済みモンスターの数と直近のものを取得します
+// 4つの読み込みは互いに依存しないため並行して行い、いずれかが失敗した時点で残りをキャンセルします
+func (s *UserService) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	var (
+		user           *models.User
+		currentMonster *models.CurrentMonster
+		sealedCount    int64
+		recentSealed   *repositories.SealedMonstersPage
+	)
+	g, gctx := errgroup.WithContext(ctx)
 
 	// ユーザー本体取得
-	log.Printf("GetUserByIDService: ユーザー本体の取得を試行中...")
-	main, err := client.Collection("users").Doc(id).Get(ctx)
-	if err != nil {
-		log.Printf("GetUserByIDService: ユーザー本体の取得に失敗: %v", err)
+	g.Go(func() error {
+		var err error
+		user, err = s.users.GetUserByID(gctx, id)
+		if err != nil && gctx.Err() == nil {
+			slog.WarnContext(ctx, "GetUserByID: ユーザー本体の取得に失敗", "user_id", id, "error", err)
+		}
+		return err
+	})
+
+	// currentMonsterの取得
+	g.Go(func() error {
+		var err error
+		currentMonster, err = s.users.GetCurrentMonster(gctx, id)
+		if err != nil && gctx.Err() == nil {
+			slog.ErrorContext(ctx, "GetUserByID: currentMonsterの取得に失敗", "user_id", id, "error", err)
+		}
+		return err
+	})
+
+	// sealedMonstersの数と直近のものの取得
+	g.Go(func() error {
+		var err error
+		sealedCount, err = s.users.CountSealedMonsters(gctx, id)
+		if err != nil && gctx.Err() == nil {
+			slog.ErrorContext(ctx, "GetUserByID: sealedMonstersの集計に失敗", "user_id", id, "error", err)
+		}
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		recentSealed, err = s.users.ListSealedMonsters(gctx, id, repositories.SealedMonstersQuery{
+			Limit:      RecentSealedMonstersLimit,
+			Descending: true,
+		})
+		if err != nil && gctx.Err() == nil {
+			slog.ErrorContext(ctx, "GetUserByID: sealedMonstersの取得に失敗", "user_id", id, "error", err)
+		}
+		return err
+	})
+
+	// 最初に失敗した読み込みのエラーを返す（ユーザーが存在しない場合はErrUserNotFound）
+	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
-	// 実際のFirestoreデータを確認
-	data := main.Data()
-	log.Printf("GetUserByIDService: Firestoreの生データ: %+v", data)
-	for key, value := range data {
-		log.Printf("フィールド '%s': 値=%v, 型=%T", key, value, value)
+	user.CurrentMonster = currentMonster
+	user.SealedMonsters = recentSealed.SealedMonsters
+	user.SealedMonsterCount = sealedCount
+	if user.CurrentMonster == nil {
+		slog.WarnContext(ctx, "GetUserByID: currentMonsterが見つかりませんでした", "user_id", id)
 	}
 
-	var user models.User
-	if err := main.DataTo(&user); err != nil {
-		log.Printf("GetUserByIDService: ユーザーデータのマッピングに失敗: %v", err)
-		return nil, err
-	}
-	
-	log.Printf("GetUserByIDService: ユーザー本体を正常に取得: %+v", user)
+	slog.DebugContext(ctx, "GetUserByID: ユーザー情報を取得しました",
+		"user_id", id,
+		"has_current_monster", user.CurrentMonster != nil,
+		"sealed_monsters", user.SealedMonsterCount,
+	)
+	return user, nil
+}
 
-	// currentMonsterの取得
-	log.Printf("GetUserByIDService: currentMonsterの取得を試行中...")
-	sub, err := client.Collection("users").Doc(id).Collection("currentMonster").Documents(ctx).GetAll()
-	if err != nil {
-		log.Printf("GetUserByIDService: currentMonsterの取得に失敗: %v", err)
+// ListSealedMonsters はユーザーの封印済みモンスターを1ページ分取得します
+// 存在しないユーザーの場合は空のページではなくErrUserNotFoundを返すため、ユーザー本体も並行して確認します
+func (s *UserService) ListSealedMonsters(ctx context.Context, id string, query repositories.SealedMonstersQuery) (*repositories.SealedMonstersPage, error) {
+	var page *repositories.SealedMonstersPage
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		_, err := s.users.GetUserByID(gctx, id)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		page, err = s.users.ListSealedMonsters(gctx, id, query)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		slog.WarnContext(ctx, "ListSealedMonsters: 封印済みモンスターの取得に失敗", "user_id", id, "error", err)
 		return nil, err
 	}
+	return page, nil
+}
 
-	if len(sub) > 0 {
-		var cm models.CurrentMonster
-		if err := sub[0].DataTo(&cm); err != nil {
-			log.Printf("GetUserByIDService: currentMonsterデータのマッピングに失敗: %v", err)
+// 封印済みモンスターを読み込む1ページの件数
+const sealedMonstersPageSize = 100
+
+// listAllSealedMonsters はfrom以降（ゼロ値の場合はすべて）に封印したモンスターを、封印した順にすべて返します
+func listAllSealedMonsters(ctx context.Context, users *repositories.UserRepository, id string, from time.Time) ([]models.SealedMonster, error) {
+	var sealed []models.SealedMonster
+	cursor := ""
+	for {
+		page, err := users.ListSealedMonsters(ctx, id, repositories.SealedMonstersQuery{
+			Limit:  sealedMonstersPageSize,
+			From:   from,
+			Cursor: cursor,
+		})
+		if err != nil {
 			return nil, err
 		}
-		user.CurrentMonster = &cm
-		log.Printf("GetUserByIDService: currentMonsterを正常に取得: %+v", cm)
-	} else {
-		user.CurrentMonster = nil
-		log.Printf("GetUserByIDService: currentMonsterが見つかりませんでした")
+		sealed = append(sealed, page.SealedMonsters...)
+		if page.NextCursor == "" {
+			return sealed, nil
+		}
+		cursor = page.NextCursor
 	}
+}
 
-	// sealedMonstersの取得
-	log.Printf("GetUserByIDService: sealedMonstersの取得を試行中...")
-	
-	// まず、コレクション参照を明示的に作成
-	sealedMonstersRef := client.Collection("users").Doc(id).Collection("sealedMonsters")
-	log.Printf("GetUserByIDService: sealedMonstersコレクション参照: %v", sealedMonstersRef.Path)
-	
-	// すべてのドキュメントを取得
-	sealedDocs, err := sealedMonstersRef.Documents(ctx).GetAll()
+// UpdateUserProfileInput はプロフィール更新で変更する項目です
+// nilの項目は変更しません
+type UpdateUserProfileInput struct {
+	GithubUserName  *string
+	PhotoURL        *string
+	DisplaySettings *models.DisplaySettings
+	// IANAのタイムゾーン名。コントリビューションの履歴の集計に使います
+	TimeZone *string
+}
+
+// UpdateUserProfile はユーザーのプロフィールを更新します
+// GitHubユーザー名を変更する場合は、トークンの持ち主（viewer）のログイン名と一致するかGitHubに確認し、
+// アバターもGitHubの最新のものに更新します
+func (s *UserService) UpdateUserProfile(ctx context.Context, id string, input UpdateUserProfileInput, githubToken string) (*models.User, error) {
+	user, err := s.users.GetUserByID(ctx, id)
 	if err != nil {
-		log.Printf("GetUserByIDService: sealedMonstersの取得に失敗: %v", err)
+		slog.WarnContext(ctx, "UpdateUserProfile: ユーザーの取得に失敗", "user_id", id, "error", err)
 		return nil, err
 	}
 
-	log.Printf("GetUserByIDService: Firestoreから取得したsealedMonstersドキュメント数: %d", len(sealedDocs))
-	
-	var sealedMonsters []models.SealedMonster
-	for i, doc := range sealedDocs {
-		log.Printf("GetUserByIDService: sealedMonster[%d] ドキュメントID: %s", i, doc.Ref.ID)
-		data := doc.Data()
-		log.Printf("GetUserByIDService: sealedMonster[%d] 生データ: %+v", i, data)
-		
-		// 手動でSealedMonsterを構築
-		sm := models.SealedMonster{}
-		
-		// MonsterId
-		if monsterId, ok := data["monsterId"].(string); ok {
-			sm.MonsterId = monsterId
+	var updates []firestore.Update
+
+	if input.GithubUserName != nil && *input.GithubUserName != user.GithubUserName {
+		viewer, err := s.github.GetViewer(ctx, githubToken)
+		if err != nil {
+			slog.WarnContext(ctx, "UpdateUserProfile: GitHubのviewer取得に失敗", "user_id", id, "error", err)
+			return nil, err
 		}
-		
-		// MonsterName
-		if monsterName, ok := data["monsterName"].(string); ok {
-			sm.MonsterName = monsterName
+		// GitHubのログイン名は大文字小文字を区別しない
+		if !strings.EqualFold(viewer.Login, *input.GithubUserName) {
+			slog.WarnContext(ctx, "UpdateUserProfile: viewerと指定されたユーザー名が一致しません", "user_id", id, "viewer", viewer.Login, "github_user_name", *input.GithubUserName)
+			return nil, apperrors.ErrGitHubUserMismatch
 		}
-		
-		// SealedAt - 複数の型に対応
-		if sealedAtValue, exists := data["sealedAt"]; exists {
-			switch v := sealedAtValue.(type) {
-			case time.Time:
-				sm.SealedAt = v
-			case string:
-				if parsedTime, err := time.Parse(time.RFC3339, v); err == nil {
-					sm.SealedAt = parsedTime
-				} else {
-					log.Printf("GetUserByIDService: sealedMonster[%d] SealedAtの文字列パースに失敗: %v", i, err)
-					sm.SealedAt = time.Time{} // ゼロ値を設定
-				}
-			default:
-				log.Printf("GetUserByIDService: sealedMonster[%d] SealedAtの型が不明: %T", i, v)
-				sm.SealedAt = time.Time{} // ゼロ値を設定
-			}
+
+		// 表記はGitHub側の正式なログイン名に揃える
+		user.GithubUserName = viewer.Login
+		updates = append(updates, firestore.Update{Path: "githubUserName", Value: viewer.Login})
+
+		// photoURLが明示的に指定されていない場合はGitHubのアバターに更新する
+		if input.PhotoURL == nil && viewer.AvatarURL != "" {
+			user.PhotoURL = viewer.AvatarURL
+			updates = append(updates, firestore.Update{Path: "photoURL", Value: viewer.AvatarURL})
 		}
-		
-		log.Printf("GetUserByIDService: sealedMonster[%d] マッピング完了: %+v", i, sm)
-		sealedMonsters = append(sealedMonsters, sm)
 	}
-	user.SealedMonsters = sealedMonsters
-	log.Printf("GetUserByIDService: sealedMonstersを正常に取得: %d件", len(sealedMonsters))
-
-	log.Printf("GetUserByIDService: 最終的なユーザー情報:")
-	log.Printf("  - FirebaseId: %s", user.FirebaseId)
-	log.Printf("  - GithubUserName: %s", user.GithubUserName)
-	log.Printf("  - CurrentMonster: %+v", user.CurrentMonster)
-	log.Printf("  - SealedMonsters数: %d", len(user.SealedMonsters))
-	for i, sm := range user.SealedMonsters {
-		log.Printf("    [%d] %+v", i, sm)
+
+	if input.PhotoURL != nil {
+		user.PhotoURL = *input.PhotoURL
+		updates = append(updates, firestore.Update{Path: "photoURL", Value: *input.PhotoURL})
+	}
+
+	if input.DisplaySettings != nil {
+		user.DisplaySettings = input.DisplaySettings
+		updates = append(updates, firestore.Update{Path: "displaySettings", Value: map[string]interface{}{
+			"displayName": input.DisplaySettings.DisplayName,
+			"theme":       input.DisplaySettings.Theme,
+		}})
+	}
+
+	if input.TimeZone != nil {
+		user.TimeZone = *input.TimeZone
+		updates = append(updates, firestore.Update{Path: "timeZone", Value: *input.TimeZone})
+	}
+
+	if len(updates) == 0 {
+		return user, nil
 	}
-	return &user, nil
+
+	if err := s.users.UpdateUserFields(ctx, id, updates); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "UpdateUserProfile: プロフィールを更新しました", "user_id", id, "fields", len(updates))
+	return user, nil
 }
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/metrics"
+	"geekcamp-vol10-backend/internal/models"
+	"geekcamp-vol10-backend/internal/outbox"
+	"geekcamp-vol10-backend/internal/repositories"
+	"geekcamp-vol10-backend/internal/webhook"
+)
+
+const (
+	// 1人のユーザーが登録できるwebhookの数
+	maxWebhooks = 5
+	// 配送の記録を残す期間（expireAtにTTLポリシーを設定した場合）
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	// テストイベントの種類
+	WebhookTestEventType = "Test"
+)
+
+// WebhookService はwebhookの登録と、ドメインイベントのwebhookへの送信を行います
+type WebhookService struct {
+	users    *repositories.UserRepository
+	monsters *repositories.MonsterRepository
+	sender   *webhook.Sender
+}
+
+// NewWebhookService はWebhookServiceを作成します
+func NewWebhookService(users *repositories.UserRepository, monsters *repositories.MonsterRepository, sender *webhook.Sender) *WebhookService {
+	return &WebhookService{
+		users:    users,
+		monsters: monsters,
+		sender:   sender,
+	}
+}
+
+// ValidateURL は登録するURLに送信できるかを確認します
+func (s *WebhookService) ValidateURL(rawURL string) error {
+	return s.sender.ValidateURL(rawURL)
+}
+
+// CreateWebhook はwebhookを登録します
+// 返したwebhookのSecretは作成時にしか返さないため、呼び出し側でレスポンスに含めてください
+func (s *WebhookService) CreateWebhook(ctx context.Context, id, rawURL, format string, events []string) (*models.Webhook, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.users.CreateWebhook(ctx, id, models.Webhook{
+		URL:       rawURL,
+		Format:    format,
+		Events:    slices.Compact(slices.Sorted(slices.Values(events))),
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, maxWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "webhookを登録しました", "user_id", id, "webhook_id", created.WebhookId, "format", format, "events", created.Events)
+	return created, nil
+}
+
+// ListWebhooks はユーザーのwebhookを返します
+func (s *WebhookService) ListWebhooks(ctx context.Context, id string) ([]models.Webhook, error) {
+	// ユーザーがいない場合に空の一覧ではなく404を返すため
+	if _, err := s.users.GetUserByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.users.ListWebhooks(ctx, id)
+}
+
+// DeleteWebhook はwebhookの登録を解除します
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id, webhookId string) error {
+	if err := s.users.DeleteWebhook(ctx, id, webhookId); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "webhookの登録を解除しました", "user_id", id, "webhook_id", webhookId)
+	return nil
+}
+
+// ListDeliveries はwebhookへの配送の記録を新しい順に最大limit件返します
+func (s *WebhookService) ListDeliveries(ctx context.Context, id, webhookId string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.users.GetWebhook(ctx, id, webhookId); err != nil {
+		return nil, err
+	}
+	return s.users.ListWebhookDeliveries(ctx, id, webhookId, limit)
+}
+
+// SendTest はwebhookにテストイベントを1回だけ送信し、配送の記録を返します
+// 受信側が失敗を返した場合も記録を返します（再試行はしません）
+func (s *WebhookService) SendTest(ctx context.Context, id, webhookId string) (*models.WebhookDelivery, error) {
+	w, err := s.users.GetWebhook(ctx, id, webhookId)
+	if err != nil {
+		return nil, err
+	}
+	eventID := make([]byte, 10)
+	if _, err := rand.Read(eventID); err != nil {
+		return nil, err
+	}
+	msg := webhook.Message{
+		ID:        "test_" + hex.EncodeToString(eventID),
+		Type:      WebhookTestEventType,
+		UserID:    id,
+		CreatedAt: time.Now(),
+		Title:     "テストイベント",
+		Text:      "GrassChainからwebhookのテストを送信しました",
+	}
+	delivery, _ := s.deliver(ctx, id, *w, msg, 1, true)
+	return delivery, nil
+}
+
+// HandleEvent はoutbox.Dispatcherに登録するコンシューマーで、イベントを受け取るwebhookに送信します
+// 送信済みのwebhookは配送の記録で判断するため、再試行では失敗したwebhookにだけ送信します
+// 再試行しても成功する見込みのない失敗（4xxなど）は記録だけしてエラーにしません
+func (s *WebhookService) HandleEvent(ctx context.Context, ev outbox.Event) error {
+	webhooks, err := s.users.ListWebhooks(ctx, ev.UserID)
+	if err != nil {
+		return err
+	}
+	var targets []models.Webhook
+	for _, w := range webhooks {
+		if slices.Contains(w.Events, ev.Type) {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	user, err := s.users.GetUserByID(ctx, ev.UserID)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		// イベントの後に削除されたユーザーのwebhookには送信しない
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	msg, err := s.message(ctx, user, ev)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, w := range targets {
+		prev, err := s.users.GetWebhookDelivery(ctx, ev.UserID, w.WebhookId, ev.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if prev != nil && prev.Status == models.WebhookDeliverySucceeded {
+			continue
+		}
+		attempts := 1
+		if prev != nil {
+			attempts = prev.Attempts + 1
+		}
+		if _, err := s.deliver(ctx, ev.UserID, w, msg, attempts, false); err != nil && webhook.Retryable(err) {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", w.WebhookId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver はmsgをwに送信し、結果を配送の記録として保存します
+func (s *WebhookService) deliver(ctx context.Context, id string, w models.Webhook, msg webhook.Message, attempts int, test bool) (*models.WebhookDelivery, error) {
+	start := time.Now()
+	statusCode, err := s.sender.Send(ctx, w.URL, w.Format, w.Secret, msg)
+	delivery := models.WebhookDelivery{
+		DeliveryId:    msg.ID,
+		EventType:     msg.Type,
+		Status:        models.WebhookDeliverySucceeded,
+		StatusCode:    statusCode,
+		Attempts:      attempts,
+		DurationMs:    time.Since(start).Milliseconds(),
+		LastAttemptAt: start,
+		Test:          test,
+		ExpireAt:      start.Add(webhookDeliveryRetention),
+	}
+	if err != nil {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.Error = err.Error()
+		slog.WarnContext(ctx, "webhookへの送信に失敗しました",
+			"user_id", id, "webhook_id", w.WebhookId, "event_id", msg.ID, "status_code", statusCode, "attempt", attempts, "error", err)
+	}
+	metrics.IncWebhookDelivery(w.Format, delivery.Status)
+
+	if err := s.users.SaveWebhookDelivery(ctx, id, w.WebhookId, delivery); err != nil {
+		// 成功を記録できなかった場合は、イベントの再試行で同じwebhookに再び送信されることがある
+		slog.WarnContext(ctx, "webhookの配送の記録に失敗しました", "user_id", id, "webhook_id", w.WebhookId, "event_id", msg.ID, "error", err)
+	}
+	return &delivery, err
+}
+
+// message はイベントをチャットに表示する文面にします
+func (s *WebhookService) message(ctx context.Context, user *models.User, ev outbox.Event) (webhook.Message, error) {
+	msg := webhook.Message{
+		ID:        ev.ID,
+		Type:      ev.Type,
+		UserID:    ev.UserID,
+		CreatedAt: ev.CreatedAt,
+		Data:      ev.Payload,
+	}
+	name := user.GithubUserName
+
+	switch ev.Type {
+	case outbox.TypeMonsterSealed:
+		var sealed outbox.MonsterSealed
+		if err := ev.Decode(&sealed); err != nil {
+			return msg, err
+		}
+		msg.Title = "モンスターを封印しました"
+		msg.Text = fmt.Sprintf("%sが%sを封印しました！", name, sealed.MonsterName)
+	case outbox.TypeMonsterAssigned:
+		var assigned outbox.MonsterAssigned
+		if err := ev.Decode(&assigned); err != nil {
+			return msg, err
+		}
+		msg.Title = "新しいモンスターが現れました"
+		msg.Text = fmt.Sprintf("%sの前に%sが現れました。あと%dコントリビューションで封印できます",
+			name, s.monsterName(ctx, assigned.MonsterId), max(assigned.RequiredContributions-assigned.ProgressContributions, 0))
+	case outbox.TypeStreakExtended:
+		var streak outbox.StreakExtended
+		if err := ev.Decode(&streak); err != nil {
+			return msg, err
+		}
+		msg.Title = "連続記録を更新しました"
+		msg.Text = fmt.Sprintf("%sの連続記録が%d日になりました（最高記録は%d日）", name, streak.ContinuousSealRecord, streak.MaxSealRecord)
+	case outbox.TypeContributionsCredited:
+		var credited outbox.ContributionsCredited
+		if err := ev.Decode(&credited); err != nil {
+			return msg, err
+		}
+		msg.Title = "コントリビューションを反映しました"
+		msg.Text = fmt.Sprintf("%sの%dコントリビューションで%sにダメージを与えました",
+			name, credited.Contributions, s.monsterName(ctx, credited.MonsterId))
+	default:
+		return msg, fmt.Errorf("webhookに送信できないイベントです: %s", ev.Type)
+	}
+	return msg, nil
+}
+
+// 名前は文面にしか使わないため、取得できなくてもIDから作った名前で送信する
+func (s *WebhookService) monsterName(ctx context.Context, id string) string {
+	monsters, err := s.monsters.GetMonsters(ctx, []string{id})
+	if err != nil {
+		slog.WarnContext(ctx, "モンスターの取得に失敗しました", "monster_id", id, "error", err)
+	}
+	return monsterName(monsters, id)
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName はトレースに付与するサービス名です（OTEL_SERVICE_NAMEで上書きできます）
+const ServiceName = "geekcamp-vol10-backend"
+
+// エクスポーターの種類
+const (
+	// トレースを出力しない（スパンは作成されるが記録されない）
+	ExporterNone = "none"
+	// 標準出力に出力する（ローカルでのデバッグ用）
+	ExporterStdout = "stdout"
+	// OTLP/HTTPで送信する。送信先は OTEL_EXPORTER_OTLP_ENDPOINT などの標準の環境変数で指定する
+	ExporterOTLP = "otlp"
+)
+
+// Tracer はアプリケーションのスパンを作成するTracerを返します
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Setup は指定したエクスポーターでTracerProviderを初期化し、グローバルに設定します
+// 受信したtraceparent/baggageヘッダーを引き継ぐためのPropagatorも設定します。
+// 戻り値の関数はサーバー終了時に呼び出し、未送信のスパンを送信してください
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(exporter) {
+	case "", ExporterNone:
+		// TracerProviderを設定しない場合、otelのデフォルト（何もしない）実装が使われる
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("不明なトレースのエクスポーターです: %q (none, stdout, otlp のいずれかを指定してください)", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("トレースのエクスポーターの初期化に失敗しました: %w", err)
+	}
+
+	// 後に指定したものが優先されるため、OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES で上書きできる
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("トレースのリソースの作成に失敗しました: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		// 呼び出し元がサンプリングしたトレースはそのまま記録する
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("トレースを有効にしました", "exporter", exporter)
+	return provider.Shutdown, nil
+}
+
+// RecordError はスパンにエラーを記録し、ステータスをErrorにします
+// errがnilの場合は何もしません
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"geekcamp-vol10-backend/internal/apperrors"
+	"geekcamp-vol10-backend/internal/docid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError はバリデーションエラーの1項目分です
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ルールごとのエラーメッセージ
+var ruleMessages = map[string]string{
+	"required":     "必須項目です",
+	"github_login": "GitHubのユーザー名として正しくありません",
+	"https_url":    "httpsのURLを指定してください",
+	"firestore_id": "IDとして使用できない値です",
+	"oneof":        "指定できない値です",
+	"max":          "長すぎます",
+	"gte":          "小さすぎます",
+	"lte":          "大きすぎます",
+	"datetime":     "日時はRFC 3339形式（例: 2025-08-01T00:00:00Z）で指定してください",
+	"date":         "日付はYYYY-MM-DD形式（例: 2025-08-01）で指定してください",
+	"timezone":     "IANAのタイムゾーン名（例: Asia/Tokyo）を指定してください",
+}
+
+// Register はginのバリデータにカスタムルールを登録します
+// ルートを登録する前に一度だけ呼び出してください
+func Register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("ginのバリデータがvalidator/v10ではありません")
+	}
+
+	// エラーのフィールド名をJSONのキー名で返す
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	rules := map[string]validator.Func{
+		"github_login": func(fl validator.FieldLevel) bool { return IsGitHubLogin(fl.Field().String()) },
+		"https_url":    func(fl validator.FieldLevel) bool { return IsHTTPSURL(fl.Field().String()) },
+		"firestore_id": func(fl validator.FieldLevel) bool { return docid.Valid(fl.Field().String()) },
+		"date":         func(fl validator.FieldLevel) bool { return IsDate(fl.Field().String()) },
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return fmt.Errorf("バリデーションルール '%s' の登録に失敗しました: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// IsGitHubLogin はGitHubのログイン名として有効かを判定します
+// 英数字とハイフンのみ・39文字以内・先頭末尾にハイフン不可・ハイフンの連続不可
+func IsGitHubLogin(s string) bool {
+	if len(s) == 0 || len(s) > 39 {
+		return false
+	}
+	if s[0] == '-' || s[len(s)-1] == '-' || strings.Contains(s, "--") {
+		return false
+	}
+	for _, r := range s {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDate はYYYY-MM-DD形式の存在する日付かを判定します
+func IsDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// IsHTTPSURL はホスト名を持つhttpsの絶対URLかを判定します
+func IsHTTPSURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" && u.Host != "" && u.User == nil
+}
+
+// Details はバインド時のエラーをフィールドごとのエラーに変換します
+func Details(err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		details := make([]FieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			details = append(details, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: message(fe.Tag()),
+			})
+		}
+		return details
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []FieldError{{Field: typeError.Field, Rule: "type", Message: "型が正しくありません"}}
+	}
+
+	return []FieldError{{Field: "", Rule: "body", Message: "リクエストの形式が正しくありません"}}
+}
+
+// Abort はバリデーションエラーを共通の400レスポンスとして返し、処理を中断します
+func Abort(c *gin.Context, err error) {
+	AbortWithDetails(c, Details(err))
+}
+
+// AbortWithDetails はフィールドごとのエラーを共通の400レスポンスとして返し、処理を中断します
+// レスポンスはapperrors.Middlewareがproblem+json形式で書き込みます
+func AbortWithDetails(c *gin.Context, details []FieldError) {
+	apperrors.Abort(c, apperrors.WithExtensions(apperrors.ErrValidation, map[string]interface{}{
+		"details": details,
+	}))
+}
+
+// "Request.displaySettings.theme" のような名前からトップレベルの構造体名を除く
+// 無名構造体の場合は先頭がフィールド名になるためそのまま返す
+func fieldPath(fe validator.FieldError) string {
+	ns := strings.Split(fe.Namespace(), ".")
+	structNs := strings.Split(fe.StructNamespace(), ".")
+	if len(ns) > 1 && ns[0] == structNs[0] {
+		return strings.Join(ns[1:], ".")
+	}
+	return strings.Join(ns, ".")
+}
+
+func message(rule string) string {
+	if msg, ok := ruleMessages[rule]; ok {
+		return msg
+	}
+	return "値が正しくありません"
+}
+
+// IDParam はパスパラメータがFirestoreのドキュメントIDとして安全な値かを検証するミドルウェアを返します
+// パラメータを持たないルートでは何もしません
+func IDParam(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Params.Get(name)
+		if ok && !docid.Valid(value) {
+			AbortWithDetails(c, []FieldError{{Field: name, Rule: "firestore_id", Message: message("firestore_id")}})
+			return
+		}
+		c.Next()
+	}
+}
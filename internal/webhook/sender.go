@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 受信側のレスポンスボディは読み捨てるが、接続を再利用するために読む上限
+const maxResponseBody = 64 << 10
+
+// ErrForbiddenAddress はプライベートアドレスなど、送信を許可していない宛先です
+var ErrForbiddenAddress = errors.New("送信を許可していないアドレスです")
+
+// StatusError は受信側が2xx以外のステータスを返したことを表します
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("受信側が %d を返しました", e.StatusCode)
+}
+
+// Retryable は時間をおいて再試行すると成功する見込みがあるかどうかを返します
+// 4xx（408・429を除く）はURLや受信側の設定の誤りなので再試行しません
+func Retryable(err error) bool {
+	if errors.Is(err, ErrForbiddenAddress) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Sender はwebhookのURLにメッセージを送信します
+type Sender struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+// NewSender はSenderを作成します
+// allowPrivateがfalseの場合、ループバック・プライベートアドレスなどには接続しません（サーバー内部への送信を防ぐため）
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		// 名前解決の後に接続先のアドレスを確認するため、DNSで内部のアドレスを返されても接続しない
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				// プロキシを経由するとアドレスの確認がプロキシに対して行われるため使わない
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// リダイレクト先は確認していないURLなので追わない
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowPrivate: allowPrivate,
+	}
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !netip.MustParsePrefix("100.64.0.0/10").Contains(addr)
+}
+
+// ValidateURL は登録するURLを確認します
+// httpsのみ受け付けます（allowPrivateの場合はローカルでの確認のためhttpも受け付けます）
+func (s *Sender) ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return errors.New("URLの形式が正しくありません")
+	}
+	if u.Scheme != "https" && !(s.allowPrivate && u.Scheme == "http") {
+		return errors.New("URLはhttpsで指定してください")
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !s.allowPrivate && !publicAddr(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Send はmsgをformatの形式で、secretで署名してrawURLに送信し、受信側のステータスコードを返します
+// 接続できなかった場合のステータスコードは0です
+func (s *Sender) Send(ctx context.Context, rawURL, format, secret string, msg Message) (int, error) {
+	body, err := Body(format, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GrassChain-Webhook/1.0")
+	req.Header.Set(HeaderEvent, msg.Type)
+	req.Header.Set(HeaderDelivery, msg.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(secret, now, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,116 @@
+// Package webhook はユーザーが登録したURLへのイベントの送信（Discord・Slack・汎用のJSON）を扱います
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ペイロードの形式
+const (
+	// Discordのwebhook（embed）
+	FormatDiscord = "discord"
+	// SlackのIncoming Webhook（Block Kit）
+	FormatSlack = "slack"
+	// イベントをそのままJSONにした形式
+	FormatJSON = "json"
+)
+
+// Formats はすべてのペイロードの形式です
+var Formats = []string{FormatDiscord, FormatSlack, FormatJSON}
+
+// 送信するリクエストのヘッダー
+const (
+	HeaderEvent     = "X-GrassChain-Event"
+	HeaderDelivery  = "X-GrassChain-Delivery"
+	HeaderTimestamp = "X-GrassChain-Timestamp"
+	HeaderSignature = "X-GrassChain-Signature"
+)
+
+// Discordのembedの色（ヒートマップの草の色）
+const discordColor = 0x40c463
+
+// Message は送信するイベントです
+type Message struct {
+	// イベントのID。再試行でも変わらないため、受信側で重複を見分けるのに使えます
+	ID        string
+	Type      string
+	UserID    string
+	CreatedAt time.Time
+	// チャットに表示する見出しと本文
+	Title string
+	Text  string
+	// イベントのペイロード（JSON）。汎用のJSONの形式にだけ含めます
+	Data json.RawMessage
+}
+
+// Body はformatの形式のリクエストボディを作成します
+func Body(format string, msg Message) ([]byte, error) {
+	switch format {
+	case FormatDiscord:
+		return json.Marshal(map[string]any{
+			"embeds": []map[string]any{{
+				"title":       msg.Title,
+				"description": msg.Text,
+				"color":       discordColor,
+				"timestamp":   msg.CreatedAt.UTC().Format(time.RFC3339),
+				"footer":      map[string]string{"text": "GrassChain"},
+			}},
+		})
+	case FormatSlack:
+		return json.Marshal(map[string]any{
+			// 通知やブロックを表示できないクライアントに使われる
+			"text": slackEscape(msg.Title + ": " + msg.Text),
+			"blocks": []map[string]any{
+				{"type": "header", "text": map[string]any{"type": "plain_text", "text": msg.Title}},
+				{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": slackEscape(msg.Text)}},
+			},
+		})
+	case FormatJSON:
+		data := msg.Data
+		if len(data) == 0 {
+			data = json.RawMessage("{}")
+		}
+		return json.Marshal(map[string]any{
+			"id":        msg.ID,
+			"type":      msg.Type,
+			"userId":    msg.UserID,
+			"createdAt": msg.CreatedAt.UTC().Format(time.RFC3339),
+			"title":     msg.Title,
+			"text":      msg.Text,
+			"data":      data,
+		})
+	}
+	return nil, fmt.Errorf("不明なペイロードの形式です: %q", format)
+}
+
+// Slackのmrkdwnで特別な意味を持つ文字をエスケープする
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Sign はtimestampとbodyの署名を "sha256=<16進数>" の形式で返します
+// 署名はHMAC-SHA256(secret, "<timestamp>.<body>") で、timestampはUNIX時間（秒）です
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret は署名に使うシークレットを作成します
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("シークレットの作成に失敗しました: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
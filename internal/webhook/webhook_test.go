@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1"}`)
+
+	// HMAC-SHA256("whsec_test", "1754006400.{"id":"evt_1"}")
+	want := "sha256=860e5063b76a452688964b3704ab515a0b9cdb27560e30eeac5dfbbb24d0515b"
+	if got := Sign("whsec_test", timestamp, body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+
+	// 秒未満やタイムゾーンは署名に影響しない
+	if got := Sign("whsec_test", timestamp.Add(500*time.Millisecond).In(time.FixedZone("JST", 9*60*60)), body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+
+	for name, got := range map[string]string{
+		"シークレット": Sign("whsec_other", timestamp, body),
+		"時刻":     Sign("whsec_test", timestamp.Add(time.Second), body),
+		"ボディ":    Sign("whsec_test", timestamp, []byte(`{"id":"evt_2"}`)),
+	} {
+		if got == want {
+			t.Errorf("%sが違うのに署名が一致しました", name)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+64 {
+		t.Errorf("NewSecret() = %q", a)
+	}
+	if a == b {
+		t.Error("NewSecret()が同じ値を返しました")
+	}
+}
+
+func TestBody(t *testing.T) {
+	msg := Message{
+		ID:        "evt_1",
+		Type:      "MonsterSealed",
+		UserID:    "user-1",
+		CreatedAt: time.Date(2025, 8, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60)),
+		Title:     "モンスターを封印しました",
+		Text:      "<script> & 草",
+		Data:      json.RawMessage(`{"monsterId":"monster-1"}`),
+	}
+
+	t.Run(FormatJSON, func(t *testing.T) {
+		body, err := Body(FormatJSON, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["id"] != "evt_1" || got["type"] != "MonsterSealed" || got["userId"] != "user-1" || got["createdAt"] != "2025-08-01T00:00:00Z" {
+			t.Errorf("Body() = %s", body)
+		}
+		if data, _ := got["data"].(map[string]any); data["monsterId"] != "monster-1" {
+			t.Errorf("data = %v", got["data"])
+		}
+	})
+
+	t.Run(FormatJSON+" データなし", func(t *testing.T) {
+		msg := msg
+		msg.Data = nil
+		body, err := Body(FormatJSON, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), `"data":{}`) {
+			t.Errorf("Body() = %s", body)
+		}
+	})
+
+	t.Run(FormatDiscord, func(t *testing.T) {
+		body, err := Body(FormatDiscord, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Embeds []struct {
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				Timestamp   string `json:"timestamp"`
+			} `json:"embeds"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Embeds) != 1 || got.Embeds[0].Title != msg.Title || got.Embeds[0].Description != msg.Text || got.Embeds[0].Timestamp != "2025-08-01T00:00:00Z" {
+			t.Errorf("Body() = %s", body)
+		}
+	})
+
+	t.Run(FormatSlack, func(t *testing.T) {
+		body, err := Body(FormatSlack, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		// mrkdwnの特殊文字はエスケープする
+		if want := "モンスターを封印しました: &lt;script&gt; &amp; 草"; got.Text != want {
+			t.Errorf("text = %q, want %q", got.Text, want)
+		}
+	})
+
+	if _, err := Body("xml", msg); err == nil {
+		t.Error("不明な形式でエラーになりません")
+	}
+}
@@ -2,85 +2,64 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"geekcamp-vol10-backend/internal/config"
-	"log"
+	"log/slog"
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	"google.golang.org/api/option"
 )
 
 
-var (
-	// グローバルなFirestoreクライアント
-	FirestoreClient *firestore.Client
-	// グローバルなFirebaseアプリ
-	FirebaseApp *firebase.App
-)
+// Firebase はFirebaseアプリとFirestoreクライアントをまとめたものです
+// Firebaseアプリはアプリケーション全体で1つだけ作成し、認証(middleware.AuthMiddleware)でも共有します
+type Firebase struct {
+	App       *firebase.App
+	Firestore *firestore.Client
+}
 
-// InitializeFirestore はFirestoreクライアントを初期化します
-func InitializeFirestore(ctx context.Context, cfg *config.Config) error {
+// NewFirebase はFirebaseアプリとFirestoreクライアントを初期化します
+// 必要な設定値の有無はconfig.Loadで検証済みです。使い終わったらCloseを呼び出してください
+func NewFirebase(ctx context.Context, cfg *config.Config) (*Firebase, error) {
+	var app *firebase.App
 	var err error
 
 	// エミュレータモードかどうかを判定
-	if cfg.FirestoreEmulatorHost != "" {
-		log.Println("Firestore エミュレータモードで初期化します")
-
-		// エミュレータ用の設定
-		if cfg.GCloudProject == "" {
-			log.Fatal("エミュレータ利用時は環境変数 'GCLOUD_PROJECT' が必要です")
-		}
+	if cfg.IsEmulatorMode() {
+		slog.Info("Firebase エミュレータモードで初期化します",
+			"firestore_host", cfg.FirestoreEmulatorHost,
+			"auth_host", cfg.FirebaseAuthEmulatorHost,
+		)
 
+		// エミュレータ利用時はサービスアカウントキーは不要ですが、プロジェクトIDが必要です
 		conf := &firebase.Config{
 			ProjectID: cfg.GCloudProject,
 		}
-		FirebaseApp, err = firebase.NewApp(ctx, conf)
-		if err != nil {
-			log.Printf("Firebase App の初期化に失敗しました: %v", err)
-			return err
-		}
-
+		app, err = firebase.NewApp(ctx, conf)
 	} else {
-		log.Println("本番環境のFirestoreで初期化します")
-
-		// 本番環境用の設定
-		if cfg.FirebaseCredentials == "" {
-			log.Fatal("本番環境利用時は環境変数 'CREDENTIALS' が必要です")
-		}
+		slog.Info("本番環境のFirebaseで初期化します")
 
 		opt := option.WithCredentialsFile(cfg.FirebaseCredentials)
-		FirebaseApp, err = firebase.NewApp(ctx, nil, opt)
-		if err != nil {
-			log.Printf("Firebase App の初期化に失敗しました: %v", err)
-			return err
-		}
+		app, err = firebase.NewApp(ctx, nil, opt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Firebase App の初期化に失敗しました: %w", err)
 	}
 
 	// Firestoreクライアントを取得
-	FirestoreClient, err = FirebaseApp.Firestore(ctx)
+	client, err := app.Firestore(ctx)
 	if err != nil {
-		log.Printf("Firestore クライアントの取得に失敗しました: %v", err)
-		return err
+		return nil, fmt.Errorf("Firestore クライアントの取得に失敗しました: %w", err)
 	}
 
-	log.Println("Firestore の初期化が完了しました")
-	return nil
+	slog.Info("Firestore の初期化が完了しました")
+	return &Firebase{App: app, Firestore: client}, nil
 }
 
-// CloseFirestore はFirestoreクライアントを閉じます
-func CloseFirestore() error {
-	if FirestoreClient != nil {
-		return FirestoreClient.Close()
+// Close はFirestoreクライアントを閉じます
+func (f *Firebase) Close() error {
+	if f.Firestore != nil {
+		return f.Firestore.Close()
 	}
 	return nil
 }
-
-// GetFirestoreClient はFirestoreクライアントを返します
-func GetFirestoreClient() *firestore.Client {
-	return FirestoreClient
-}
-
-// GetFirebaseApp はFirebaseアプリを返します
-func GetFirebaseApp() *firebase.App {
-	return FirebaseApp
-
-}